@@ -107,7 +107,7 @@ func (a Abstract) S(slices ...Slice) (newShape Shapelike, err error) {
 
 	opDims := len(a)
 	if len(slices) > opDims {
-		err = errors.Errorf(dimsMismatch, opDims, len(slices))
+		err = newDimMismatchError(dimsMismatch, opDims, len(slices), a)
 		return
 	}
 
@@ -187,7 +187,7 @@ func (a Abstract) Repeat(axis Axis, repeats ...int) (retVal Shapelike, finalRepe
 	default:
 		if int(axis) >= a.Dims() {
 			// error
-			err = errors.Errorf(invalidAxis, axis, a.Dims())
+			err = newInvalidAxisError(axis, a.Dims(), a)
 			return
 		}
 		sz = a[axis]
@@ -209,7 +209,7 @@ func (a Abstract) Repeat(axis Axis, repeats ...int) (retVal Shapelike, finalRepe
 		// optimistically check
 		reps := len(repeats)
 		if size > 0 && reps != size {
-			err = errors.Errorf(broadcastError, size, reps)
+			err = newDimMismatchError(broadcastError, size, reps, a)
 			return
 		}
 		newSize := sumInts(repeats)
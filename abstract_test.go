@@ -99,8 +99,8 @@ func ExampleSlice_s() {
 	fmt.Printf("%v @ %v ↠ %v", result, snd, result2)
 
 	// Output:
-	// expr: (a, b) → (a, b, a + b, K b) → (a[1:5], b[1:5], a + b[1:5], K b[2:5])
-	// (a, b) → (a, b, a + b, K b) → (a[1:5], b[1:5], a + b[1:5], K b[2:5]) @ (10, 20) ↠ (10, 20, 30, 20) → (4, 4, 4, 3)
+	// expr: (a, b) → (a, b, a + b, K b) → (a[1:5], b[1:5], (a + b)[1:5], (K b)[2:5])
+	// (a, b) → (a, b, a + b, K b) → (a[1:5], b[1:5], (a + b)[1:5], (K b)[2:5]) @ (10, 20) ↠ (10, 20, 30, 20) → (4, 4, 4, 3)
 	// (10, 20, 30, 20) → (4, 4, 4, 3) @ (10, 20, 30, 20) ↠ (4, 4, 4, 3)
 
 }
@@ -0,0 +1,531 @@
+package shapes
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// binary.go describes a compact binary encoding of expressions.
+//
+// An encoded expression is laid out as follows:
+//
+//	version  byte
+//	vars     uvarint n, followed by n uvarint runes
+//	expr     opcode byte, followed by the fields of the expression
+//
+// The variables are interned: each variable is written once in the table of vars, in order of first appearance,
+// and the expression refers to a variable by its index in the table. Integers are written as varints, lengths as uvarints and
+// operators as a single byte. The fields of an expression are written in the same order as the fields of its type.
+//
+// The encoding is deterministic, so equal expressions have equal encodings and hashes.
+
+// BinaryVersion is the version of the binary encoding of expressions.
+const BinaryVersion = 1
+
+// opcodes of the expressions in the binary encoding. The values must not change.
+const (
+	binNil byte = iota
+	binVar
+	binSize
+	binAxis
+	binSizes
+	binAxes
+	binShape
+	binAbstract
+	binArrow
+	binApplication
+	binCompound
+	binSubjectTo
+	binBinOp
+	binUnaryOp
+	binRange
+	binSlices
+	binIndexOf
+	binTransposeOf
+	binSliceOf
+	binConcatOf
+	binRepeatOf
+	binBroadcastOf
+	binReductOf
+	binDiagOf
+	binDiagEmbedOf
+)
+
+type binEncoder struct {
+	buf  []byte
+	vars map[Var]int
+	tbl  []Var
+}
+
+func (e *binEncoder) byte(b byte) { e.buf = append(e.buf, b) }
+
+func (e *binEncoder) int(i int) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], int64(i))
+	e.buf = append(e.buf, tmp[:n]...)
+}
+
+func (e *binEncoder) uint(i int) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], uint64(i))
+	e.buf = append(e.buf, tmp[:n]...)
+}
+
+func (e *binEncoder) ints(is []int) {
+	e.uint(len(is))
+	for _, i := range is {
+		e.int(i)
+	}
+}
+
+func (e *binEncoder) op(op OpType) error {
+	if _, ok := optypeStr[op]; !ok {
+		return errors.Errorf("Cannot encode unknown OpType %d", byte(op))
+	}
+	e.byte(byte(op))
+	return nil
+}
+
+// exprs encodes expressions in order, stopping at the first error.
+func (e *binEncoder) exprs(as ...substitutable) error {
+	for _, a := range as {
+		if err := e.expr(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *binEncoder) expr(a substitutable) error {
+	switch at := a.(type) {
+	case nil:
+		e.byte(binNil)
+	case Var:
+		i, ok := e.vars[at]
+		if !ok {
+			i = len(e.tbl)
+			e.vars[at] = i
+			e.tbl = append(e.tbl, at)
+		}
+		e.byte(binVar)
+		e.uint(i)
+	case Size:
+		e.byte(binSize)
+		e.int(int(at))
+	case Axis:
+		e.byte(binAxis)
+		e.int(int(at))
+	case Sizes:
+		e.byte(binSizes)
+		e.ints(sizesToInts(at))
+	case Axes:
+		e.byte(binAxes)
+		e.ints(axesToInts(at))
+	case Shape:
+		e.byte(binShape)
+		e.ints(at)
+	case Abstract:
+		e.byte(binAbstract)
+		e.uint(len(at))
+		for _, d := range at {
+			if err := e.expr(d.(substitutable)); err != nil {
+				return err
+			}
+		}
+	case Arrow:
+		e.byte(binArrow)
+		return e.exprs(at.A, at.B)
+	case Application:
+		e.byte(binApplication)
+		return e.exprs(at.A, at.B)
+	case Compound:
+		e.byte(binCompound)
+		return e.exprs(at.Expr, at.SubjectTo)
+	case SubjectTo:
+		e.byte(binSubjectTo)
+		if err := e.op(at.OpType); err != nil {
+			return err
+		}
+		return e.exprs(at.A, at.B)
+	case BinOp:
+		e.byte(binBinOp)
+		if err := e.op(at.Op); err != nil {
+			return err
+		}
+		return e.exprs(at.A, at.B)
+	case E2:
+		return e.expr(at.BinOp)
+	case UnaryOp:
+		e.byte(binUnaryOp)
+		if err := e.op(at.Op); err != nil {
+			return err
+		}
+		return e.expr(at.A)
+	case Range:
+		e.byte(binRange)
+		e.int(at.start)
+		e.int(at.end)
+		e.int(at.step)
+	case *Range:
+		return e.expr(*at)
+	case Slices:
+		e.byte(binSlices)
+		e.uint(len(at))
+		for _, s := range at {
+			e.int(s.Start())
+			e.int(s.End())
+			e.int(s.Step())
+		}
+	case IndexOf:
+		e.byte(binIndexOf)
+		e.int(int(at.I))
+		return e.expr(at.A)
+	case TransposeOf:
+		e.byte(binTransposeOf)
+		e.ints(axesToInts(at.Axes))
+		return e.expr(at.A)
+	case SliceOf:
+		e.byte(binSliceOf)
+		return e.exprs(at.Slice, at.A)
+	case sizelikeSliceOf:
+		return e.expr(at.SliceOf)
+	case ConcatOf:
+		e.byte(binConcatOf)
+		e.int(int(at.Along))
+		return e.exprs(at.A, at.B)
+	case RepeatOf:
+		e.byte(binRepeatOf)
+		e.int(int(at.Along))
+		e.ints(sizesToInts(at.Repeats))
+		return e.expr(at.A)
+	case BroadcastOf:
+		e.byte(binBroadcastOf)
+		return e.exprs(at.A, at.B)
+	case ReductOf:
+		e.byte(binReductOf)
+		if err := e.expr(at.A); err != nil {
+			return err
+		}
+		e.int(int(at.Along))
+	case DiagOf:
+		e.byte(binDiagOf)
+		e.int(at.Offset)
+		e.int(int(at.Axis1))
+		e.int(int(at.Axis2))
+		return e.expr(at.A)
+	case DiagEmbedOf:
+		e.byte(binDiagEmbedOf)
+		e.int(at.Offset)
+		e.int(int(at.Axis1))
+		e.int(int(at.Axis2))
+		return e.expr(at.A)
+	default:
+		return errors.Errorf("Cannot encode %v of %T", a, a)
+	}
+	return nil
+}
+
+// marshalBinary encodes an expression.
+func marshalBinary(a substitutable) ([]byte, error) {
+	e := binEncoder{vars: make(map[Var]int)}
+	if err := e.expr(a); err != nil {
+		return nil, err
+	}
+
+	retVal := binEncoder{buf: make([]byte, 0, len(e.buf)+1+len(e.tbl)+binary.MaxVarintLen64)}
+	retVal.byte(BinaryVersion)
+	retVal.uint(len(e.tbl))
+	for _, v := range e.tbl {
+		retVal.uint(int(v))
+	}
+	retVal.buf = append(retVal.buf, e.buf...)
+	return retVal.buf, nil
+}
+
+type binDecoder struct {
+	data []byte
+	vars []Var
+	err  error // the first error. Once set, everything that is read is a zero value.
+}
+
+func (d *binDecoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = errors.Errorf(format, args...)
+	}
+}
+
+func (d *binDecoder) byte() byte {
+	if len(d.data) == 0 {
+		d.fail("Unexpected end of data")
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *binDecoder) int() int {
+	i, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail("Invalid varint")
+		d.data = nil
+		return 0
+	}
+	d.data = d.data[n:]
+	return int(i)
+}
+
+func (d *binDecoder) uint() int {
+	i, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail("Invalid uvarint")
+		d.data = nil
+		return 0
+	}
+	d.data = d.data[n:]
+	return int(i)
+}
+
+// len reads a length. Every element takes at least one byte, so a length longer than the remaining data is invalid.
+func (d *binDecoder) len() int {
+	l := d.uint()
+	if l < 0 || l > len(d.data) {
+		d.fail("Invalid length %d", l)
+		d.data = nil
+		return 0
+	}
+	return l
+}
+
+func (d *binDecoder) ints() []int {
+	l := d.len()
+	retVal := make([]int, l)
+	for i := range retVal {
+		retVal[i] = d.int()
+	}
+	return retVal
+}
+
+func (d *binDecoder) op() OpType {
+	op := OpType(d.byte())
+	if _, ok := optypeStr[op]; !ok && d.err == nil {
+		d.fail("Unknown OpType %d", byte(op))
+	}
+	return op
+}
+
+// operand reads an expression that is the operand of another expression.
+func (d *binDecoder) operand() Expr {
+	a := d.expr()
+	e, err := decodedExpr(a)
+	if err != nil {
+		d.fail("%v", err)
+	}
+	return e
+}
+
+func (d *binDecoder) operation() Operation {
+	a := d.expr()
+	op, err := decodedOperation(a)
+	if err != nil {
+		d.fail("%v", err)
+	}
+	return op
+}
+
+func (d *binDecoder) expr() substitutable {
+	if d.err != nil {
+		return nil
+	}
+	switch code := d.byte(); code {
+	case binNil:
+		return nil
+	case binVar:
+		i := d.uint()
+		if i < 0 || i >= len(d.vars) {
+			d.fail("Invalid Var %d. There are only %d Vars", i, len(d.vars))
+			return nil
+		}
+		return d.vars[i]
+	case binSize:
+		return Size(d.int())
+	case binAxis:
+		return Axis(d.int())
+	case binSizes:
+		return intsToSizes(d.ints())
+	case binAxes:
+		return intsToAxes(d.ints())
+	case binShape:
+		return Shape(d.ints())
+	case binAbstract:
+		retVal := make(Abstract, d.len())
+		for i := range retVal {
+			sz, err := decodedSizelike(d.expr())
+			if err != nil {
+				d.fail("%v", err)
+				return nil
+			}
+			retVal[i] = sz
+		}
+		return retVal
+	case binArrow:
+		return Arrow{d.operand(), d.operand()}
+	case binApplication:
+		return Application{d.operand(), d.operand()}
+	case binCompound:
+		e := d.operand()
+		st, ok := d.expr().(SubjectTo)
+		if !ok {
+			d.fail("Expected a SubjectTo in a Compound")
+		}
+		return Compound{e, st}
+	case binSubjectTo:
+		return SubjectTo{d.op(), d.operation(), d.operation()}
+	case binBinOp:
+		return BinOp{d.op(), d.operand(), d.operand()}
+	case binUnaryOp:
+		return UnaryOp{d.op(), d.operand()}
+	case binRange:
+		return Range{d.int(), d.int(), d.int()}
+	case binSlices:
+		retVal := make(Slices, d.len())
+		for i := range retVal {
+			retVal[i] = Range{d.int(), d.int(), d.int()}
+		}
+		return retVal
+	case binIndexOf:
+		return IndexOf{Size(d.int()), d.operand()}
+	case binTransposeOf:
+		return TransposeOf{intsToAxes(d.ints()), d.operand()}
+	case binSliceOf:
+		sl, ok := d.expr().(Slicelike)
+		if !ok {
+			d.fail("Expected a Range, Slices or Var to slice with")
+		}
+		return SliceOf{sl, d.operand()}
+	case binConcatOf:
+		return ConcatOf{Axis(d.int()), d.operand(), d.operand()}
+	case binRepeatOf:
+		return RepeatOf{Axis(d.int()), intsToSizes(d.ints()), d.operand()}
+	case binBroadcastOf:
+		return BroadcastOf{d.operand(), d.operand()}
+	case binReductOf:
+		return ReductOf{d.operand(), Axis(d.int())}
+	case binDiagOf:
+		return DiagOf{d.int(), Axis(d.int()), Axis(d.int()), d.operand()}
+	case binDiagEmbedOf:
+		return DiagEmbedOf{d.int(), Axis(d.int()), Axis(d.int()), d.operand()}
+	default:
+		d.fail("Unknown opcode %d", code)
+	}
+	return nil
+}
+
+func unmarshalBinary(data []byte) (substitutable, error) {
+	d := binDecoder{data: data}
+	if v := d.byte(); d.err == nil && (v < 1 || v > BinaryVersion) {
+		return nil, errors.Errorf(unsupportedVersion, v, BinaryVersion)
+	}
+	d.vars = make([]Var, d.len())
+	for i := range d.vars {
+		d.vars[i] = Var(d.uint())
+	}
+	a := d.expr()
+	if d.err == nil && len(d.data) > 0 {
+		d.fail("%d unexpected bytes after the expression", len(d.data))
+	}
+	if d.err != nil {
+		return nil, errors.Wrap(d.err, "Unable to decode expression")
+	}
+	return a, nil
+}
+
+// UnmarshalExpr decodes an expression encoded by MarshalBinary.
+func UnmarshalExpr(data []byte) (Expr, error) {
+	a, err := unmarshalBinary(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodedExpr(a)
+}
+
+// Hash returns the SHA-256 hash of the binary encoding of an expression.
+// Equal expressions have equal hashes, so the hash may be used as a key to cache the results of inference.
+//
+// Note that expressions that only differ in the names of their variables (e.g. a → a and b → b) have different hashes.
+func Hash(e Expr) (retVal [sha256.Size]byte, err error) {
+	data, err := marshalBinary(e)
+	if err != nil {
+		return retVal, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+func unmarshalBinaryInto(data []byte, ptr interface{}) error {
+	a, err := unmarshalBinary(data)
+	if err != nil {
+		return err
+	}
+	return decodeInto(a, ptr)
+}
+
+/* encoding.BinaryMarshaler and encoding.BinaryUnmarshaler */
+
+func (v Var) MarshalBinary() ([]byte, error)          { return marshalBinary(v) }
+func (v *Var) UnmarshalBinary(data []byte) error      { return unmarshalBinaryInto(data, v) }
+func (s Size) MarshalBinary() ([]byte, error)         { return marshalBinary(s) }
+func (s *Size) UnmarshalBinary(data []byte) error     { return unmarshalBinaryInto(data, s) }
+func (s Sizes) MarshalBinary() ([]byte, error)        { return marshalBinary(s) }
+func (s *Sizes) UnmarshalBinary(data []byte) error    { return unmarshalBinaryInto(data, s) }
+func (a Axis) MarshalBinary() ([]byte, error)         { return marshalBinary(a) }
+func (a *Axis) UnmarshalBinary(data []byte) error     { return unmarshalBinaryInto(data, a) }
+func (a Axes) MarshalBinary() ([]byte, error)         { return marshalBinary(a) }
+func (a *Axes) UnmarshalBinary(data []byte) error     { return unmarshalBinaryInto(data, a) }
+func (s Shape) MarshalBinary() ([]byte, error)        { return marshalBinary(s) }
+func (s *Shape) UnmarshalBinary(data []byte) error    { return unmarshalBinaryInto(data, s) }
+func (a Abstract) MarshalBinary() ([]byte, error)     { return marshalBinary(a) }
+func (a *Abstract) UnmarshalBinary(data []byte) error { return unmarshalBinaryInto(data, a) }
+func (a Arrow) MarshalBinary() ([]byte, error)        { return marshalBinary(a) }
+func (a *Arrow) UnmarshalBinary(data []byte) error    { return unmarshalBinaryInto(data, a) }
+func (a Application) MarshalBinary() ([]byte, error)  { return marshalBinary(a) }
+func (a *Application) UnmarshalBinary(data []byte) error {
+	return unmarshalBinaryInto(data, a)
+}
+func (c Compound) MarshalBinary() ([]byte, error)      { return marshalBinary(c) }
+func (c *Compound) UnmarshalBinary(data []byte) error  { return unmarshalBinaryInto(data, c) }
+func (s SubjectTo) MarshalBinary() ([]byte, error)     { return marshalBinary(s) }
+func (s *SubjectTo) UnmarshalBinary(data []byte) error { return unmarshalBinaryInto(data, s) }
+func (op BinOp) MarshalBinary() ([]byte, error)        { return marshalBinary(op) }
+func (op *BinOp) UnmarshalBinary(data []byte) error    { return unmarshalBinaryInto(data, op) }
+func (op UnaryOp) MarshalBinary() ([]byte, error)      { return marshalBinary(op) }
+func (op *UnaryOp) UnmarshalBinary(data []byte) error  { return unmarshalBinaryInto(data, op) }
+func (s Range) MarshalBinary() ([]byte, error)         { return marshalBinary(s) }
+func (s *Range) UnmarshalBinary(data []byte) error     { return unmarshalBinaryInto(data, s) }
+func (ss Slices) MarshalBinary() ([]byte, error)       { return marshalBinary(ss) }
+func (ss *Slices) UnmarshalBinary(data []byte) error   { return unmarshalBinaryInto(data, ss) }
+func (i IndexOf) MarshalBinary() ([]byte, error)       { return marshalBinary(i) }
+func (i *IndexOf) UnmarshalBinary(data []byte) error   { return unmarshalBinaryInto(data, i) }
+func (t TransposeOf) MarshalBinary() ([]byte, error)   { return marshalBinary(t) }
+func (t *TransposeOf) UnmarshalBinary(data []byte) error {
+	return unmarshalBinaryInto(data, t)
+}
+func (s SliceOf) MarshalBinary() ([]byte, error)      { return marshalBinary(s) }
+func (s *SliceOf) UnmarshalBinary(data []byte) error  { return unmarshalBinaryInto(data, s) }
+func (c ConcatOf) MarshalBinary() ([]byte, error)     { return marshalBinary(c) }
+func (c *ConcatOf) UnmarshalBinary(data []byte) error { return unmarshalBinaryInto(data, c) }
+func (r RepeatOf) MarshalBinary() ([]byte, error)     { return marshalBinary(r) }
+func (r *RepeatOf) UnmarshalBinary(data []byte) error { return unmarshalBinaryInto(data, r) }
+func (b BroadcastOf) MarshalBinary() ([]byte, error)  { return marshalBinary(b) }
+func (b *BroadcastOf) UnmarshalBinary(data []byte) error {
+	return unmarshalBinaryInto(data, b)
+}
+func (r ReductOf) MarshalBinary() ([]byte, error)     { return marshalBinary(r) }
+func (r *ReductOf) UnmarshalBinary(data []byte) error { return unmarshalBinaryInto(data, r) }
+func (d DiagOf) MarshalBinary() ([]byte, error)       { return marshalBinary(d) }
+func (d *DiagOf) UnmarshalBinary(data []byte) error   { return unmarshalBinaryInto(data, d) }
+func (d DiagEmbedOf) MarshalBinary() ([]byte, error)  { return marshalBinary(d) }
+func (d *DiagEmbedOf) UnmarshalBinary(data []byte) error {
+	return unmarshalBinaryInto(data, d)
+}
@@ -0,0 +1,153 @@
+package shapes
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinary_roundTrip(t *testing.T) {
+	assert := assert.New(t)
+	for _, e := range jsonCases {
+		b, err := marshalBinary(e)
+		if err != nil {
+			t.Errorf("Unable to marshal %v: %v", e, err)
+			continue
+		}
+		got, err := UnmarshalExpr(b)
+		if err != nil {
+			t.Errorf("Unable to unmarshal %v from %x: %v", e, b, err)
+			continue
+		}
+		assert.Equal(e, got, "Round trip of %v via %x", e, b)
+
+		j, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(len(b) < len(j)/4, "Expected the binary encoding of %v to be much smaller than %s. Got %d bytes", e, j, len(b))
+
+		// every truncation of the data is an error
+		for i := range b {
+			_, err := UnmarshalExpr(b[:i])
+			checkErr(t, true, err, fmt.Sprintf("truncated %x", b), i)
+		}
+	}
+}
+
+func TestBinary_concrete(t *testing.T) {
+	assert := assert.New(t)
+	in := MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')})
+	b, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Arrow
+	if err := out.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(in, out)
+
+	var s Shape
+	assert.NotNil(s.UnmarshalBinary(b), "Expected an error decoding an Arrow into a Shape")
+}
+
+var unmarshalExprErrTests = []struct {
+	name string
+	in   []byte
+}{
+	{"empty", nil},
+	{"future version", []byte{2, 0, binSize, 4}},
+	{"unknown opcode", []byte{1, 0, 200}},
+	{"unknown op", []byte{1, 1, 'a', binUnaryOp, 100, binVar, 0}},
+	{"undeclared var", []byte{1, 1, 'a', binVar, 1}},
+	{"trailing bytes", []byte{1, 0, binSize, 4, 4}},
+	{"long length", []byte{1, 0, binShape, 100, 2}},
+	{"not a dimension", []byte{1, 0, binAbstract, 1, binArrow, binSize, 2, binSize, 2}},
+	{"not an expression", []byte{1, 0, binArrow, binSubjectTo, byte(Eq), binSize, 1, binSize, 1, binSize, 2}},
+}
+
+func TestUnmarshalExpr_errors(t *testing.T) {
+	for _, c := range unmarshalExprErrTests {
+		_, err := UnmarshalExpr(c.in)
+		checkErr(t, true, err, c.name, c.in)
+	}
+}
+
+func TestHash(t *testing.T) {
+	assert := assert.New(t)
+	hashes := make(map[[32]byte]Expr)
+	for _, e := range jsonCases {
+		h, err := Hash(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other, ok := hashes[h]; ok {
+			t.Errorf("%v and %v have the same hash", e, other)
+		}
+		hashes[h] = e
+
+		// an equal expression has an equal hash
+		parsed, err := Parse(fmt.Sprint(e))
+		if err != nil || !reflect.DeepEqual(e, parsed) {
+			continue
+		}
+		h2, err := Hash(parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(h, h2, "Hash of %v", e)
+	}
+}
+
+func ExampleHash() {
+	a, _ := Parse("(a, b) → (b, c) → (a, c)")
+	b := MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')})
+	c, _ := Parse("(x, y) → (y, z) → (x, z)")
+
+	ha, _ := Hash(a)
+	hb, _ := Hash(b)
+	hc, _ := Hash(c)
+	fmt.Println(ha == hb, ha == hc)
+
+	data, _ := b.MarshalBinary()
+	fmt.Println(len(data), "bytes")
+
+	// Output:
+	// true false
+	// 25 bytes
+}
+
+func benchExpr(n int) Expr {
+	var e Expr = Abstract{Var('a'), Var('b')}
+	for i := 0; i < n; i++ {
+		e = Arrow{Abstract{BinOp{Add, Var('a'), Size(i)}, Var('b')}, e}
+	}
+	return e
+}
+
+func BenchmarkMarshalBinary(b *testing.B) {
+	e := benchExpr(1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := marshalBinary(e); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalExpr(b *testing.B) {
+	data, err := marshalBinary(benchExpr(1000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := UnmarshalExpr(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package shapes
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// diff.go describes how to compare two shapes axis by axis, so that a mismatch may be reported as the axes that differ
+// rather than as two shapes that the reader has to compare by eye.
+
+// DiffKind says how an axis of the expected shape differs from the actual one.
+type DiffKind byte
+
+const (
+	// DimSame is an axis that is the same in both shapes.
+	DimSame DiffKind = iota
+	// DimChanged is an axis that is in both shapes, but with different sizes.
+	DimChanged
+	// DimMissing is an axis of the expected shape that is not in the actual shape.
+	DimMissing
+	// DimExtra is an axis of the actual shape that is not in the expected shape.
+	DimExtra
+)
+
+// String returns the name of the kind.
+func (k DiffKind) String() string {
+	switch k {
+	case DimSame:
+		return "same"
+	case DimChanged:
+		return "changed"
+	case DimMissing:
+		return "missing"
+	case DimExtra:
+		return "extra"
+	}
+	return fmt.Sprintf("DiffKind(%d)", byte(k))
+}
+
+// DimDiff is an axis of the alignment of two shapes.
+type DimDiff struct {
+	Kind DiffKind
+
+	ExpectedAxis, ActualAxis int      // the axis in each shape, or -1 if the shape has no such axis
+	Expected, Actual         Sizelike // the dimension in each shape, or nil if the shape has no such axis
+}
+
+// Differs returns true if the axis is not the same in both shapes.
+func (d DimDiff) Differs() bool { return d.Kind != DimSame }
+
+// Diff aligns the axes of two shapes, and says how each of them differs.
+//
+// Shapes of the same rank are aligned axis by axis. Shapes of different ranks are aligned by their longest common leading and
+// trailing axes, so that e.g. (2, 3, 4) and (2, 4) differ only by the missing axis 1. If neither the leading nor the trailing
+// axes are in common, the trailing axes are aligned, as they are when broadcasting.
+//
+// Only a Shape or an Abstract has axes. Any other expression is compared as a whole, as a single DimDiff whose axes are -1.
+func Diff(expected, actual Expr) []DimDiff {
+	e, eok := diffDims(expected)
+	a, aok := diffDims(actual)
+	if !eok || !aok {
+		d := DimDiff{Kind: DimSame, ExpectedAxis: -1, ActualAxis: -1}
+		if !eq(expected, actual) {
+			d.Kind = DimChanged
+		}
+		return []DimDiff{d}
+	}
+
+	// the common leading and trailing axes
+	n := len(e)
+	if len(a) < n {
+		n = len(a)
+	}
+	var lead, trail int
+	for lead < n && eq(e[lead], a[lead]) {
+		lead++
+	}
+	for trail < n-lead && eq(e[len(e)-1-trail], a[len(a)-1-trail]) {
+		trail++
+	}
+
+	retVal := make([]DimDiff, 0, len(e)+len(a)-n)
+	for i := 0; i < lead; i++ {
+		retVal = append(retVal, DimDiff{Kind: DimSame, ExpectedAxis: i, ActualAxis: i, Expected: e[i], Actual: a[i]})
+	}
+
+	// the axes in between are paired up. The unpaired ones go after the common leading axes, unless there are no common trailing axes.
+	em, am := len(e)-lead-trail, len(a)-lead-trail
+	pairs := em
+	if am < pairs {
+		pairs = am
+	}
+	padAfter := lead > 0 && trail == 0
+	if !padAfter {
+		retVal = diffUnpaired(retVal, e, a, lead, lead+em-pairs, lead+am-pairs)
+	}
+	for k := 0; k < pairs; k++ {
+		i, j := lead+k, lead+k
+		if !padAfter {
+			i, j = lead+em-pairs+k, lead+am-pairs+k
+		}
+		retVal = append(retVal, DimDiff{Kind: DimChanged, ExpectedAxis: i, ActualAxis: j, Expected: e[i], Actual: a[j]})
+	}
+	if padAfter {
+		retVal = diffUnpaired(retVal, e, a, lead+pairs, lead+em, lead+am)
+	}
+
+	for k := trail; k > 0; k-- {
+		i, j := len(e)-k, len(a)-k
+		retVal = append(retVal, DimDiff{Kind: DimSame, ExpectedAxis: i, ActualAxis: j, Expected: e[i], Actual: a[j]})
+	}
+	return retVal
+}
+
+// diffUnpaired appends the axes of e from `from` to eEnd as missing, and the axes of a from `from` to aEnd as extra.
+// At most one of them has any axes.
+func diffUnpaired(retVal []DimDiff, e, a []Sizelike, from, eEnd, aEnd int) []DimDiff {
+	for i := from; i < eEnd; i++ {
+		retVal = append(retVal, DimDiff{Kind: DimMissing, ExpectedAxis: i, ActualAxis: -1, Expected: e[i]})
+	}
+	for j := from; j < aEnd; j++ {
+		retVal = append(retVal, DimDiff{Kind: DimExtra, ExpectedAxis: -1, ActualAxis: j, Actual: a[j]})
+	}
+	return retVal
+}
+
+// diffDims returns the dimensions of a Shape or an Abstract.
+func diffDims(e Expr) ([]Sizelike, bool) {
+	switch et := e.(type) {
+	case Shape:
+		retVal := make([]Sizelike, len(et))
+		for i, d := range et {
+			retVal[i] = Size(d)
+		}
+		return retVal, true
+	case Abstract:
+		return []Sizelike(et), true
+	}
+	return nil, false
+}
+
+// DiffReport formats the Diff of two shapes side by side, one axis per line, marking the axes that differ, e.g.
+//
+//	  expected  actual
+//	  (2, 3, 4) (2, 4)
+//	  0: 2      0: 2
+//	✗ 1: 3      -      missing
+//	  2: 4      1: 4
+func DiffReport(expected, actual Expr) string {
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, " \texpected\tactual\t\n")
+	fmt.Fprintf(w, " \t%v\t%v\t\n", expected, actual)
+	for _, d := range Diff(expected, actual) {
+		if d.ExpectedAxis < 0 && d.ActualAxis < 0 {
+			// the expressions have no axes, and have already been written out in full
+			if d.Differs() {
+				fmt.Fprintf(w, "✗\t%v\t\t\n", d.Kind)
+			}
+			continue
+		}
+		mark, note := " ", ""
+		if d.Differs() {
+			mark, note = "✗", d.Kind.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", mark, diffAxis(d.ExpectedAxis, d.Expected), diffAxis(d.ActualAxis, d.Actual), note)
+	}
+	w.Flush()
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func diffAxis(axis int, d Sizelike) string {
+	if axis < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d: %v", axis, d)
+}
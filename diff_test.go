@@ -0,0 +1,82 @@
+package shapes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var diffTests = []struct {
+	name             string
+	expected, actual Expr
+
+	correct []DimDiff
+}{
+	{"same", Shape{2, 3}, Shape{2, 3}, []DimDiff{
+		{DimSame, 0, 0, Size(2), Size(2)},
+		{DimSame, 1, 1, Size(3), Size(3)},
+	}},
+	{"changed", Shape{2, 3}, Shape{2, 4}, []DimDiff{
+		{DimSame, 0, 0, Size(2), Size(2)},
+		{DimChanged, 1, 1, Size(3), Size(4)},
+	}},
+	{"missing in the middle", Shape{2, 3, 4}, Shape{2, 4}, []DimDiff{
+		{DimSame, 0, 0, Size(2), Size(2)},
+		{DimMissing, 1, -1, Size(3), nil},
+		{DimSame, 2, 1, Size(4), Size(4)},
+	}},
+	{"extra leading", Shape{2, 3}, Shape{4, 2, 3}, []DimDiff{
+		{DimExtra, -1, 0, nil, Size(4)},
+		{DimSame, 0, 1, Size(2), Size(2)},
+		{DimSame, 1, 2, Size(3), Size(3)},
+	}},
+	{"leading alignment", Shape{2, 3, 4}, Shape{2, 5}, []DimDiff{
+		{DimSame, 0, 0, Size(2), Size(2)},
+		{DimChanged, 1, 1, Size(3), Size(5)},
+		{DimMissing, 2, -1, Size(4), nil},
+	}},
+	{"trailing alignment", Shape{1, 2, 3}, Shape{5, 6}, []DimDiff{
+		{DimMissing, 0, -1, Size(1), nil},
+		{DimChanged, 1, 0, Size(2), Size(5)},
+		{DimChanged, 2, 1, Size(3), Size(6)},
+	}},
+	{"abstract", Abstract{Var('a'), Size(3)}, Shape{2, 3}, []DimDiff{
+		{DimChanged, 0, 0, Var('a'), Size(2)},
+		{DimSame, 1, 1, Size(3), Size(3)},
+	}},
+	{"scalar", Shape{}, Shape{2}, []DimDiff{
+		{DimExtra, -1, 0, nil, Size(2)},
+	}},
+	{"not a shape", Var('a'), Shape{2}, []DimDiff{
+		{DimChanged, -1, -1, nil, nil},
+	}},
+	{"same arrows", MakeArrow(Var('a'), Var('a')), MakeArrow(Var('a'), Var('a')), []DimDiff{
+		{DimSame, -1, -1, nil, nil},
+	}},
+}
+
+func TestDiff(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range diffTests {
+		assert.Equal(c.correct, Diff(c.expected, c.actual), c.name)
+	}
+}
+
+func TestDiffReport(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("  expected actual\n  (2, 3)   (2, 3)\n  0: 2     0: 2\n  1: 3     1: 3", DiffReport(Shape{2, 3}, Shape{2, 3}))
+	assert.Equal("  expected actual\n  a        (2)\n✗ changed", DiffReport(Var('a'), Shape{2}))
+}
+
+func ExampleDiffReport() {
+	fmt.Println(DiffReport(Shape{2, 3, 4, 5}, Shape{2, 7, 5}))
+
+	// Output:
+	//   expected     actual
+	//   (2, 3, 4, 5) (2, 7, 5)
+	//   0: 2         0: 2
+	// ✗ 1: 3         -         missing
+	// ✗ 2: 4         1: 7      changed
+	//   3: 5         2: 5
+}
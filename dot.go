@@ -0,0 +1,201 @@
+package shapes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// dot.go describes how to draw expressions and constraints as graphs in the DOT language of Graphviz, e.g.
+//
+//	shapes.WriteDOT(f, expr)
+//
+// followed by
+//
+//	dot -Tsvg expr.dot > expr.svg
+
+// dotGraph is a DOT graph that is being written.
+type dotGraph struct {
+	buf bytes.Buffer
+	n   int // number of nodes
+}
+
+func newDOTGraph(name string) *dotGraph {
+	g := new(dotGraph)
+	fmt.Fprintf(&g.buf, "digraph %s {\n", name)
+	g.buf.WriteString("\tordering=out;\n")
+	g.buf.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	return g
+}
+
+// node adds a node, and returns its ID.
+func (g *dotGraph) node(label string, attrs ...string) string {
+	id := "n" + strconv.Itoa(g.n)
+	g.n++
+	fmt.Fprintf(&g.buf, "\t%s [label=%s", id, dotQuote(label))
+	for _, attr := range attrs {
+		fmt.Fprintf(&g.buf, ", %s", attr)
+	}
+	g.buf.WriteString("];\n")
+	return id
+}
+
+func (g *dotGraph) edge(from, to string, attrs ...string) {
+	fmt.Fprintf(&g.buf, "\t%s -> %s", from, to)
+	if len(attrs) > 0 {
+		fmt.Fprintf(&g.buf, " [%s]", strings.Join(attrs, ", "))
+	}
+	g.buf.WriteString(";\n")
+}
+
+// tree adds the tree of an expression, and returns the ID of its root.
+func (g *dotGraph) tree(a substitutable, attrs ...string) string {
+	id := g.node(dotLabel(a), attrs...)
+	for _, child := range dotChildren(a) {
+		g.edge(id, g.tree(child, attrs...))
+	}
+	return id
+}
+
+func (g *dotGraph) writeTo(w io.Writer) error {
+	g.buf.WriteString("}\n")
+	_, err := g.buf.WriteTo(w)
+	return err
+}
+
+// dotQuote quotes a string as a DOT ID.
+func dotQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return `"` + s + `"`
+}
+
+// dotLabel returns the label of the node of an expression. Leaves are labelled with the expression itself,
+// and the other nodes with their operator, along with any parameters that are not drawn as children.
+func dotLabel(a substitutable) string {
+	switch at := a.(type) {
+	case Arrow:
+		return "→"
+	case Application:
+		return "@"
+	case Compound:
+		return "{ | }"
+	case SubjectTo:
+		return at.OpType.String()
+	case BinOp:
+		return at.Op.String()
+	case E2:
+		return at.Op.String()
+	case UnaryOp:
+		return at.Op.String()
+	case Abstract:
+		return "Abstract"
+	case ReductOf:
+		return fmt.Sprintf("ReductOf %d", at.Along)
+	case RepeatOf:
+		return fmt.Sprintf("RepeatOf along=%d repeats=%v", at.Along, at.Repeats)
+	case DiagOf:
+		return fmt.Sprintf("DiagOf offset=%d axes=[%d %d]", at.Offset, at.Axis1, at.Axis2)
+	case DiagEmbedOf:
+		return fmt.Sprintf("DiagEmbedOf offset=%d axes=[%d %d]", at.Offset, at.Axis1, at.Axis2)
+	case sizelikeSliceOf:
+		return "SliceOf"
+	}
+	if len(dotChildren(a)) == 0 {
+		return fmt.Sprint(a)
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", a), "shapes.")
+}
+
+// dotChildren returns the children of the node of an expression. A Shape is a leaf.
+func dotChildren(a substitutable) []substitutable {
+	switch at := a.(type) {
+	case Shape:
+		return nil
+	case Compound:
+		return []substitutable{at.Expr, at.SubjectTo}
+	case substitutableExpr:
+		subs := at.subExprs()
+		retVal := make([]substitutable, 0, len(subs))
+		for _, s := range subs {
+			retVal = append(retVal, s)
+		}
+		return retVal
+	}
+	return nil
+}
+
+// WriteDOT writes the tree of an expression as a graph in the DOT language of Graphviz.
+func WriteDOT(w io.Writer, e Expr) error {
+	g := newDOTGraph("expr")
+	g.tree(e)
+	return g.writeTo(w)
+}
+
+// WriteConstraintsDOT writes the constraints of ce (e.g. as made by App) as a graph in the DOT language of Graphviz,
+// along with the substitutions that solve them and the expression that is inferred (see Infer).
+//
+// The constraints are solved in order, as Infer does. If a constraint cannot be solved, it is drawn in red with the error,
+// and the constraints after it are drawn in grey.
+func WriteConstraintsDOT(w io.Writer, ce ConstraintsExpr) error {
+	g := newDOTGraph("constraints")
+
+	// solved[i] are the substitutions that solve the constraints up to and including the i-th.
+	var solved []substitutions
+	subs, solveErr := solveEach(nil, ce.cs, nil, func(i int, ss substitutions) { solved = append(solved, ss) })
+	failed := solveErr != nil
+	for i, c := range ce.cs {
+		// a constraint is drawn with the substitutions that were known before it was solved
+		j := i - 1
+		if j >= len(solved) {
+			j = len(solved) - 1
+		}
+		if j >= 0 {
+			c = c.apply(solved[j].resolved()).(exprConstraint)
+		}
+		var attrs []string
+		var err error
+		switch {
+		case !failed || i < len(solved):
+		case i == len(solved):
+			err = solveErr
+			attrs = append(attrs, "color=red", "fontcolor=red")
+		default:
+			attrs = append(attrs, "color=grey", "fontcolor=grey")
+		}
+
+		id := g.node(fmt.Sprintf("constraint %d\n=", i), attrs...)
+		g.edge(id, g.tree(c.a, attrs...))
+		g.edge(id, g.tree(c.b, attrs...))
+		if err != nil {
+			g.edge(id, g.node(err.Error(), "shape=note", "color=red", "fontcolor=red"), "color=red")
+		}
+	}
+
+	if ce.st.A != nil && ce.st.B != nil {
+		g.edge(g.node("subject to", "shape=ellipse"), g.tree(ce.st))
+	}
+	if failed {
+		return g.writeTo(w)
+	}
+
+	var lines []string
+	for _, s := range subs {
+		lines = append(lines, fmt.Sprintf("%v ↦ %v", s.For, s.Sub))
+	}
+	subsID := g.node("substitutions\n"+strings.Join(lines, "\n"), "shape=note")
+	if ce.e == nil {
+		return g.writeTo(w)
+	}
+
+	result, err := Infer(ce)
+	if err != nil {
+		g.edge(subsID, g.node(err.Error(), "shape=note", "color=red", "fontcolor=red"), "color=red")
+		return g.writeTo(w)
+	}
+	g.edge(subsID, g.tree(result, "style=bold"), `label="result"`)
+	return g.writeTo(w)
+}
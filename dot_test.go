@@ -0,0 +1,132 @@
+package shapes
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+var dotTests = []struct {
+	name string
+	e    Expr
+
+	contains []string
+}{
+	{"Shape", Shape{2, 3}, []string{`n0 [label="(2, 3)"];`}},
+	{"Var", Var('a'), []string{`n0 [label="a"];`}},
+	{"Abstract", Abstract{Var('a'), Size(2)}, []string{
+		`n0 [label="Abstract"];`,
+		`n1 [label="a"];`,
+		`n2 [label="2"];`,
+		"n0 -> n1;",
+		"n0 -> n2;",
+	}},
+	{"Arrow", MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('a')}), []string{
+		`n0 [label="→"];`,
+		"n0 -> n1;",
+		"n0 -> n4;",
+	}},
+	{"Compound", Compound{
+		Expr:      MakeArrow(Var('a'), Var('b')),
+		SubjectTo: SubjectTo{Eq, UnaryOp{Prod, Var('a')}, UnaryOp{Prod, Var('b')}},
+	}, []string{
+		`n0 [label="{ | }"];`,
+		`n4 [label="="];`,
+		`n5 [label="Π"];`,
+	}},
+	{"ReductOf", ReductOf{A: Var('a'), Along: 1}, []string{`n0 [label="ReductOf 1"];`}},
+	{"IndexOf", IndexOf{I: 1, A: Var('a')}, []string{`[label="IndexOf"]`}},
+	{"RepeatOf", RepeatOf{Along: 1, Repeats: []Size{2, 3}, A: Var('a')}, []string{`n0 [label="RepeatOf along=1 repeats=[2 3]"];`}},
+	{"DiagOf", DiagOf{Offset: 1, Axis1: 0, Axis2: 1, A: Var('a')}, []string{`n0 [label="DiagOf offset=1 axes=[0 1]"];`}},
+	{"DiagEmbedOf", DiagEmbedOf{Offset: -1, Axis1: 1, Axis2: 2, A: Var('a')}, []string{`n0 [label="DiagEmbedOf offset=-1 axes=[1 2]"];`}},
+}
+
+func TestWriteDOT(t *testing.T) {
+	for _, c := range dotTests {
+		var buf bytes.Buffer
+		if err := WriteDOT(&buf, c.e); err != nil {
+			t.Errorf("%v: %v", c.name, err)
+			continue
+		}
+		out := buf.String()
+		if !strings.HasPrefix(out, "digraph expr {\n") || !strings.HasSuffix(out, "}\n") {
+			t.Errorf("%v: expected a digraph. Got\n%v", c.name, out)
+		}
+		for _, s := range c.contains {
+			if !strings.Contains(out, s) {
+				t.Errorf("%v: expected %q in\n%v", c.name, s, out)
+			}
+		}
+	}
+}
+
+func TestWriteConstraintsDOT(t *testing.T) {
+	matmul := MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')})
+
+	var buf bytes.Buffer
+	if err := WriteConstraintsDOT(&buf, App(matmul, Shape{2, 3})); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{`label="constraint 0\n="`, `b ↦ 3\na ↦ 2`, `[label="result"]`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in\n%v", s, out)
+		}
+	}
+
+	buf.Reset()
+	ce := App(matmul, Shape{2, 3, 4})
+	if err := WriteConstraintsDOT(&buf, ce); err != nil {
+		t.Fatal(err)
+	}
+	out = buf.String()
+	if !strings.Contains(out, "color=red") {
+		t.Errorf("Expected the failed constraint to be drawn in red. Got\n%v", out)
+	}
+	if strings.Contains(out, "substitutions") {
+		t.Errorf("Expected no substitutions when a constraint cannot be solved. Got\n%v", out)
+	}
+
+	// the constraints after the one that fails are drawn in grey, with the substitutions known when it failed
+	buf.Reset()
+	ce = ConstraintsExpr{cs: constraints{
+		{a: Var('a'), b: Shape{2, 3}},
+		{a: Var('a'), b: Shape{4, 5}},
+		{a: Var('b'), b: Var('a')},
+	}, e: Var('b')}
+	if err := WriteConstraintsDOT(&buf, ce); err != nil {
+		t.Fatal(err)
+	}
+	out = buf.String()
+	for _, s := range []string{
+		`n0 [label="constraint 0\n="];`,
+		`n3 [label="constraint 1\n=", color=red, fontcolor=red];`,
+		`n4 [label="(2, 3)", color=red, fontcolor=red];`,
+		`n7 [label="constraint 2\n=", color=grey, fontcolor=grey];`,
+		`n9 [label="(2, 3)", color=grey, fontcolor=grey];`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in\n%v", s, out)
+		}
+	}
+}
+
+func ExampleWriteDOT() {
+	WriteDOT(os.Stdout, MakeArrow(Abstract{Var('a'), Var('b')}, Shape{2}))
+
+	// Output:
+	// digraph expr {
+	// 	ordering=out;
+	// 	node [shape=box, fontname="monospace"];
+	// 	n0 [label="→"];
+	// 	n1 [label="Abstract"];
+	// 	n2 [label="a"];
+	// 	n1 -> n2;
+	// 	n3 [label="b"];
+	// 	n1 -> n3;
+	// 	n0 -> n1;
+	// 	n4 [label="(2)"];
+	// 	n0 -> n4;
+	// }
+}
@@ -1,12 +1,23 @@
 package shapes
 
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
 const (
-	dimsMismatch      = "Dimension mismatch. Expected %v. Got  %v instead."
-	invalidAxis       = "Invalid axis %d for ndarray with %d dimensions."
-	repeatedAxis      = "repeated axis %d in permutation pattern."
-	invalidSliceIndex = "Invalid slice index. Start: %d, End: %d."
-	unaryOpResolveErr = "Cannot resolve %v to a Size."
-	broadcastErr      = "Cannot broadcast %v with %v. %d-th dimension does not match or is not a 1."
+	dimsMismatch         = "Dimension mismatch. Expected %v. Got  %v instead."
+	invalidAxis          = "Invalid axis %d for ndarray with %d dimensions."
+	repeatedAxis         = "repeated axis %d in permutation pattern."
+	invalidSliceIndex    = "Invalid slice index. Start: %d, End: %d."
+	unaryOpResolveErr    = "Cannot resolve %v to a Size."
+	broadcastErr         = "Cannot broadcast %v with %v. %d-th dimension does not match or is not a 1."
+	indexOutOfBounds     = "Index %d is out of bounds for axis %d with size %d."
+	flatIndexOutOfBounds = "Flat index %d is out of bounds for an array of size %d."
+	notArrow             = "Cannot apply %v of %T. Only an Arrow or a Compound of an Arrow can be applied."
+	unsupportedVersion   = "Unsupported encoding version %d. Versions 1 to %d are supported."
+	cannotBind           = "Cannot bind %v to %v of %T. Only an expression may be bound to a variable."
 )
 
 // NoOpError is a useful for operations that have no op.
@@ -23,3 +34,91 @@ const (
 	broadcastError = "Canot broadcast together. Resulting shape will be at least (%d, 1). Repeats is (%d, 1)"
 	dimMismatch    = "Dimension mismatch. Expected %d, got %d"
 )
+
+// DimMismatchError is returned when a number of dimensions, or a size, is not the expected one.
+type DimMismatchError struct {
+	Expected, Got int
+	Shape         Expr // the shape at fault, if any
+
+	format string
+}
+
+func newDimMismatchError(format string, expected, got int, shape Expr) error {
+	return errors.WithStack(&DimMismatchError{Expected: expected, Got: got, Shape: shape, format: format})
+}
+
+func (e *DimMismatchError) Error() string { return fmt.Sprintf(e.format, e.Expected, e.Got) }
+
+// InvalidAxisError is returned when an axis is out of the range of the dimensions of a shape, or when it is repeated
+// where each axis may only be given once (e.g. in a permutation pattern).
+type InvalidAxisError struct {
+	Axis     Axis
+	Dims     int
+	Shape    Expr // the shape at fault, if any
+	Repeated bool // whether the axis is in range, but repeated
+}
+
+func newInvalidAxisError(axis Axis, dims int, shape Expr) error {
+	return errors.WithStack(&InvalidAxisError{Axis: axis, Dims: dims, Shape: shape})
+}
+
+func newRepeatedAxisError(axis Axis, dims int, shape Expr) error {
+	return errors.WithStack(&InvalidAxisError{Axis: axis, Dims: dims, Shape: shape, Repeated: true})
+}
+
+func (e *InvalidAxisError) Error() string {
+	if e.Repeated {
+		return fmt.Sprintf(repeatedAxis, e.Axis)
+	}
+	return fmt.Sprintf(invalidAxis, e.Axis, e.Dims)
+}
+
+// BroadcastError is returned when two shapes cannot be broadcast together.
+type BroadcastError struct {
+	A, B Expr
+	Axis int // the axis of the broadcast shape that does not match
+}
+
+func newBroadcastError(a, b Expr, axis int) error {
+	return errors.WithStack(&BroadcastError{A: a, B: b, Axis: axis})
+}
+
+func (e *BroadcastError) Error() string { return fmt.Sprintf(broadcastErr, e.A, e.B, e.Axis) }
+
+// UnificationError is returned when two expressions cannot be unified.
+// A or B is nil if it is not an Expr (e.g. a SubjectTo).
+type UnificationError struct {
+	A, B       Expr
+	NumA, NumB int   // the number of sub-expressions of A and B
+	Recursive  bool  // whether A is a Var that occurs in B
+	Path       []int // the indices of the sub-expressions, from the root of the constraint, at which A and B were found
+
+	a, b substitutable
+	c    int // the constraint that failed, if it is only known once all the constraints have been unified
+}
+
+func newUnificationError(a, b substitutable, numA, numB int, recursive bool) error {
+	A, _ := decodedExpr(a)
+	B, _ := decodedExpr(b)
+	return errors.WithStack(&UnificationError{A: A, B: B, NumA: numA, NumB: numB, Recursive: recursive, a: a, b: b})
+}
+
+func (e *UnificationError) Error() string {
+	switch {
+	case e.Recursive:
+		return "Recursive unification"
+	case e.NumA == e.NumB:
+		return fmt.Sprintf("Unification Fail. %v ~ %v cannot proceed", e.a, e.b)
+	}
+	return fmt.Sprintf("Unification Fail. %v ~ %v cannot proceed as they do not contain the same amount of sub-expressions. %v has %d subexpressions while %v has %d subexpressions", e.a, e.b, e.a, e.NumA, e.b, e.NumB)
+}
+
+// UnsatisfiedConstraintError is returned when the SubjectTo of an expression resolves to false.
+type UnsatisfiedConstraintError struct {
+	Constraint SubjectTo // the constraint, with the inferred substitutions applied
+	Expr       Expr      // the inferred expression that the constraint applies to
+}
+
+func (e *UnsatisfiedConstraintError) Error() string {
+	return fmt.Sprintf("SubjectTo %v resolved to false. Cannot continue", e.Constraint)
+}
@@ -0,0 +1,104 @@
+package shapes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Shape{2, 3}.S(nil, nil, nil)
+	var dm *DimMismatchError
+	if assert.True(errors.As(err, &dm), "Expected a *DimMismatchError. Got %v", err) {
+		assert.Equal(2, dm.Expected)
+		assert.Equal(3, dm.Got)
+		assert.Equal(Shape{2, 3}, dm.Shape)
+		assert.EqualError(err, fmt.Sprintf(dimsMismatch, 2, 3))
+	}
+
+	_, _, _, err = Shape{2, 3}.Repeat(5, 1)
+	var ia *InvalidAxisError
+	if assert.True(errors.As(err, &ia), "Expected an *InvalidAxisError. Got %v", err) {
+		assert.Equal(Axis(5), ia.Axis)
+		assert.Equal(2, ia.Dims)
+		assert.Equal(Shape{2, 3}, ia.Shape)
+		assert.EqualError(err, fmt.Sprintf(invalidAxis, 5, 2))
+	}
+
+	err = UnsafePermute([]int{1, 1}, []int{2, 3})
+	ia = nil
+	if assert.True(errors.As(err, &ia), "Expected an *InvalidAxisError. Got %v", err) {
+		assert.Equal(Axis(1), ia.Axis)
+		assert.True(ia.Repeated)
+		assert.EqualError(err, fmt.Sprintf(repeatedAxis, 1))
+	}
+
+	// the number of repeats must match the size of the axis
+	for _, s := range []Shapelike{Shape{2, 3}, Abstract{Size(2), Size(3)}} {
+		_, _, _, err = s.Repeat(1, 1, 2)
+		dm = nil
+		if assert.True(errors.As(err, &dm), "Expected a *DimMismatchError. Got %v", err) {
+			assert.Equal(3, dm.Expected)
+			assert.Equal(2, dm.Got)
+			assert.Equal(s, dm.Shape)
+			assert.EqualError(err, fmt.Sprintf(broadcastError, 3, 2))
+		}
+	}
+
+	_, err = MakeView(Shape{2, 3}, RowMajor).Broadcast(Shape{4, 2, 4})
+	var bc *BroadcastError
+	if assert.True(errors.As(err, &bc), "Expected a *BroadcastError. Got %v", err) {
+		assert.Equal(Shape{2, 3}, bc.A)
+		assert.Equal(Shape{4, 2, 4}, bc.B)
+		assert.Equal(2, bc.Axis)
+		assert.EqualError(err, "Cannot broadcast (2, 3) with (4, 2, 4). 2-th dimension does not match or is not a 1.")
+	}
+
+	matmul := MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')})
+	_, err = InferApp(matmul, Shape{2, 3, 4})
+	var ue *UnificationError
+	if assert.True(errors.As(err, &ue), "Expected a *UnificationError. Got %v", err) {
+		assert.Equal(Abstract{Var('a'), Var('b')}, ue.A)
+		assert.Equal(Shape{2, 3, 4}, ue.B)
+		assert.Equal(2, ue.NumA)
+		assert.Equal(3, ue.NumB)
+		assert.Contains(ue.Error(), "(a, b) ~ (2, 3, 4) cannot proceed as they do not contain the same amount of sub-expressions.")
+		assert.EqualError(err, "Failed to solve [{(a, b) → (b, c) → (a, c) = (2, 3, 4) → d}] | d: "+ue.Error())
+	}
+
+	_, err = unify(Var('a'), Arrow{Var('a'), Var('b')})
+	ue = nil
+	if assert.True(errors.As(err, &ue), "Expected a *UnificationError. Got %v", err) {
+		assert.True(ue.Recursive)
+		assert.Equal(Var('a'), ue.A)
+		assert.EqualError(err, "Recursive unification")
+	}
+
+	first := Compound{
+		Expr:      MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('a')}),
+		SubjectTo: SubjectTo{Lt, IndexOf{1, Abstract{Var('a'), Var('b')}}, Size(4)},
+	}
+	_, err = InferApp(first, Shape{2, 5})
+	var uc *UnsatisfiedConstraintError
+	if assert.True(errors.As(err, &uc), "Expected an *UnsatisfiedConstraintError. Got %v", err) {
+		assert.Equal(SubjectTo{Lt, IndexOf{1, Abstract{Size(2), Size(5)}}, Size(4)}, uc.Constraint)
+		assert.Equal(Shape{2}, uc.Expr)
+		assert.EqualError(err, "SubjectTo ((2, 5)[1] < 4) resolved to false. Cannot continue")
+	}
+}
+
+func ExampleBroadcastError() {
+	_, err := MakeView(Shape{2, 3}, RowMajor).Broadcast(Shape{2, 4})
+
+	var bc *BroadcastError
+	if errors.As(err, &bc) {
+		fmt.Printf("Axis %d of %v does not match %v\n", bc.Axis, bc.A, bc.B)
+	}
+
+	// Output:
+	// Axis 1 of (2, 3) does not match (2, 4)
+}
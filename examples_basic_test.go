@@ -661,6 +661,42 @@ func Example_trace() {
 	// Trace: (a, a) → ()
 }
 
+func Example_diagonal() {
+	// batched trace: the sum of the diagonals of the last two axes
+	trace := Arrow{
+		Var('a'),
+		ReductOf{DiagOf{Offset: 0, Axis1: -2, Axis2: -1, A: Var('a')}, -1},
+	}
+	fmt.Printf("Trace: %v\n", trace)
+	retExpr, err := InferApp(trace, Shape{5, 3, 3})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	fmt.Printf("Applying %v to %v: %v\n", Shape{5, 3, 3}, trace, retExpr)
+
+	// the superdiagonal of a matrix
+	superdiag := Arrow{Var('a'), DiagOf{Offset: 1, Axis1: 0, Axis2: 1, A: Var('a')}}
+	retExpr, err = InferApp(superdiag, Shape{3, 4})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	fmt.Printf("Applying %v to %v: %v\n", Shape{3, 4}, superdiag, retExpr)
+
+	// the inverse
+	embed := Arrow{Var('a'), DiagEmbedOf{Offset: -1, Axis1: -2, Axis2: -1, A: Var('a')}}
+	retExpr, err = InferApp(embed, Shape{5, 3})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	fmt.Printf("Applying %v to %v: %v\n", Shape{5, 3}, embed, retExpr)
+
+	// Output:
+	// Trace: a → /⁻¹Diag⁽⁻² ⁻¹⁾{0} a
+	// Applying (5, 3, 3) to a → /⁻¹Diag⁽⁻² ⁻¹⁾{0} a: (5)
+	// Applying (3, 4) to a → Diag⁽⁰ ¹⁾{1} a: (3)
+	// Applying (5, 3) to a → DiagEmbed⁽⁻² ⁻¹⁾{-1} a: (5, 4, 4)
+}
+
 func Example_keepDims() {
 	keepDims1 := MakeArrow(
 		MakeArrow(Var('a'), Var('b')),
@@ -801,7 +837,7 @@ func Example_im2col() {
 	fmt.Printf("\t%v @ %v → %v", im2colExpr, s, s2)
 
 	// Output:
-	// im2col: (b, c, h, w) → (b, (((h + 2 × 1) - 3) ÷ 1) + 1, (((w + 2 × 1) - 3) ÷ 1) + 1, c × 9)
-	// Applying (100, 3, 90, 120) to (b, c, h, w) → (b, (((h + 2 × 1) - 3) ÷ 1) + 1, (((w + 2 × 1) - 3) ÷ 1) + 1, c × 9):
-	//	(b, c, h, w) → (b, (((h + 2 × 1) - 3) ÷ 1) + 1, (((w + 2 × 1) - 3) ÷ 1) + 1, c × 9) @ (100, 3, 90, 120) → (100, 90, 120, 27)
+	// im2col: (b, c, h, w) → (b, (h + 2 × 1 - 3) ÷ 1 + 1, (w + 2 × 1 - 3) ÷ 1 + 1, c × 9)
+	// Applying (100, 3, 90, 120) to (b, c, h, w) → (b, (h + 2 × 1 - 3) ÷ 1 + 1, (w + 2 × 1 - 3) ÷ 1 + 1, c × 9):
+	//	(b, c, h, w) → (b, (h + 2 × 1 - 3) ÷ 1 + 1, (w + 2 × 1 - 3) ÷ 1 + 1, c × 9) @ (100, 3, 90, 120) → (100, 90, 120, 27)
 }
@@ -1,21 +1,19 @@
 package shapes
 
-import (
-	"fmt"
-)
+import "fmt"
 
 //go-sumtype:decl Expr
 
 // Expr represents an expression. The following types are Expr:
 //
-//	Shape | Abstract | Arrow | Compound
+//	Shape | Abstract | Arrow | Compound | Application
 //	Var | Size | UnaryOp
 //	IndexOf | TransposeOf | SliceOf | RepeatOf | ConcatOf
 //	Sli | Axis | Axes
 //
 // A compact BNF is as follows:
 //
-//	E := S | A | E → E | (E s.t. X)
+//	E := S | A | E → E | (E s.t. X) | E @ E
 //	a | Sz | Π E | Σ E | D E
 //	I n E | T []Ax E | L : E | R Ax n E | C Ax E E
 //	: | Ax | []Ax
@@ -177,11 +175,9 @@ func (a Arrow) isExpr() {}
 func (a Arrow) depth() int { return max(a.A.depth(), a.B.depth()) + 1 }
 
 func (a Arrow) Format(s fmt.State, r rune) {
-	if _, ok := a.A.(Arrow); ok {
-		fmt.Fprintf(s, "(%v) → %v", a.A, a.B)
-		return
-	}
-	fmt.Fprintf(s, "%v → %v", a.A, a.B)
+	fmtOperand(s, a.A, precArrow+1)
+	s.Write([]byte(" → "))
+	fmtOperand(s, a.B, precArrow)
 }
 
 func (a Arrow) apply(ss substitutions) substitutable {
@@ -196,6 +192,66 @@ func (a Arrow) subExprs() []substitutableExpr {
 	return []substitutableExpr{a.A.(substitutableExpr), a.B.(substitutableExpr)}
 }
 
+// Application represents the application of a function expression to an input, written A @ B.
+// Applications are left associative, so f @ x @ y is (f @ x) @ y.
+//
+// An Application resolves to the result of InferArgs, with each application in a chain such as f @ x @ y as an argument.
+type Application struct {
+	A Expr // the function. It must be an Arrow or a Compound of an Arrow
+	B Expr // the input
+}
+
+func (a Application) isExpr() {}
+
+func (a Application) depth() int { return max(a.A.depth(), a.B.depth()) + 1 }
+
+func (a Application) Format(s fmt.State, r rune) {
+	fmtOperand(s, a.A, precApp)
+	s.Write([]byte(" @ "))
+	fmtOperand(s, a.B, precApp+1)
+}
+
+func (a Application) apply(ss substitutions) substitutable {
+	return Application{
+		A: a.A.apply(ss).(Expr),
+		B: a.B.apply(ss).(Expr),
+	}
+}
+func (a Application) freevars() varset { return (exprtup{a.A, a.B}).freevars() }
+
+func (a Application) subExprs() []substitutableExpr {
+	return []substitutableExpr{a.A.(substitutableExpr), a.B.(substitutableExpr)}
+}
+
+func (a Application) resolve() (retVal Expr, err error) { return a.resolveTrace(nil) }
+
+// resolveTrace is resolve, recording each step in tr (which may be nil). The steps are those of the arguments of a.
+func (a Application) resolveTrace(tr *Trace) (retVal Expr, err error) {
+	fn, args := a.flatten()
+	if tr != nil {
+		defer func(arg int) { tr.arg = arg }(tr.arg)
+	}
+	return inferArgs(tr, fn, args...)
+}
+
+// flatten splits a chain of applications f @ x @ y into the function f and its arguments x and y,
+// so that a failure may be blamed on the argument that caused it.
+func (a Application) flatten() (fn Expr, args []Arg) {
+	fn = a
+	for {
+		app, ok := fn.(Application)
+		if !ok {
+			break
+		}
+		args = append(args, Arg{Expr: app.B})
+		fn = app.A
+	}
+	for i, j := 0, len(args)-1; i < j; i, j = i+1, j-1 {
+		args[i], args[j] = args[j], args[i]
+	}
+	return fn, args
+}
+
 /* Example
 
 MatMul:
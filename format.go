@@ -1,6 +1,8 @@
 package shapes
 
 import (
+	"fmt"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -15,9 +17,7 @@ func supInts(a []int) (retVal string) {
 		str := strconv.Itoa(num)
 		// Loop through each character in the string and convert it to superscript
 		for _, char := range str {
-			offset := int(char) - int('0')
-			sup := supdigits[offset]
-			b.WriteRune(sup)
+			b.WriteRune(supRune(char))
 		}
 		if i < len(a)-1 {
 			b.WriteRune(' ')
@@ -30,8 +30,93 @@ func supInts(a []int) (retVal string) {
 func supInt(a int) (retVal string) {
 	str := strconv.Itoa(a)
 	for _, r := range str {
-		offset := int(r) - int('0')
-		retVal += string(supdigits[offset])
+		retVal += string(supRune(r))
 	}
 	return retVal
 }
+
+// supRune converts a digit or a minus sign to its superscript form.
+func supRune(r rune) rune {
+	if r == '-' {
+		return '⁻'
+	}
+	return supdigits[int(r)-int('0')]
+}
+
+// binding powers of expressions when they are formatted. Infix operators use their opprec.
+const (
+	precApp     = -1
+	precArrow   = 0
+	precConcat  = 5
+	precPrefix  = 60
+	precPostfix = 80
+	precAtom    = 100
+)
+
+// exprPrec returns how tightly an expression binds when it is formatted.
+func exprPrec(e interface{}) int {
+	switch et := e.(type) {
+	case Application:
+		return precApp
+	case Arrow:
+		return precArrow
+	case ConcatOf:
+		return precConcat
+	case BinOp:
+		return et.Op.prec()
+	case E2:
+		return et.Op.prec()
+	case UnaryOp, TransposeOf, ReductOf, RepeatOf, DiagOf, DiagEmbedOf:
+		return precPrefix
+	case IndexOf, SliceOf, sizelikeSliceOf:
+		return precPostfix
+	}
+	return precAtom
+}
+
+// fmtOperand formats an operand of an expression, adding parentheses if the operand binds less tightly than prec.
+func fmtOperand(s fmt.State, operand interface{}, prec int) {
+	if exprPrec(operand) < prec {
+		fmt.Fprintf(s, "(%v)", operand)
+		return
+	}
+	fmt.Fprintf(s, "%v", operand)
+}
+
+// fmtSlicing formats the brackets of a slicing expression, e.g. the [0:2] of a[0:2].
+func fmtSlicing(w io.Writer, sl Slicelike) {
+	switch slt := sl.(type) {
+	case Slice:
+		io.WriteString(w, "[")
+		fmtSlice(w, slt, true)
+		io.WriteString(w, "]")
+	case Slices:
+		// a single Slice in Slices is followed by a comma (as in numpy), so that it is not read back as a Slice.
+		io.WriteString(w, "[")
+		for i := range slt {
+			fmtSlice(w, slt[i], len(slt) == 1)
+			if i < len(slt)-1 {
+				io.WriteString(w, ", ")
+			}
+		}
+		if len(slt) == 1 {
+			io.WriteString(w, ",")
+		}
+		io.WriteString(w, "]")
+	case Var:
+		fmt.Fprintf(w, "[%v]", slt)
+	}
+}
+
+// fmtSlice formats a slice as it would be written in a slicing expression, i.e. start:end:step.
+// If full is false, a slice of a single element is written as just its start.
+func fmtSlice(s io.Writer, sl Slice, full bool) {
+	start, end, step := sl.Start(), sl.End(), sl.Step()
+	fmt.Fprintf(s, "%d", start)
+	if full || end != start+1 || step != 1 {
+		fmt.Fprintf(s, ":%d", end)
+	}
+	if step != 1 {
+		fmt.Fprintf(s, ":%d", step)
+	}
+}
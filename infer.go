@@ -36,7 +36,10 @@ func (ce ConstraintsExpr) Format(f fmt.State, r rune) {
 // which will then yield:
 //
 //	(3, c) → (2, c)
-func App(ar Expr, b Expr) ConstraintsExpr {
+func App(ar Expr, b Expr) ConstraintsExpr { return app(nil, ar, b) }
+
+// app is App, recording the alpha renaming in tr (which may be nil).
+func app(tr *Trace, ar Expr, b Expr) ConstraintsExpr {
 	var a Arrow
 	var st SubjectTo
 	switch at := ar.(type) {
@@ -55,7 +58,7 @@ func App(ar Expr, b Expr) ConstraintsExpr {
 	fv := a.freevars()
 
 	// rename all the free variables in b
-	b = alpha(fv, b)
+	b = alpha(tr, fv, b)
 
 	// add those new free variables to the set of free variables
 	fv = append(fv, b.freevars()...)
@@ -63,21 +66,32 @@ func App(ar Expr, b Expr) ConstraintsExpr {
 
 	// get a fresh variable given the set already used
 	fr := fresh(fv)
-	cs := constraints{{a, Arrow{b, fr}}}
+	cs := constraints{{a: a, b: Arrow{b, fr}}}
+	tr.step(StepFresh, "%v", fr)
 	return ConstraintsExpr{cs, fr, st}
 }
 
-func Infer(ce ConstraintsExpr) (Expr, error) {
+func Infer(ce ConstraintsExpr) (Expr, error) { return inferWith(nil, ce) }
+
+// inferWith is Infer, recording each step in tr (which may be nil).
+func inferWith(tr *Trace, ce ConstraintsExpr) (Expr, error) {
 	if ce.e == nil {
 		return nil, errors.Errorf("No expression found in ConstraintExpr %v", ce)
 	}
 
-	subs, err := solve(ce.cs, nil)
+	subs, err := solveWith(tr, ce.cs, nil)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to solve %v", ce)
 	}
+	return inferSolved(tr, ce, subs)
+}
+
+// inferSolved applies the substitutions that solve the constraints of ce to its expression, and checks its SubjectTo.
+func inferSolved(tr *Trace, ce ConstraintsExpr, subs substitutions) (Expr, error) {
+	subs = subs.resolved()
 	retVal := ce.e.apply(subs).(Expr)
-	if retVal, err = recursiveResolve(retVal); err != nil {
+	var err error
+	if retVal, err = recursiveResolveWith(tr, retVal); err != nil {
 		return retVal, err
 	}
 
@@ -92,8 +106,9 @@ func Infer(ce ConstraintsExpr) (Expr, error) {
 		if err != nil {
 			return nil, errors.Errorf("Failed to resolve SubjectTo %v. Error %v", st, err)
 		}
+		tr.step(StepCheck, "%v ⇒ %t", st, ok)
 		if !ok {
-			return nil, errors.Errorf("SubjectTo %v resolved to false. Cannot continue", st)
+			return nil, errors.WithStack(&UnsatisfiedConstraintError{Constraint: st, Expr: retVal})
 		}
 		return retVal, nil
 	}
@@ -101,18 +116,40 @@ func Infer(ce ConstraintsExpr) (Expr, error) {
 	return retVal, nil
 }
 
+// InferApp infers the result of applying a to each of the others in turn, e.g. InferApp(f, x, y) infers f @ x @ y.
+//
+// A failure to apply an argument is an *ArgError, as it is for InferArgs, but its message is that of the underlying error.
 func InferApp(a Expr, others ...Expr) (retVal Expr, err error) {
 	if len(others) == 0 {
 		return nil, errors.New("Expected at least one other shape expression in order to InferApp")
 	}
-	fst := a
+	args := make([]Arg, 0, len(others))
 	for _, e := range others {
-		if fst, err = Infer(App(fst, e)); err != nil {
-			return nil, err
+		args = append(args, Arg{Expr: e})
+	}
+	if retVal, err = inferArgs(nil, a, args...); err != nil {
+		var ae *ArgError
+		if errors.As(err, &ae) {
+			ae.plain = true
 		}
+		return nil, err
+	}
+	return retVal, nil
+}
 
+// Eval parses a string and infers the resulting expression. Applications (written with `@`) are inferred as per InferArgs,
+// so a failure to apply an argument is an *ArgError that says where the argument is in the string.
+//
+// For example, Eval("(a, b) → (b, c) → (a, c) @ (2, 3)") returns (3, c) → (2, c).
+func Eval(a string) (Expr, error) {
+	fn, args, err := ParseApp(a)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return recursiveResolve(fn)
 	}
-	return fst, nil
+	return InferArgs(fn, args...)
 }
 
 func ToShape(a Expr) (Shape, error) {
@@ -138,7 +175,7 @@ func fresh(set varset) Var {
 	return set[len(set)-1] + 1
 }
 
-func alpha(set varset, a Expr) Expr {
+func alpha(tr *Trace, set varset, a Expr) Expr {
 	fv := a.freevars()
 	var subs substitutions
 	for _, v := range fv {
@@ -148,35 +185,49 @@ func alpha(set varset, a Expr) Expr {
 			subs = append(subs, substitution{Sub: fr, For: v})
 		}
 	}
+	tr.subs(StepAlpha, subs)
 	a2 := a.apply(subs).(Expr)
 	return a2
 }
 
-func recursiveResolve(a Expr) (Expr, error) {
+func recursiveResolve(a Expr) (Expr, error) { return recursiveResolveWith(nil, a) }
+
+// recursiveResolveWith is recursiveResolve, recording each rewrite in tr (which may be nil).
+func recursiveResolveWith(tr *Trace, a Expr) (Expr, error) {
 	switch at := a.(type) {
 	case Abstract:
 		// even though Abstract implements `resolver`,
 		// due to the recursive nature of recursiveResolve,
 		// this will cause an infinite loop
 		retVal, err := at.resolve()
+		if err == nil {
+			tr.rewrite(a, retVal)
+		}
 		return retVal, err
 	case Arrow:
-		A, err := recursiveResolve(at.A)
+		A, err := recursiveResolveWith(tr, at.A)
 		if err != nil {
 			return a, nil // if there's an error, don't continue or return errors.
 		}
-		B, err := recursiveResolve(at.B)
+		B, err := recursiveResolveWith(tr, at.B)
 		if err != nil {
 			return a, nil // if there's an error, don't continue or return errors.
 		}
 		return Arrow{A, B}, nil
 	case SliceOf:
-		A, err := recursiveResolve(at.A)
+		A, err := recursiveResolveWith(tr, at.A)
 		if err != nil {
 			return nil, errors.Wrapf(err, "Unable to resolve %v in SliceOf", at.A)
 		}
 		s := SliceOf{at.Slice, A}
-		return s.resolve()
+		retVal, err := s.resolve()
+		if err == nil {
+			tr.rewrite(a, retVal)
+		}
+		return retVal, err
+	case Compound:
+		// a Compound embeds a SubjectTo, which makes it look like a sizeOp. It cannot be resolved any further.
+		return a, nil
 
 	case sizeOp:
 		if !at.isValid() {
@@ -186,16 +237,19 @@ func recursiveResolve(a Expr) (Expr, error) {
 		if err != nil {
 			return a, errors.Wrapf(err, "Cannot resolve final expresison. But it may still be used.")
 		}
+		tr.rewrite(a, Shape{int(sz)})
 		return Shape{int(sz)}, nil
 	case resolver:
 		retVal, err := at.resolve()
 		if _, ok := err.(NoOpError); ok {
+			tr.rewrite(a, retVal)
 			return retVal, nil
 		}
 		if err != nil {
 			return nil, errors.Wrapf(err, "Failed to recursively resolve %v", at)
 		}
-		return recursiveResolve(retVal)
+		tr.rewrite(a, retVal)
+		return recursiveResolveWith(tr, retVal)
 	default:
 		// nothing else can be resolved. return the identity
 		return a, nil
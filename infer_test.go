@@ -1 +1,75 @@
 package shapes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+var evalTests = []struct {
+	in      string
+	correct string
+	err     bool
+}{
+	{"(a, b) → (b, c) → (a, c) @ (2, 3)", "(3, c) → (2, c)", false},
+	{"(a, b) → (b, c) → (a, c) @ (2, 3) @ (3, 4)", "(2, 4)", false},
+	{"(a, b) -> (b, c) -> (a, c) @ (2, 3) @ (3, 4)", "(2, 4)", false},
+	{"(a → a) @ ((b → T⁽¹ ⁰⁾ b) @ (2, 3))", "(3, 2)", false},
+	{"{ a → b → (a||b) | (K a ⚟ K b) } @ (2, 3, 4) @ (2, 1, 4)", "(2, 3, 4)", false},
+	{"a → Π a @ (2, 3)", "(6)", false},
+	{"(2, 3)", "(2, 3)", false},
+	{"(a → b → b s.t. (Πa = Πb)) @ (2, 3) @ (3, 2)", "(3, 2)", false},
+	{"a → b → b s.t. (Πa = Πb)", "{ a → b → b | (Π a = Π b) }", false},
+
+	{"(a, b) → (b, c) → (a, c) @ (2, 3) @ (4, 4)", "", true},
+	{"a @ (2, 3)", "", true},
+	{"(a → b → b s.t. (Πa = Πb)) @ (2, 3) @ (3, 3)", "", true},
+	{"(2, 3) @ (2, 3)", "", true},
+	{"(a, b) → (b, c) → (a, c) @", "", true},
+}
+
+func TestEval(t *testing.T) {
+	for i, c := range evalTests {
+		e, err := Eval(c.in)
+		if checkErr(t, c.err, err, c.in, i) {
+			continue
+		}
+		if got := fmt.Sprint(e); got != c.correct {
+			t.Errorf("Expected Eval(%q) to be %v. Got %v instead", c.in, c.correct, got)
+		}
+	}
+}
+
+func TestEval_provenance(t *testing.T) {
+	_, err := Eval("(a, b) → (b, c) → (a, c) @ (2, 3) @ (4, 5)")
+	var ae *ArgError
+	if !errors.As(err, &ae) {
+		t.Fatalf("Expected an *ArgError. Got %v of %T instead", err, err)
+	}
+	if ae.Arg != 2 || ae.Span != (Span{Start: 36, End: 42}) {
+		t.Errorf("Expected the failure to be blamed on arg 2 at position 36. Got %v", ae.Provenance)
+	}
+	if want := "arg 2 at position 36 dim 0: expected b=3 from arg 1, got 4"; err.Error() != want {
+		t.Errorf("Expected %q. Got %q instead", want, err.Error())
+	}
+}
+
+func ExampleEval() {
+	matmul := "(a, b) → (b, c) → (a, c)"
+	expr, err := Eval(matmul + " @ (2, 3)")
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(expr)
+
+	expr, err = Eval(matmul + " @ (2, 3) @ (3, 4)")
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(expr)
+
+	// Output:
+	// (3, c) → (2, c)
+	// (2, 4)
+}
@@ -19,8 +19,11 @@ type IndexOf struct {
 	A Expr
 }
 
-func (i IndexOf) isExpr()                    {}
-func (i IndexOf) Format(s fmt.State, r rune) { fmt.Fprintf(s, "%v[%d]", i.A, i.I) }
+func (i IndexOf) isExpr() {}
+func (i IndexOf) Format(s fmt.State, r rune) {
+	fmtOperand(s, i.A, precPostfix)
+	fmt.Fprintf(s, "[%d]", i.I)
+}
 func (i IndexOf) apply(ss substitutions) substitutable {
 	return IndexOf{
 		I: i.I,
@@ -66,8 +69,11 @@ type TransposeOf struct {
 	A    Expr
 }
 
-func (t TransposeOf) isExpr()                    {}
-func (t TransposeOf) Format(s fmt.State, r rune) { fmt.Fprintf(s, "T%x %v", t.Axes, t.A) }
+func (t TransposeOf) isExpr() {}
+func (t TransposeOf) Format(s fmt.State, r rune) {
+	fmt.Fprintf(s, "T%x ", t.Axes)
+	fmtOperand(s, t.A, precPrefix)
+}
 func (t TransposeOf) apply(ss substitutions) substitutable {
 	return TransposeOf{
 		Axes: t.Axes,
@@ -101,17 +107,11 @@ type SliceOf struct {
 }
 
 func (s SliceOf) isExpr() {}
-func (s SliceOf) Format(st fmt.State, r rune) {
-	switch s.Slice.(type) {
-	case Slice:
-		fmt.Fprintf(st, "%v%v", s.A, s.Slice)
-	case Slices:
-		fmt.Fprintf(st, "%v%v", s.A, s.Slice)
-	case Var:
-		fmt.Fprintf(st, "%v[%v]", s.A, s.Slice)
-
-	}
 
+// Format formats the SliceOf. A single slice is always written in full (i.e. start:end), so that it is not confused with an IndexOf.
+func (s SliceOf) Format(st fmt.State, r rune) {
+	fmtOperand(st, s.A, precPostfix)
+	fmtSlicing(st, s.Slice)
 }
 func (s SliceOf) apply(ss substitutions) substitutable {
 	return SliceOf{
@@ -173,8 +173,12 @@ type ConcatOf struct {
 	A, B  Expr
 }
 
-func (c ConcatOf) isExpr()                    {}
-func (c ConcatOf) Format(s fmt.State, r rune) { fmt.Fprintf(s, "%v :{%d}: %v", c.A, c.Along, c.B) }
+func (c ConcatOf) isExpr() {}
+func (c ConcatOf) Format(s fmt.State, r rune) {
+	fmtOperand(s, c.A, precConcat)
+	fmt.Fprintf(s, " :{%d}: ", c.Along)
+	fmtOperand(s, c.B, precConcat+1)
+}
 func (c ConcatOf) apply(ss substitutions) substitutable {
 	return ConcatOf{
 		Along: c.Along,
@@ -196,7 +200,8 @@ type RepeatOf struct {
 
 func (r RepeatOf) isExpr() {}
 func (r RepeatOf) Format(s fmt.State, ru rune) {
-	fmt.Fprintf(s, "Repeat%x{%v} %v", r.Along, r.Repeats, r.A)
+	fmt.Fprintf(s, "Repeat%x{%v} ", r.Along, r.Repeats)
+	fmtOperand(s, r.A, precPrefix)
 }
 func (r RepeatOf) apply(ss substitutions) substitutable {
 	return RepeatOf{
@@ -229,8 +234,14 @@ type BroadcastOf struct {
 	A, B Expr
 }
 
-func (b BroadcastOf) isExpr()                    {}
-func (b BroadcastOf) Format(s fmt.State, r rune) { fmt.Fprintf(s, "(%v||%v)", b.A, b.B) }
+func (b BroadcastOf) isExpr() {}
+func (b BroadcastOf) Format(s fmt.State, r rune) {
+	s.Write([]byte("("))
+	fmtOperand(s, b.A, opprec['∨'])
+	s.Write([]byte("||"))
+	fmtOperand(s, b.B, opprec['∨']+1)
+	s.Write([]byte(")"))
+}
 func (b BroadcastOf) apply(ss substitutions) substitutable {
 	return BroadcastOf{
 		A: b.A.apply(ss).(Expr),
@@ -270,8 +281,11 @@ func Reduce(a Expr, along Axes) ReductOf {
 	return retVal.(ReductOf)
 }
 
-func (r ReductOf) isExpr()                    {}
-func (r ReductOf) Format(s fmt.State, c rune) { fmt.Fprintf(s, "/%x%v", r.Along, r.A) }
+func (r ReductOf) isExpr() {}
+func (r ReductOf) Format(s fmt.State, c rune) {
+	fmt.Fprintf(s, "/%x", r.Along)
+	fmtOperand(s, r.A, precPrefix)
+}
 func (r ReductOf) apply(ss substitutions) substitutable {
 	return ReductOf{
 		A:     r.A.apply(ss).(Expr),
@@ -319,3 +333,217 @@ func (r ReductOf) resolve() (Expr, error) {
 	}
 
 }
+
+// DiagOf is the symbolic version of extracting the diagonals (with the given offset) of the 2D planes formed by Axis1 and Axis2 of A.
+// Following np.diagonal, the two axes are removed and a new last axis is appended. The size of the new axis is
+//
+//	min(n1, n2 - k) if k ≥ 0
+//	min(n1 + k, n2) if k < 0
+//
+// where n1 and n2 are the sizes of Axis1 and Axis2, and k is the offset. Negative sizes are clamped to 0.
+//
+// Triangular parts (i.e. triu and tril) do not change the shape and are simply a → a.
+type DiagOf struct {
+	Offset       int
+	Axis1, Axis2 Axis
+	A            Expr
+}
+
+func (d DiagOf) isExpr() {}
+func (d DiagOf) Format(s fmt.State, r rune) {
+	fmt.Fprintf(s, "Diag%x{%d} ", Axes{d.Axis1, d.Axis2}, d.Offset)
+	fmtOperand(s, d.A, precPrefix)
+}
+func (d DiagOf) apply(ss substitutions) substitutable {
+	return DiagOf{
+		Offset: d.Offset,
+		Axis1:  d.Axis1,
+		Axis2:  d.Axis2,
+		A:      d.A.apply(ss).(Expr),
+	}
+}
+func (d DiagOf) freevars() varset { return d.A.freevars() }
+func (d DiagOf) subExprs() []substitutableExpr {
+	return []substitutableExpr{Axes{d.Axis1, d.Axis2}, Size(d.Offset), d.A.(substitutableExpr)}
+}
+func (d DiagOf) depth() int { return d.A.depth() + 1 }
+
+func (d DiagOf) resolve() (Expr, error) {
+	abs, err := resolveToAbstract(d.A)
+	if err != nil {
+		if _, ok := err.(NoOpError); ok {
+			return d, err
+		}
+		return nil, errors.Wrapf(err, "Unable to resolve %v", d)
+	}
+
+	dims := abs.Dims()
+	a1 := ResolveAxis(d.Axis1, abs)
+	a2 := ResolveAxis(d.Axis2, abs)
+	if a1 < 0 || int(a1) >= dims {
+		return nil, newInvalidAxisError(d.Axis1, dims, d.A)
+	}
+	if a2 < 0 || int(a2) >= dims {
+		return nil, newInvalidAxisError(d.Axis2, dims, d.A)
+	}
+	if a1 == a2 {
+		return nil, errors.Errorf("Cannot take the diagonal of %v along the same axis %d twice.", d.A, a1)
+	}
+
+	var size Sizelike
+	n1, err1 := sizelikeToSize(abs[a1])
+	n2, err2 := sizelikeToSize(abs[a2])
+	switch {
+	case err1 == nil && err2 == nil:
+		k := Size(d.Offset)
+		var sz Size
+		if k >= 0 {
+			sz = n1
+			if n2-k < sz {
+				sz = n2 - k
+			}
+		} else {
+			sz = n2
+			if n1+k < sz {
+				sz = n1 + k
+			}
+		}
+		if sz < 0 {
+			sz = 0
+		}
+		size = sz
+	case d.Offset == 0 && eq(abs[a1], abs[a2]):
+		// the diagonal of a symbolic square matrix, e.g. (a, a)
+		size = abs[a1]
+	default:
+		// not enough is known about the sizes yet.
+		return d, noopError{}
+	}
+
+	retVal := make(Abstract, 0, dims-1)
+	for i := range abs {
+		if i != int(a1) && i != int(a2) {
+			retVal = append(retVal, abs[i])
+		}
+	}
+	retVal = append(retVal, size)
+	if shp, ok := retVal.ToShape(); ok {
+		return shp, nil
+	}
+	return retVal, nil
+}
+
+// DiagEmbedOf is the symbolic version of embedding the last axis of A as the diagonals (with the given offset)
+// of the 2D planes formed by Axis1 and Axis2 of the result. It is the inverse of DiagOf.
+//
+// Following torch.diag_embed, the result has one more dimension than A. Axis1 and Axis2 refer to the axes of the result,
+// and both have the size n + |k|, where n is the size of the last axis of A, and k is the offset.
+type DiagEmbedOf struct {
+	Offset       int
+	Axis1, Axis2 Axis
+	A            Expr
+}
+
+func (d DiagEmbedOf) isExpr() {}
+func (d DiagEmbedOf) Format(s fmt.State, r rune) {
+	fmt.Fprintf(s, "DiagEmbed%x{%d} ", Axes{d.Axis1, d.Axis2}, d.Offset)
+	fmtOperand(s, d.A, precPrefix)
+}
+func (d DiagEmbedOf) apply(ss substitutions) substitutable {
+	return DiagEmbedOf{
+		Offset: d.Offset,
+		Axis1:  d.Axis1,
+		Axis2:  d.Axis2,
+		A:      d.A.apply(ss).(Expr),
+	}
+}
+func (d DiagEmbedOf) freevars() varset { return d.A.freevars() }
+func (d DiagEmbedOf) subExprs() []substitutableExpr {
+	return []substitutableExpr{Axes{d.Axis1, d.Axis2}, Size(d.Offset), d.A.(substitutableExpr)}
+}
+func (d DiagEmbedOf) depth() int { return d.A.depth() + 1 }
+
+func (d DiagEmbedOf) resolve() (Expr, error) {
+	abs, err := resolveToAbstract(d.A)
+	if err != nil {
+		if _, ok := err.(NoOpError); ok {
+			return d, err
+		}
+		return nil, errors.Wrapf(err, "Unable to resolve %v", d)
+	}
+	if abs.Dims() == 0 {
+		return nil, errors.Errorf("Cannot embed the diagonal of a scalar %v", d.A)
+	}
+
+	dims := abs.Dims() + 1
+	a1 := d.Axis1
+	if a1 < 0 {
+		a1 += Axis(dims)
+	}
+	a2 := d.Axis2
+	if a2 < 0 {
+		a2 += Axis(dims)
+	}
+	if a1 < 0 || int(a1) >= dims {
+		return nil, newInvalidAxisError(d.Axis1, dims, d.A)
+	}
+	if a2 < 0 || int(a2) >= dims {
+		return nil, newInvalidAxisError(d.Axis2, dims, d.A)
+	}
+	if a1 == a2 {
+		return nil, errors.Errorf("Cannot embed the diagonal of %v along the same axis %d twice.", d.A, a1)
+	}
+
+	last := abs[len(abs)-1]
+	k := d.Offset
+	if k < 0 {
+		k = -k
+	}
+	var size Sizelike = last
+	if k != 0 {
+		switch l := last.(type) {
+		case Size:
+			size = l + Size(k)
+		case BinOp:
+			size = BinOp{Add, E2{l}, Size(k)}
+		case Expr:
+			size = BinOp{Add, l, Size(k)}
+		}
+	}
+
+	retVal := make(Abstract, 0, dims)
+	rest := abs[:len(abs)-1]
+	for i := 0; i < dims; i++ {
+		if i == int(a1) || i == int(a2) {
+			retVal = append(retVal, size)
+			continue
+		}
+		retVal = append(retVal, rest[0])
+		rest = rest[1:]
+	}
+	if shp, ok := retVal.ToShape(); ok {
+		return shp, nil
+	}
+	return retVal, nil
+}
+
+// resolveToAbstract resolves an expression into an Abstract, so that its dimensions may be inspected.
+// A NoOpError is returned if the expression cannot be resolved yet (e.g. it is a Var).
+func resolveToAbstract(a Expr) (Abstract, error) {
+	for {
+		switch at := a.(type) {
+		case Shape:
+			return at.toAbs(0), nil
+		case Abstract:
+			return at, nil
+		case resolver:
+			expr, err := at.resolve()
+			if err != nil {
+				return nil, err
+			}
+			a = expr
+		default:
+			return nil, noopError{}
+		}
+	}
+}
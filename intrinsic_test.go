@@ -0,0 +1,69 @@
+package shapes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var diagOfTests = []struct {
+	name string
+	e    resolver
+
+	correct Expr
+	err     bool
+	noop    bool
+}{
+	{"square", DiagOf{0, 0, 1, Shape{3, 3}}, Shape{3}, false, false},
+	{"wide", DiagOf{0, 0, 1, Shape{3, 4}}, Shape{3}, false, false},
+	{"upper", DiagOf{1, 0, 1, Shape{3, 4}}, Shape{3}, false, false},
+	{"upper 2", DiagOf{2, 0, 1, Shape{3, 4}}, Shape{2}, false, false},
+	{"lower", DiagOf{-1, 0, 1, Shape{3, 4}}, Shape{2}, false, false},
+	{"out of range offset", DiagOf{5, 0, 1, Shape{3, 4}}, Shape{0}, false, false},
+	{"batched", DiagOf{0, 1, 2, Shape{5, 3, 3}}, Shape{5, 3}, false, false},
+	{"negative axes", DiagOf{0, -2, -1, Shape{5, 3, 4}}, Shape{5, 3}, false, false},
+	{"outer axes", DiagOf{0, 0, 2, Shape{3, 5, 4}}, Shape{5, 3}, false, false},
+	{"symbolic square", DiagOf{0, 0, 1, Abstract{Var('a'), Var('a')}}, Abstract{Var('a')}, false, false},
+	{"symbolic batch", DiagOf{0, 1, 2, Abstract{Var('b'), Size(3), Size(3)}}, Abstract{Var('b'), Size(3)}, false, false},
+	{"symbolic offset", DiagOf{1, 0, 1, Abstract{Var('a'), Var('a')}}, nil, false, true},
+	{"var", DiagOf{0, 0, 1, Var('a')}, nil, false, true},
+	{"nested", DiagOf{0, 0, 1, TransposeOf{Axes{1, 0}, Shape{4, 3}}}, Shape{3}, false, false},
+	{"same axes", DiagOf{0, 1, 1, Shape{3, 3}}, nil, true, false},
+	{"bad axis", DiagOf{0, 0, 2, Shape{3, 3}}, nil, true, false},
+
+	{"embed", DiagEmbedOf{0, -2, -1, Shape{3}}, Shape{3, 3}, false, false},
+	{"embed offset", DiagEmbedOf{1, -2, -1, Shape{5, 3}}, Shape{5, 4, 4}, false, false},
+	{"embed negative offset", DiagEmbedOf{-2, -2, -1, Shape{3}}, Shape{5, 5}, false, false},
+	{"embed outer axes", DiagEmbedOf{0, 0, 2, Shape{5, 3}}, Shape{3, 5, 3}, false, false},
+	{"embed symbolic", DiagEmbedOf{0, -2, -1, Abstract{Var('b'), Var('n')}}, Abstract{Var('b'), Var('n'), Var('n')}, false, false},
+	{"embed symbolic offset", DiagEmbedOf{1, -2, -1, Abstract{Var('n')}}, Abstract{BinOp{Add, Var('n'), Size(1)}, BinOp{Add, Var('n'), Size(1)}}, false, false},
+	{"embed var", DiagEmbedOf{0, -2, -1, Var('a')}, nil, false, true},
+	{"embed scalar", DiagEmbedOf{0, -2, -1, Shape{}}, nil, true, false},
+	{"embed same axes", DiagEmbedOf{0, 1, -1, Shape{3}}, nil, true, false},
+}
+
+func TestDiagOf(t *testing.T) {
+	assert := assert.New(t)
+	for i, c := range diagOfTests {
+		got, err := c.e.resolve()
+		if c.noop {
+			if _, ok := err.(NoOpError); !ok {
+				t.Errorf("Test %q: expected a NoOpError. Got %v instead", c.name, err)
+			}
+			assert.Equal(c.e, got, "Test %q: an unresolvable expression should be returned as is", c.name)
+			continue
+		}
+		if checkErr(t, c.err, err, c.name, i) {
+			continue
+		}
+		checkShape(t, c.correct, got, c.name, i)
+	}
+}
+
+func TestDiagOf_Format(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("Diag⁽⁰ ¹⁾{1} a", fmt.Sprintf("%v", DiagOf{1, 0, 1, Var('a')}))
+	assert.Equal("Diag⁽⁻² ⁻¹⁾{-1} a", fmt.Sprintf("%v", DiagOf{-1, -2, -1, Var('a')}))
+	assert.Equal("DiagEmbed⁽⁻² ⁻¹⁾{0} (a, b)", fmt.Sprintf("%v", DiagEmbedOf{0, -2, -1, Abstract{Var('a'), Var('b')}}))
+}
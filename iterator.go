@@ -0,0 +1,193 @@
+package shapes
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Iterator walks over all the coordinates of a Shape, yielding the multidimensional coordinate and the flat offset of each element.
+// An Iterator may walk over several operands in lockstep (see NewBroadcastIterator), in which case there is one offset per operand.
+//
+// Typical use looks like this:
+//
+//	it := NewIterator(s, RowMajor)
+//	for it.Next() {
+//		coord, off := it.Coord(), it.Offset()
+//		...
+//	}
+type Iterator struct {
+	shape   Shape
+	order   DataOrder
+	strides [][]int // one set of strides per operand
+	fixed   []bool  // axes which are not walked over
+
+	coord   []int
+	offsets []int
+	started bool
+	done    bool
+}
+
+// NewIterator creates an Iterator that walks over the coordinates of s in the given order.
+// The offsets are those of a contiguous array laid out in the same order, so they will simply count up from 0.
+func NewIterator(s Shape, o DataOrder) *Iterator {
+	return newIterator(s, o, s.Strides(o))
+}
+
+// NewStridedIterator creates an Iterator that walks over the coordinates of s in the given order,
+// computing offsets with the given strides (e.g. strides from TStrides).
+func NewStridedIterator(s Shape, strides []int, o DataOrder) (*Iterator, error) {
+	if len(strides) != len(s) {
+		return nil, newDimMismatchError(dimsMismatch, len(s), len(strides), s)
+	}
+	return newIterator(s, o, strides), nil
+}
+
+// NewBroadcastIterator creates an Iterator that walks over two or more mutually broadcastable shapes in lockstep.
+// The coordinates are those of the broadcast shape.
+// There is one offset per input shape, each assuming a contiguous layout in the given order.
+// Axes that are broadcast in an input shape have a stride of 0. As with numpy, shapes of different ranks are aligned by their
+// trailing axes, e.g. (3) is broadcast as (1, 3).
+func NewBroadcastIterator(o DataOrder, shapes ...Shape) (*Iterator, error) {
+	if len(shapes) < 2 {
+		return nil, errors.Errorf("Expected at least two shapes to broadcast. Got %d", len(shapes))
+	}
+
+	// shapes of a lower rank are padded with leading axes of size 1
+	dims := 0
+	for _, s := range shapes {
+		if len(s) > dims {
+			dims = len(s)
+		}
+	}
+	padded := make([]Shape, 0, len(shapes))
+	for _, s := range shapes {
+		p := make(Shape, dims)
+		for i := range p[:dims-len(s)] {
+			p[i] = 1
+		}
+		copy(p[dims-len(s):], s)
+		padded = append(padded, p)
+	}
+
+	bc := padded[0].Clone()
+	for _, s := range padded[1:] {
+		err := AreBroadcastable(bc, s)
+		if _, ok := err.(NoOpError); !ok && err != nil {
+			return nil, errors.Wrapf(err, "Unable to create a broadcast iterator over %v", shapes)
+		}
+		// equal sizes stay, otherwise the size that is not 1 wins - including 0, as with numpy.
+		for i, d := range s {
+			if bc[i] == 1 {
+				bc[i] = d
+			}
+		}
+	}
+
+	strides := make([][]int, 0, len(shapes))
+	for _, s := range padded {
+		st := s.Strides(o)
+		for i := range st {
+			if s[i] == 1 && bc[i] != 1 {
+				st[i] = 0
+			}
+		}
+		strides = append(strides, st)
+	}
+	return newIterator(bc, o, strides...), nil
+}
+
+// NewExcludingIterator creates an Iterator that walks over the coordinates of s in the given order, except that the given axes are held at 0.
+// This is the outer loop of a reduction along the given axes.
+func NewExcludingIterator(s Shape, o DataOrder, axes ...Axis) (*Iterator, error) {
+	it := NewIterator(s, o)
+	for _, a := range axes {
+		ax := ResolveAxis(a, s)
+		if ax < 0 || int(ax) >= len(s) {
+			return nil, newInvalidAxisError(a, len(s), s)
+		}
+		it.fixed[ax] = true
+	}
+	return it, nil
+}
+
+func newIterator(s Shape, o DataOrder, strides ...[]int) *Iterator {
+	return &Iterator{
+		shape:   s,
+		order:   o,
+		strides: strides,
+		fixed:   make([]bool, len(s)),
+		coord:   make([]int, len(s)),
+		offsets: make([]int, len(strides)),
+	}
+}
+
+// Next advances the iterator. It returns false when there are no more coordinates to walk over.
+// Next must be called before the first coordinate is read.
+func (it *Iterator) Next() bool {
+	if it.done {
+		return false
+	}
+	if !it.started {
+		it.started = true
+		if it.shape.TotalSize() == 0 {
+			it.done = true
+			return false
+		}
+		return true
+	}
+
+	dims := len(it.shape)
+	for j := 0; j < dims; j++ {
+		i := j
+		if it.order != ColMajor {
+			i = dims - 1 - j
+		}
+		if it.fixed[i] {
+			continue
+		}
+
+		it.coord[i]++
+		for k := range it.offsets {
+			it.offsets[k] += it.strides[k][i]
+		}
+		if it.coord[i] < it.shape[i] {
+			return true
+		}
+
+		// carry over to the next axis
+		for k := range it.offsets {
+			it.offsets[k] -= it.coord[i] * it.strides[k][i]
+		}
+		it.coord[i] = 0
+	}
+	it.done = true
+	return false
+}
+
+// Coord returns the current coordinate. The returned slice is reused by the iterator and must not be modified.
+func (it *Iterator) Coord() []int { return it.coord }
+
+// Offset returns the flat offset of the current coordinate in the first operand.
+func (it *Iterator) Offset() int {
+	if len(it.offsets) == 0 {
+		return 0
+	}
+	return it.offsets[0]
+}
+
+// Offsets returns the flat offsets of the current coordinate in each operand. The returned slice is reused by the iterator and must not be modified.
+func (it *Iterator) Offsets() []int { return it.offsets }
+
+// Shape returns the shape that is being walked over. For a broadcast iterator this is the broadcast shape.
+func (it *Iterator) Shape() Shape { return it.shape }
+
+// Reset resets the iterator so that it may be used again.
+func (it *Iterator) Reset() {
+	for i := range it.coord {
+		it.coord[i] = 0
+	}
+	for i := range it.offsets {
+		it.offsets[i] = 0
+	}
+	it.started = false
+	it.done = false
+}
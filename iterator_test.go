@@ -0,0 +1,177 @@
+package shapes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collect walks the iterator and returns the coordinates and offsets that it yielded.
+func collect(it *Iterator) (coords [][]int, offsets [][]int) {
+	for it.Next() {
+		c := make([]int, len(it.Coord()))
+		copy(c, it.Coord())
+		o := make([]int, len(it.Offsets()))
+		copy(o, it.Offsets())
+		coords = append(coords, c)
+		offsets = append(offsets, o)
+	}
+	return
+}
+
+func TestIterator(t *testing.T) {
+	assert := assert.New(t)
+
+	// row major
+	coords, offsets := collect(NewIterator(Shape{2, 3}, RowMajor))
+	assert.Equal([][]int{{0, 0}, {0, 1}, {0, 2}, {1, 0}, {1, 1}, {1, 2}}, coords)
+	assert.Equal([][]int{{0}, {1}, {2}, {3}, {4}, {5}}, offsets)
+
+	// col major
+	coords, offsets = collect(NewIterator(Shape{2, 3}, ColMajor))
+	assert.Equal([][]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}, {1, 2}}, coords)
+	assert.Equal([][]int{{0}, {1}, {2}, {3}, {4}, {5}}, offsets)
+
+	// scalars have exactly one element
+	coords, offsets = collect(NewIterator(ScalarShape(), RowMajor))
+	assert.Equal([][]int{{}}, coords)
+	assert.Equal([][]int{{0}}, offsets)
+
+	// zero sized arrays have no elements
+	coords, _ = collect(NewIterator(Shape{2, 0, 3}, RowMajor))
+	assert.Nil(coords)
+
+	// reset
+	it := NewIterator(Shape{2, 2}, RowMajor)
+	first, _ := collect(it)
+	it.Reset()
+	second, _ := collect(it)
+	assert.Equal(first, second)
+}
+
+func TestStridedIterator(t *testing.T) {
+	assert := assert.New(t)
+	s := Shape{2, 3}
+	tShape, tStrides, err := s.TStrides(s.Strides(RowMajor), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := NewStridedIterator(tShape, tStrides, RowMajor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offsets []int
+	for it.Next() {
+		offsets = append(offsets, it.Offset())
+	}
+	assert.Equal([]int{0, 3, 1, 4, 2, 5}, offsets)
+
+	if _, err = NewStridedIterator(s, []int{1}, RowMajor); err == nil {
+		t.Errorf("Expected an error when the shape and strides have different lengths")
+	}
+}
+
+func TestBroadcastIterator(t *testing.T) {
+	assert := assert.New(t)
+	it, err := NewBroadcastIterator(RowMajor, Shape{2, 3}, Shape{1, 3}, Shape{2, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(Shape{2, 3}, it.Shape())
+	_, offsets := collect(it)
+	assert.Equal([][]int{
+		{0, 0, 0}, {1, 1, 0}, {2, 2, 0},
+		{3, 0, 1}, {4, 1, 1}, {5, 2, 1},
+	}, offsets)
+
+	// shapes of unequal rank
+	if it, err = NewBroadcastIterator(RowMajor, Shape{1, 3}, Shape{3}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(Shape{1, 3}, it.Shape())
+	_, offsets = collect(it)
+	assert.Equal([][]int{{0, 0}, {1, 1}, {2, 2}}, offsets)
+
+	if it, err = NewBroadcastIterator(RowMajor, Shape{2, 1, 3}, Shape{4, 1}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(Shape{2, 4, 3}, it.Shape())
+	_, offsets = collect(it)
+	assert.Equal(24, len(offsets))
+	assert.Equal([]int{0, 0}, offsets[0])
+	assert.Equal([]int{2, 1}, offsets[5])  // (0, 1, 2)
+	assert.Equal([]int{5, 3}, offsets[23]) // (1, 3, 2)
+
+	// zero sized axes are broadcast like any other size
+	if it, err = NewBroadcastIterator(RowMajor, Shape{0, 3}, Shape{1, 3}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(Shape{0, 3}, it.Shape())
+	coords, _ := collect(it)
+	assert.Empty(coords)
+
+	if it, err = NewBroadcastIterator(RowMajor, Shape{1, 3}, Shape{0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(Shape{0, 3}, it.Shape())
+	coords, _ = collect(it)
+	assert.Empty(coords)
+
+	if _, err = NewBroadcastIterator(RowMajor, Shape{2, 3}, Shape{2}); err == nil {
+		t.Errorf("Expected an error when the trailing axes are not broadcastable")
+	}
+	if _, err = NewBroadcastIterator(RowMajor, Shape{2, 3}, Shape{3, 3}); err == nil {
+		t.Errorf("Expected an error when the shapes are not broadcastable")
+	}
+	if _, err = NewBroadcastIterator(RowMajor, Shape{2, 3}); err == nil {
+		t.Errorf("Expected an error when only one shape is provided")
+	}
+}
+
+func TestExcludingIterator(t *testing.T) {
+	assert := assert.New(t)
+	it, err := NewExcludingIterator(Shape{2, 3, 4}, RowMajor, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coords, offsets := collect(it)
+	assert.Equal(8, len(coords))
+	for i, c := range coords {
+		assert.Equal(0, c[1])
+		assert.Equal(c[0]*12+c[2], offsets[i][0])
+	}
+
+	// negative axes
+	it, err = NewExcludingIterator(Shape{2, 3, 4}, RowMajor, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coords, _ = collect(it)
+	assert.Equal([][]int{{0, 0, 0}, {0, 1, 0}, {0, 2, 0}}, coords)
+
+	if _, err = NewExcludingIterator(Shape{2, 3}, RowMajor, 2); err == nil {
+		t.Errorf("Expected an error for an invalid axis")
+	}
+}
+
+func ExampleNewBroadcastIterator() {
+	a := Shape{2, 3}
+	b := Shape{1, 3}
+	it, err := NewBroadcastIterator(RowMajor, a, b)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for it.Next() {
+		fmt.Printf("%v: a[%d] b[%d]\n", it.Coord(), it.Offsets()[0], it.Offsets()[1])
+	}
+
+	// Output:
+	// [0 0]: a[0] b[0]
+	// [0 1]: a[1] b[1]
+	// [0 2]: a[2] b[2]
+	// [1 0]: a[3] b[0]
+	// [1 1]: a[4] b[1]
+	// [1 2]: a[5] b[2]
+}
@@ -0,0 +1,701 @@
+package shapes
+
+import (
+	"encoding/json"
+	"reflect"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// json.go describes the JSON encoding of expressions.
+//
+// Every expression is encoded as a JSON object tagged with the name of its Go type, e.g. (a, b) → (b, c) is encoded as
+//
+//	{"version":1,"type":"Arrow",
+//	 "a":{"type":"Abstract","dims":[{"type":"Var","name":"a"},{"type":"Var","name":"b"}]},
+//	 "b":{"type":"Abstract","dims":[{"type":"Var","name":"b"},{"type":"Var","name":"c"}]}}
+//
+// Only the outermost object has a version. The other fields of an object are the lower cased fields of its type:
+//
+//	Var          name
+//	Size, Axis   value
+//	Sizes, Axes  values
+//	Shape        dims (numbers)
+//	Abstract     dims (objects)
+//	Arrow        a, b
+//	Application  a, b
+//	Compound     expr, subjectTo
+//	SubjectTo    op, a, b
+//	BinOp        op, a, b
+//	UnaryOp      op, a
+//	Range        start, end, step
+//	Slices       slices (objects with start, end and step)
+//	IndexOf      i, a
+//	TransposeOf  axes, a
+//	SliceOf      slice, a
+//	ConcatOf     along, a, b
+//	RepeatOf     along, repeats, a
+//	BroadcastOf  a, b
+//	ReductOf     a, along
+//	DiagOf       offset, axis1, axis2, a
+//	DiagEmbedOf  offset, axis1, axis2, a
+//
+// Operators are encoded by name (see opNames). Any Expr can be decoded with UnmarshalExprJSON.
+
+// JSONVersion is the version of the JSON encoding of expressions.
+const JSONVersion = 1
+
+// opNames are the names of the operators in the JSON encoding.
+var opNames = map[OpType]string{
+	Const:  "const",
+	Dims:   "dims",
+	Prod:   "prod",
+	Sum:    "sum",
+	ForAll: "forall",
+	Add:    "add",
+	Sub:    "sub",
+	Mul:    "mul",
+	Div:    "div",
+	Eq:     "eq",
+	Ne:     "ne",
+	Lt:     "lt",
+	Gt:     "gt",
+	Lte:    "lte",
+	Gte:    "gte",
+	And:    "and",
+	Or:     "or",
+	Bc:     "bcast",
+}
+
+func opName(op OpType) (string, error) {
+	if name, ok := opNames[op]; ok {
+		return name, nil
+	}
+	return "", errors.Errorf("Cannot encode unknown OpType %d", byte(op))
+}
+
+func opByName(name string) (OpType, error) {
+	for op, n := range opNames {
+		if n == name {
+			return op, nil
+		}
+	}
+	return Const, errors.Errorf("Unknown operator %q", name)
+}
+
+// jsonExpr is an expression nested in another. It is encoded without a version.
+type jsonExpr struct{ substitutable }
+
+func (j jsonExpr) MarshalJSON() ([]byte, error) {
+	if j.substitutable == nil {
+		return []byte("null"), nil
+	}
+	v, err := toJSON(j.substitutable, 0)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func (j *jsonExpr) UnmarshalJSON(data []byte) (err error) {
+	j.substitutable, err = fromJSON(data)
+	return err
+}
+
+// jsonHeader is common to all the encoded expressions.
+type jsonHeader struct {
+	Version int    `json:"version,omitempty"`
+	Type    string `json:"type"`
+}
+
+type jsonVar struct {
+	jsonHeader
+	Name string `json:"name"`
+}
+
+type jsonInt struct {
+	jsonHeader
+	Value int `json:"value"`
+}
+
+type jsonInts struct {
+	jsonHeader
+	Values []int `json:"values"`
+}
+
+type jsonShape struct {
+	jsonHeader
+	Dims []int `json:"dims"`
+}
+
+type jsonAbstract struct {
+	jsonHeader
+	Dims []jsonExpr `json:"dims"`
+}
+
+type jsonBinary struct {
+	jsonHeader
+	A jsonExpr `json:"a"`
+	B jsonExpr `json:"b"`
+}
+
+type jsonCompound struct {
+	jsonHeader
+	Expr      jsonExpr `json:"expr"`
+	SubjectTo jsonExpr `json:"subjectTo"`
+}
+
+type jsonOp struct {
+	jsonHeader
+	Op string    `json:"op"`
+	A  jsonExpr  `json:"a"`
+	B  *jsonExpr `json:"b,omitempty"` // nil for a UnaryOp
+}
+
+type jsonRange struct {
+	jsonHeader
+	Start int `json:"start"`
+	End   int `json:"end"`
+	Step  int `json:"step"`
+}
+
+type jsonSlices struct {
+	jsonHeader
+	Slices []jsonRange `json:"slices"`
+}
+
+type jsonIndexOf struct {
+	jsonHeader
+	I int      `json:"i"`
+	A jsonExpr `json:"a"`
+}
+
+type jsonTransposeOf struct {
+	jsonHeader
+	Axes []int    `json:"axes"`
+	A    jsonExpr `json:"a"`
+}
+
+type jsonSliceOf struct {
+	jsonHeader
+	Slice jsonExpr `json:"slice"`
+	A     jsonExpr `json:"a"`
+}
+
+type jsonConcatOf struct {
+	jsonHeader
+	Along int      `json:"along"`
+	A     jsonExpr `json:"a"`
+	B     jsonExpr `json:"b"`
+}
+
+type jsonRepeatOf struct {
+	jsonHeader
+	Along   int      `json:"along"`
+	Repeats []int    `json:"repeats"`
+	A       jsonExpr `json:"a"`
+}
+
+type jsonReductOf struct {
+	jsonHeader
+	A     jsonExpr `json:"a"`
+	Along int      `json:"along"`
+}
+
+type jsonDiagOf struct {
+	jsonHeader
+	Offset int      `json:"offset"`
+	Axis1  int      `json:"axis1"`
+	Axis2  int      `json:"axis2"`
+	A      jsonExpr `json:"a"`
+}
+
+// toJSON converts an expression into the value that is encoded. Only the outermost expression has a version.
+func toJSON(a substitutable, version int) (interface{}, error) {
+	h := func(typ string) jsonHeader { return jsonHeader{Version: version, Type: typ} }
+	switch at := a.(type) {
+	case Var:
+		return jsonVar{h("Var"), string(rune(at))}, nil
+	case Size:
+		return jsonInt{h("Size"), int(at)}, nil
+	case Axis:
+		return jsonInt{h("Axis"), int(at)}, nil
+	case Sizes:
+		return jsonInts{h("Sizes"), sizesToInts(at)}, nil
+	case Axes:
+		return jsonInts{h("Axes"), axesToInts(at)}, nil
+	case Shape:
+		return jsonShape{h("Shape"), []int(at)}, nil
+	case Abstract:
+		var dims []jsonExpr
+		if at != nil {
+			dims = make([]jsonExpr, 0, len(at))
+		}
+		for _, d := range at {
+			dims = append(dims, jsonExpr{d.(substitutable)})
+		}
+		return jsonAbstract{h("Abstract"), dims}, nil
+	case Arrow:
+		return jsonBinary{h("Arrow"), jsonExpr{at.A}, jsonExpr{at.B}}, nil
+	case Application:
+		return jsonBinary{h("Application"), jsonExpr{at.A}, jsonExpr{at.B}}, nil
+	case Compound:
+		return jsonCompound{h("Compound"), jsonExpr{at.Expr}, jsonExpr{at.SubjectTo}}, nil
+	case SubjectTo:
+		return toJSONOp(h("SubjectTo"), at.OpType, at.A, at.B)
+	case BinOp:
+		return toJSONOp(h("BinOp"), at.Op, at.A, at.B)
+	case E2:
+		return toJSONOp(h("BinOp"), at.Op, at.A, at.B)
+	case UnaryOp:
+		return toJSONOp(h("UnaryOp"), at.Op, at.A, nil)
+	case Range:
+		return jsonRange{h("Range"), at.start, at.end, at.step}, nil
+	case *Range:
+		return toJSON(*at, version)
+	case Slices:
+		var slices []jsonRange
+		if at != nil {
+			slices = make([]jsonRange, 0, len(at))
+		}
+		for _, s := range at {
+			slices = append(slices, jsonRange{Start: s.Start(), End: s.End(), Step: s.Step()})
+		}
+		return jsonSlices{h("Slices"), slices}, nil
+	case IndexOf:
+		return jsonIndexOf{h("IndexOf"), int(at.I), jsonExpr{at.A}}, nil
+	case TransposeOf:
+		return jsonTransposeOf{h("TransposeOf"), axesToInts(at.Axes), jsonExpr{at.A}}, nil
+	case SliceOf:
+		return jsonSliceOf{h("SliceOf"), jsonExpr{at.Slice}, jsonExpr{at.A}}, nil
+	case sizelikeSliceOf:
+		return toJSON(at.SliceOf, version)
+	case ConcatOf:
+		return jsonConcatOf{h("ConcatOf"), int(at.Along), jsonExpr{at.A}, jsonExpr{at.B}}, nil
+	case RepeatOf:
+		return jsonRepeatOf{h("RepeatOf"), int(at.Along), sizesToInts(at.Repeats), jsonExpr{at.A}}, nil
+	case BroadcastOf:
+		return jsonBinary{h("BroadcastOf"), jsonExpr{at.A}, jsonExpr{at.B}}, nil
+	case ReductOf:
+		return jsonReductOf{h("ReductOf"), jsonExpr{at.A}, int(at.Along)}, nil
+	case DiagOf:
+		return jsonDiagOf{h("DiagOf"), at.Offset, int(at.Axis1), int(at.Axis2), jsonExpr{at.A}}, nil
+	case DiagEmbedOf:
+		return jsonDiagOf{h("DiagEmbedOf"), at.Offset, int(at.Axis1), int(at.Axis2), jsonExpr{at.A}}, nil
+	}
+	return nil, errors.Errorf("Cannot encode %v of %T as JSON", a, a)
+}
+
+func toJSONOp(h jsonHeader, op OpType, A, B substitutable) (interface{}, error) {
+	name, err := opName(op)
+	if err != nil {
+		return nil, err
+	}
+	retVal := jsonOp{jsonHeader: h, Op: name, A: jsonExpr{A}}
+	if B != nil {
+		retVal.B = &jsonExpr{B}
+	}
+	return retVal, nil
+}
+
+// fromJSON decodes an expression, ignoring its version.
+func fromJSON(data []byte) (substitutable, error) {
+	if string(data) == "null" {
+		return nil, nil
+	}
+	var h jsonHeader
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, err
+	}
+
+	switch h.Type {
+	case "Var":
+		var v jsonVar
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		r, size := utf8.DecodeRuneInString(v.Name)
+		if size == 0 || size != len(v.Name) {
+			return nil, errors.Errorf("Expected the name of a Var to be a single character. Got %q instead", v.Name)
+		}
+		return Var(r), nil
+	case "Size", "Axis":
+		var v jsonInt
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		if h.Type == "Size" {
+			return Size(v.Value), nil
+		}
+		return Axis(v.Value), nil
+	case "Sizes", "Axes":
+		var v jsonInts
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		if h.Type == "Sizes" {
+			return intsToSizes(v.Values), nil
+		}
+		return intsToAxes(v.Values), nil
+	case "Shape":
+		var v jsonShape
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return Shape(v.Dims), nil
+	case "Abstract":
+		var v jsonAbstract
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		var retVal Abstract
+		if v.Dims != nil {
+			retVal = make(Abstract, 0, len(v.Dims))
+		}
+		for _, d := range v.Dims {
+			sz, err := decodedSizelike(d.substitutable)
+			if err != nil {
+				return nil, err
+			}
+			retVal = append(retVal, sz)
+		}
+		return retVal, nil
+	case "Arrow", "Application", "BroadcastOf":
+		var v jsonBinary
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		A, err := decodedExpr(v.A.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		B, err := decodedExpr(v.B.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		switch h.Type {
+		case "Arrow":
+			return Arrow{A, B}, nil
+		case "Application":
+			return Application{A, B}, nil
+		}
+		return BroadcastOf{A, B}, nil
+	case "Compound":
+		var v jsonCompound
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		e, err := decodedExpr(v.Expr.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		st, ok := v.SubjectTo.substitutable.(SubjectTo)
+		if !ok {
+			return nil, errors.Errorf("Expected a SubjectTo in a Compound. Got %v of %T instead", v.SubjectTo.substitutable, v.SubjectTo.substitutable)
+		}
+		return Compound{e, st}, nil
+	case "SubjectTo", "BinOp", "UnaryOp":
+		var v jsonOp
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		op, err := opByName(v.Op)
+		if err != nil {
+			return nil, err
+		}
+		var b jsonExpr
+		if v.B != nil {
+			b = *v.B
+		}
+		if h.Type == "SubjectTo" {
+			A, err := decodedOperation(v.A.substitutable)
+			if err != nil {
+				return nil, err
+			}
+			B, err := decodedOperation(b.substitutable)
+			if err != nil {
+				return nil, err
+			}
+			return SubjectTo{op, A, B}, nil
+		}
+		A, err := decodedExpr(v.A.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		if h.Type == "UnaryOp" {
+			return UnaryOp{op, A}, nil
+		}
+		B, err := decodedExpr(b.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		return BinOp{op, A, B}, nil
+	case "Range":
+		var v jsonRange
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return Range{v.Start, v.End, v.Step}, nil
+	case "Slices":
+		var v jsonSlices
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		var retVal Slices
+		if v.Slices != nil {
+			retVal = make(Slices, 0, len(v.Slices))
+		}
+		for _, s := range v.Slices {
+			retVal = append(retVal, Range{s.Start, s.End, s.Step})
+		}
+		return retVal, nil
+	case "IndexOf":
+		var v jsonIndexOf
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		A, err := decodedExpr(v.A.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		return IndexOf{Size(v.I), A}, nil
+	case "TransposeOf":
+		var v jsonTransposeOf
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		A, err := decodedExpr(v.A.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		return TransposeOf{intsToAxes(v.Axes), A}, nil
+	case "SliceOf":
+		var v jsonSliceOf
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		sl, ok := v.Slice.substitutable.(Slicelike)
+		if !ok {
+			return nil, errors.Errorf("Expected a Range, Slices or Var to slice with. Got %v of %T instead", v.Slice.substitutable, v.Slice.substitutable)
+		}
+		A, err := decodedExpr(v.A.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		return SliceOf{sl, A}, nil
+	case "ConcatOf":
+		var v jsonConcatOf
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		A, err := decodedExpr(v.A.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		B, err := decodedExpr(v.B.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		return ConcatOf{Axis(v.Along), A, B}, nil
+	case "RepeatOf":
+		var v jsonRepeatOf
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		A, err := decodedExpr(v.A.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		return RepeatOf{Axis(v.Along), intsToSizes(v.Repeats), A}, nil
+	case "ReductOf":
+		var v jsonReductOf
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		A, err := decodedExpr(v.A.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		return ReductOf{A, Axis(v.Along)}, nil
+	case "DiagOf", "DiagEmbedOf":
+		var v jsonDiagOf
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		A, err := decodedExpr(v.A.substitutable)
+		if err != nil {
+			return nil, err
+		}
+		if h.Type == "DiagOf" {
+			return DiagOf{v.Offset, Axis(v.Axis1), Axis(v.Axis2), A}, nil
+		}
+		return DiagEmbedOf{v.Offset, Axis(v.Axis1), Axis(v.Axis2), A}, nil
+	}
+	return nil, errors.Errorf("Unknown expression type %q", h.Type)
+}
+
+// decodedExpr converts a decoded operand into an Expr. Like the parser, a BinOp operand becomes an E2.
+func decodedExpr(a substitutable) (Expr, error) {
+	switch at := a.(type) {
+	case nil:
+		return nil, nil
+	case BinOp:
+		return E2{at}, nil
+	case Expr:
+		return at, nil
+	}
+	return nil, errors.Errorf("Expected an expression. Got %v of %T instead", a, a)
+}
+
+// decodedSizelike converts a decoded dimension of an Abstract into a Sizelike.
+func decodedSizelike(a substitutable) (Sizelike, error) {
+	switch at := a.(type) {
+	case SliceOf:
+		return sizelikeSliceOf{at}, nil
+	case Sizelike:
+		return at, nil
+	}
+	return nil, errors.Errorf("Expected a dimension. Got %v of %T instead", a, a)
+}
+
+// decodedOperation converts a decoded operand of a SubjectTo into an Operation.
+func decodedOperation(a substitutable) (Operation, error) {
+	if op, ok := a.(Operation); ok {
+		return op, nil
+	}
+	return nil, errors.Errorf("Expected an operation. Got %v of %T instead", a, a)
+}
+
+// decodeInto sets the value that ptr points to to the decoded expression, which must be of the same type.
+func decodeInto(a substitutable, ptr interface{}) error {
+	v := reflect.ValueOf(ptr).Elem()
+	av := reflect.ValueOf(a)
+	if !av.IsValid() || av.Type() != v.Type() {
+		return errors.Errorf("Cannot decode %v of %T into %v", a, a, v.Type())
+	}
+	v.Set(av)
+	return nil
+}
+
+// marshalJSON encodes the outermost expression, with a version.
+func marshalJSON(a substitutable) ([]byte, error) {
+	v, err := toJSON(a, JSONVersion)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalExprJSON decodes an expression encoded by json.Marshal. It fails if the version of the encoding is unsupported.
+func UnmarshalExprJSON(data []byte) (Expr, error) {
+	a, err := unmarshalJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodedExpr(a)
+}
+
+func unmarshalJSON(data []byte) (substitutable, error) {
+	var h jsonHeader
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, errors.Wrap(err, "Unable to decode expression")
+	}
+	if h.Version < 1 || h.Version > JSONVersion {
+		return nil, errors.Errorf(unsupportedVersion, h.Version, JSONVersion)
+	}
+	a, err := fromJSON(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to decode expression")
+	}
+	return a, nil
+}
+
+// unmarshalJSONInto decodes an expression into ptr, which must point to a value of the same type as the expression.
+func unmarshalJSONInto(data []byte, ptr interface{}) error {
+	a, err := unmarshalJSON(data)
+	if err != nil {
+		return err
+	}
+	return decodeInto(a, ptr)
+}
+
+// JSONExpr wraps an Expr so that it can be decoded from JSON, e.g. as a field of a struct. A nil Expr is encoded as null.
+type JSONExpr struct {
+	Expr Expr
+}
+
+func (j JSONExpr) MarshalJSON() ([]byte, error) {
+	if j.Expr == nil {
+		return []byte("null"), nil
+	}
+	return marshalJSON(j.Expr)
+}
+
+func (j *JSONExpr) UnmarshalJSON(data []byte) (err error) {
+	if string(data) == "null" {
+		j.Expr = nil
+		return nil
+	}
+	j.Expr, err = UnmarshalExprJSON(data)
+	return err
+}
+
+/* json.Marshaler and json.Unmarshaler */
+
+func (v Var) MarshalJSON() ([]byte, error)          { return marshalJSON(v) }
+func (v *Var) UnmarshalJSON(data []byte) error      { return unmarshalJSONInto(data, v) }
+func (s Size) MarshalJSON() ([]byte, error)         { return marshalJSON(s) }
+func (s *Size) UnmarshalJSON(data []byte) error     { return unmarshalJSONInto(data, s) }
+func (s Sizes) MarshalJSON() ([]byte, error)        { return marshalJSON(s) }
+func (s *Sizes) UnmarshalJSON(data []byte) error    { return unmarshalJSONInto(data, s) }
+func (a Axis) MarshalJSON() ([]byte, error)         { return marshalJSON(a) }
+func (a *Axis) UnmarshalJSON(data []byte) error     { return unmarshalJSONInto(data, a) }
+func (a Axes) MarshalJSON() ([]byte, error)         { return marshalJSON(a) }
+func (a *Axes) UnmarshalJSON(data []byte) error     { return unmarshalJSONInto(data, a) }
+func (s Shape) MarshalJSON() ([]byte, error)        { return marshalJSON(s) }
+func (s *Shape) UnmarshalJSON(data []byte) error    { return unmarshalJSONInto(data, s) }
+func (a Abstract) MarshalJSON() ([]byte, error)     { return marshalJSON(a) }
+func (a *Abstract) UnmarshalJSON(data []byte) error { return unmarshalJSONInto(data, a) }
+func (a Arrow) MarshalJSON() ([]byte, error)        { return marshalJSON(a) }
+func (a *Arrow) UnmarshalJSON(data []byte) error    { return unmarshalJSONInto(data, a) }
+func (a Application) MarshalJSON() ([]byte, error)  { return marshalJSON(a) }
+func (a *Application) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONInto(data, a)
+}
+func (c Compound) MarshalJSON() ([]byte, error)      { return marshalJSON(c) }
+func (c *Compound) UnmarshalJSON(data []byte) error  { return unmarshalJSONInto(data, c) }
+func (s SubjectTo) MarshalJSON() ([]byte, error)     { return marshalJSON(s) }
+func (s *SubjectTo) UnmarshalJSON(data []byte) error { return unmarshalJSONInto(data, s) }
+func (op BinOp) MarshalJSON() ([]byte, error)        { return marshalJSON(op) }
+func (op *BinOp) UnmarshalJSON(data []byte) error    { return unmarshalJSONInto(data, op) }
+func (op UnaryOp) MarshalJSON() ([]byte, error)      { return marshalJSON(op) }
+func (op *UnaryOp) UnmarshalJSON(data []byte) error  { return unmarshalJSONInto(data, op) }
+func (s Range) MarshalJSON() ([]byte, error)         { return marshalJSON(s) }
+func (s *Range) UnmarshalJSON(data []byte) error     { return unmarshalJSONInto(data, s) }
+func (ss Slices) MarshalJSON() ([]byte, error)       { return marshalJSON(ss) }
+func (ss *Slices) UnmarshalJSON(data []byte) error   { return unmarshalJSONInto(data, ss) }
+func (i IndexOf) MarshalJSON() ([]byte, error)       { return marshalJSON(i) }
+func (i *IndexOf) UnmarshalJSON(data []byte) error   { return unmarshalJSONInto(data, i) }
+func (t TransposeOf) MarshalJSON() ([]byte, error)   { return marshalJSON(t) }
+func (t *TransposeOf) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONInto(data, t)
+}
+func (s SliceOf) MarshalJSON() ([]byte, error)      { return marshalJSON(s) }
+func (s *SliceOf) UnmarshalJSON(data []byte) error  { return unmarshalJSONInto(data, s) }
+func (c ConcatOf) MarshalJSON() ([]byte, error)     { return marshalJSON(c) }
+func (c *ConcatOf) UnmarshalJSON(data []byte) error { return unmarshalJSONInto(data, c) }
+func (r RepeatOf) MarshalJSON() ([]byte, error)     { return marshalJSON(r) }
+func (r *RepeatOf) UnmarshalJSON(data []byte) error { return unmarshalJSONInto(data, r) }
+func (b BroadcastOf) MarshalJSON() ([]byte, error)  { return marshalJSON(b) }
+func (b *BroadcastOf) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONInto(data, b)
+}
+func (r ReductOf) MarshalJSON() ([]byte, error)     { return marshalJSON(r) }
+func (r *ReductOf) UnmarshalJSON(data []byte) error { return unmarshalJSONInto(data, r) }
+func (d DiagOf) MarshalJSON() ([]byte, error)       { return marshalJSON(d) }
+func (d *DiagOf) UnmarshalJSON(data []byte) error   { return unmarshalJSONInto(data, d) }
+func (d DiagEmbedOf) MarshalJSON() ([]byte, error)  { return marshalJSON(d) }
+func (d *DiagEmbedOf) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONInto(data, d)
+}
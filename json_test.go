@@ -0,0 +1,105 @@
+package shapes
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var jsonCases = append([]Expr{
+	Size(2),
+	Axis(1),
+	Range{0, 2, 1},
+	Abstract{sizelikeSliceOf{SliceOf{Range{1, 5, 1}, E2{BinOp{Add, Var('a'), Var('b')}}}}},
+	Arrow{Var('a'), Abstract{Var('a'), BinOp{Div, UnaryOp{Prod, Var('a')}, Size(2)}}},
+}, roundTripCases...)
+
+func TestJSON_roundTrip(t *testing.T) {
+	assert := assert.New(t)
+	for _, e := range jsonCases {
+		b, err := json.Marshal(e)
+		if err != nil {
+			t.Errorf("Unable to marshal %v: %v", e, err)
+			continue
+		}
+		got, err := UnmarshalExprJSON(b)
+		if err != nil {
+			t.Errorf("Unable to unmarshal %v from %s: %v", e, b, err)
+			continue
+		}
+		assert.Equal(e, got, "Round trip of %v via %s", e, b)
+	}
+}
+
+func TestJSON_concrete(t *testing.T) {
+	assert := assert.New(t)
+
+	var s Shape
+	if err := json.Unmarshal([]byte(`{"version":1,"type":"Shape","dims":[2,3]}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(Shape{2, 3}, s)
+
+	var a Arrow
+	assert.NotNil(json.Unmarshal([]byte(`{"version":1,"type":"Shape","dims":[2,3]}`), &a), "Expected an error decoding a Shape into an Arrow")
+
+	type sig struct {
+		Name string
+		Expr JSONExpr
+		Out  JSONExpr
+	}
+	in := sig{"transpose", JSONExpr{Arrow{Var('a'), TransposeOf{Axes{1, 0}, Var('a')}}}, JSONExpr{}}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out sig
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(in, out)
+}
+
+var jsonErrTests = []struct {
+	name string
+	in   string
+}{
+	{"no version", `{"type":"Shape","dims":[2,3]}`},
+	{"future version", `{"version":2,"type":"Shape","dims":[2,3]}`},
+	{"unknown type", `{"version":1,"type":"Tensor"}`},
+	{"unknown op", `{"version":1,"type":"Abstract","dims":[{"type":"BinOp","op":"pow","a":{"type":"Var","name":"a"},"b":{"type":"Size","value":2}}]}`},
+	{"bad var", `{"version":1,"type":"Var","name":"ab"}`},
+	{"not a dimension", `{"version":1,"type":"Abstract","dims":[{"type":"Shape","dims":[2]}]}`},
+	{"not an expression", `{"version":1,"type":"Arrow","a":{"type":"Var","name":"a"},"b":{"type":"SubjectTo","op":"eq","a":{"type":"Size","value":1},"b":{"type":"Size","value":1}}}`},
+	{"bad json", `{"version":1,"type":"Shape","dims":[2,`},
+}
+
+func TestUnmarshalExprJSON_errors(t *testing.T) {
+	for _, c := range jsonErrTests {
+		_, err := UnmarshalExprJSON([]byte(c.in))
+		checkErr(t, true, err, c.name, c.in)
+	}
+}
+
+func ExampleUnmarshalExprJSON() {
+	transpose := Arrow{Var('a'), TransposeOf{Axes{1, 0}, Var('a')}}
+	b, err := json.Marshal(transpose)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%s\n", b)
+
+	e, err := UnmarshalExprJSON(b)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(e)
+
+	// Output:
+	// {"version":1,"type":"Arrow","a":{"type":"Var","name":"a"},"b":{"type":"TransposeOf","axes":[1,0],"a":{"type":"Var","name":"a"}}}
+	// a → T⁽¹ ⁰⁾ a
+}
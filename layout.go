@@ -0,0 +1,175 @@
+package shapes
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// layout.go describes how the elements of a multidimensional array with a given Shape are laid out in a flat slice of memory.
+// All strides and offsets are measured in number of elements, not bytes.
+
+// DataOrder is the order in which the elements of a multidimensional array are laid out in memory.
+type DataOrder byte
+
+const (
+	// RowMajor is the C-like order: the last axis varies the fastest.
+	RowMajor DataOrder = iota
+	// ColMajor is the Fortran-like order: the first axis varies the fastest.
+	ColMajor
+)
+
+// String returns the name of the order.
+func (o DataOrder) String() string {
+	switch o {
+	case RowMajor:
+		return "RowMajor"
+	case ColMajor:
+		return "ColMajor"
+	}
+	return fmt.Sprintf("UNKNOWN DATAORDER %d", byte(o))
+}
+
+// Strides returns the strides of a contiguous array of shape s laid out in the given order.
+// A scalar shape has no strides.
+func (s Shape) Strides(o DataOrder) []int {
+	if s.IsScalar() {
+		return nil
+	}
+	retVal := make([]int, len(s))
+	acc := 1
+	switch o {
+	case ColMajor:
+		for i := range s {
+			retVal[i] = acc
+			acc *= s[i]
+		}
+	default:
+		for i := len(s) - 1; i >= 0; i-- {
+			retVal[i] = acc
+			acc *= s[i]
+		}
+	}
+	return retVal
+}
+
+// TStrides is the layout-aware version of T. Instead of describing a copy, it returns the permuted shape along with the permuted strides,
+// which together describe the transposed view of the same memory.
+//
+// Like T, a NoOpError is returned if the permutation does nothing.
+func (s Shape) TStrides(strides []int, axes ...Axis) (newShape Shape, newStrides []int, err error) {
+	if len(strides) != len(s) {
+		return nil, nil, newDimMismatchError(dimsMismatch, len(s), len(strides), s)
+	}
+	newShape = s.Clone()
+	newStrides = make([]int, len(strides))
+	copy(newStrides, strides)
+	err = UnsafePermute(axesToInts(axes), []int(newShape), newStrides)
+	return
+}
+
+// IsContiguous returns true if the strides describe an array of the given shape that occupies a contiguous block of memory,
+// in either RowMajor or ColMajor order. Axes of size 1 do not affect contiguity.
+func IsContiguous(shape Shape, strides []int) bool {
+	return isContiguousIn(shape, strides, RowMajor) || isContiguousIn(shape, strides, ColMajor)
+}
+
+// isContiguousIn checks that the strides describe a contiguous array of the given shape, laid out in the given order.
+func isContiguousIn(shape Shape, strides []int, o DataOrder) bool {
+	if len(shape) != len(strides) {
+		return false
+	}
+	if shape.TotalSize() == 0 {
+		return true // nothing is laid out, so there are no gaps.
+	}
+
+	acc := 1
+	check := func(i int) bool {
+		if shape[i] == 1 {
+			return true
+		}
+		if strides[i] != acc {
+			return false
+		}
+		acc *= shape[i]
+		return true
+	}
+
+	switch o {
+	case ColMajor:
+		for i := 0; i < len(shape); i++ {
+			if !check(i) {
+				return false
+			}
+		}
+	default:
+		for i := len(shape) - 1; i >= 0; i-- {
+			if !check(i) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Offset returns the flat offset of the element at the given multidimensional index, given the strides.
+//
+// Offset does not perform any bounds checking of the index. See Shape.Ravel for that.
+func Offset(index, strides []int) (int, error) {
+	if len(index) != len(strides) {
+		return -1, newDimMismatchError(dimsMismatch, len(strides), len(index), nil)
+	}
+	var retVal int
+	for i := range index {
+		retVal += index[i] * strides[i]
+	}
+	return retVal, nil
+}
+
+// Ravel returns the flat index of the element at the given coordinates of an array of shape s laid out in RowMajor order.
+// Like Dim, negative coordinates count from the end of the axis.
+func (s Shape) Ravel(coords ...int) (int, error) {
+	if len(coords) != len(s) {
+		return -1, newDimMismatchError(dimsMismatch, len(s), len(coords), s)
+	}
+	var retVal int
+	for i, c := range coords {
+		size := s[i]
+		if c < 0 {
+			c += size
+		}
+		if c < 0 || c >= size {
+			return -1, errors.Errorf(indexOutOfBounds, coords[i], i, size)
+		}
+		retVal = retVal*size + c
+	}
+	return retVal, nil
+}
+
+// Unravel returns the coordinates of the element at the flat index i of an array of shape s laid out in RowMajor order.
+// A negative i counts from the end of the array.
+func (s Shape) Unravel(i int) ([]int, error) {
+	return s.UnravelTo(make([]int, len(s)), i)
+}
+
+// UnravelTo is the allocation-free version of Unravel. The coordinates are written into dst, which must have a capacity of at least s.Dims().
+func (s Shape) UnravelTo(dst []int, i int) ([]int, error) {
+	if cap(dst) < len(s) {
+		return nil, errors.Errorf("Unable to unravel into a slice with capacity %d. Expected at least %d.", cap(dst), len(s))
+	}
+	dst = dst[:len(s)]
+
+	total := s.TotalSize()
+	idx := i
+	if idx < 0 {
+		idx += total
+	}
+	if idx < 0 || idx >= total {
+		return nil, errors.Errorf(flatIndexOutOfBounds, i, total)
+	}
+	for d := len(s) - 1; d >= 0; d-- {
+		dst[d] = idx % s[d]
+		idx /= s[d]
+	}
+	return dst, nil
+}
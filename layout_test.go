@@ -0,0 +1,184 @@
+package shapes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var stridesTests = []struct {
+	s       Shape
+	o       DataOrder
+	correct []int
+}{
+	{Shape{}, RowMajor, nil},
+	{Shape{5}, RowMajor, []int{1}},
+	{Shape{2, 3, 4}, RowMajor, []int{12, 4, 1}},
+	{Shape{2, 3, 4}, ColMajor, []int{1, 2, 6}},
+	{Shape{2, 1, 4}, RowMajor, []int{4, 4, 1}},
+}
+
+func TestShape_Strides(t *testing.T) {
+	for _, c := range stridesTests {
+		assert.Equal(t, c.correct, c.s.Strides(c.o), "Strides of %v in %v", c.s, c.o)
+	}
+}
+
+var isContiguousTests = []struct {
+	s       Shape
+	strides []int
+	correct bool
+}{
+	{Shape{}, nil, true},
+	{Shape{2, 3}, []int{3, 1}, true},
+	{Shape{2, 3}, []int{1, 2}, true},
+	{Shape{2, 3}, []int{1, 3}, false}, // transposed row major
+	{Shape{2, 3}, []int{6, 2}, false}, // stepped
+	{Shape{2, 1, 3}, []int{3, 100, 1}, true},
+	{Shape{2, 3}, []int{0, 1}, false}, // broadcast
+	{Shape{0, 3}, []int{0, 0}, true},
+	{Shape{2, 3}, []int{1}, false},
+}
+
+func TestIsContiguous(t *testing.T) {
+	for _, c := range isContiguousTests {
+		assert.Equal(t, c.correct, IsContiguous(c.s, c.strides), "IsContiguous(%v, %v)", c.s, c.strides)
+	}
+}
+
+func TestOffset(t *testing.T) {
+	assert := assert.New(t)
+	off, err := Offset([]int{1, 2, 3}, Shape{2, 3, 4}.Strides(RowMajor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(1*12+2*4+3, off)
+
+	if _, err = Offset([]int{1, 2}, []int{1}); err == nil {
+		t.Errorf("Expected an error when the index and strides have different lengths")
+	}
+}
+
+func TestShape_TStrides(t *testing.T) {
+	assert := assert.New(t)
+	s := Shape{2, 3, 4}
+	strides := s.Strides(RowMajor)
+
+	newShape, newStrides, err := s.TStrides(strides, 2, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	correct, _ := s.T(2, 0, 1)
+	assert.Equal(correct, newShape)
+	assert.Equal([]int{1, 12, 4}, newStrides)
+	assert.Equal(Shape{2, 3, 4}, s, "s should not have been mutated")
+	assert.Equal([]int{12, 4, 1}, strides, "strides should not have been mutated")
+	assert.False(IsContiguous(newShape, newStrides))
+
+	// transposing a matrix yields a ColMajor layout
+	m := Shape{2, 3}
+	mT, mTStrides, err := m.TStrides(m.Strides(RowMajor), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(mT.Strides(ColMajor), mTStrides)
+
+	if _, _, err = s.TStrides(strides, 0, 1, 2); err == nil {
+		t.Errorf("Expected a NoOpError")
+	} else if _, ok := err.(NoOpError); !ok {
+		t.Errorf("Expected a NoOpError. Got %v instead", err)
+	}
+
+	if _, _, err = s.TStrides([]int{1}, 1, 0, 2); err == nil {
+		t.Errorf("Expected an error when the shape and strides have different lengths")
+	}
+}
+
+func ExampleShape_Strides() {
+	s := Shape{2, 3, 4}
+	fmt.Printf("RowMajor strides of %v: %v\n", s, s.Strides(RowMajor))
+	fmt.Printf("ColMajor strides of %v: %v\n", s, s.Strides(ColMajor))
+
+	// Output:
+	// RowMajor strides of (2, 3, 4): [12 4 1]
+	// ColMajor strides of (2, 3, 4): [1 2 6]
+}
+
+var ravelTests = []struct {
+	s      Shape
+	coords []int
+	flat   int
+	err    bool
+}{
+	{Shape{}, nil, 0, false},
+	{Shape{5}, []int{3}, 3, false},
+	{Shape{2, 3, 4}, []int{1, 2, 3}, 23, false},
+	{Shape{2, 3, 4}, []int{0, 0, 0}, 0, false},
+	{Shape{2, 3, 4}, []int{-1, -1, -1}, 23, false},
+	{Shape{2, 3, 4}, []int{1, -3, 0}, 12, false},
+	{Shape{2, 3, 4}, []int{2, 0, 0}, -1, true},
+	{Shape{2, 3, 4}, []int{0, -4, 0}, -1, true},
+	{Shape{2, 3, 4}, []int{0, 0}, -1, true},
+}
+
+func TestShape_Ravel(t *testing.T) {
+	for i, c := range ravelTests {
+		flat, err := c.s.Ravel(c.coords...)
+		if checkErr(t, c.err, err, "Ravel", i) {
+			continue
+		}
+		if flat != c.flat {
+			t.Errorf("Expected %v.Ravel(%v) to be %d. Got %d instead", c.s, c.coords, c.flat, flat)
+		}
+	}
+}
+
+func TestShape_Unravel(t *testing.T) {
+	assert := assert.New(t)
+	s := Shape{2, 3, 4}
+	for i := 0; i < s.TotalSize(); i++ {
+		coords, err := s.Unravel(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		flat, err := s.Ravel(coords...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(i, flat, "Round trip of %d via %v", i, coords)
+	}
+
+	coords, err := s.Unravel(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{1, 2, 3}, coords)
+
+	coords, err = ScalarShape().Unravel(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{}, coords)
+
+	if _, err = s.Unravel(24); err == nil {
+		t.Errorf("Expected an error when unravelling an out of bounds index")
+	}
+	if _, err = s.Unravel(-25); err == nil {
+		t.Errorf("Expected an error when unravelling an out of bounds index")
+	}
+
+	// allocation free
+	buf := make([]int, 0, 3)
+	if coords, err = s.UnravelTo(buf, 13); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{1, 0, 1}, coords)
+	assert.Equal(&buf[:1][0], &coords[0], "Expected UnravelTo to reuse the buffer")
+	allocs := testing.AllocsPerRun(100, func() { s.UnravelTo(buf, 13) })
+	assert.Equal(0.0, allocs)
+
+	if _, err = s.UnravelTo(make([]int, 2), 0); err == nil {
+		t.Errorf("Expected an error when the buffer is too small")
+	}
+}
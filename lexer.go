@@ -0,0 +1,559 @@
+package shapes
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+type tokentype int
+
+const (
+	eos tokentype = iota
+	parenL
+	parenR
+	brackL
+	brackR
+	axesL // use brackR for closing
+	braceL
+	braceR
+	digit
+	letter
+	comma
+	arrow
+	colon
+	pipe
+	unop
+	binop
+	cmpop
+	logop
+	transposeop
+	sup    // a lone superscript number, e.g. the axis of Repeat⁰
+	reduct // '/' followed by a superscript axis. The value is the axis.
+	kwSizes
+	kwRepeat
+	kwDiag
+	kwDiagEmbed
+	appop
+	kwWhere // s.t. or where
+	ref     // a reference to a signature, e.g. $matmul. The value is the index of the name in lexer.names.
+)
+
+type tok struct {
+	t tokentype // type
+	v int       // value of the token. It is a rune, except for digits (and the like), whose value is the number
+	l int       // location
+}
+
+func (t tok) Format(s fmt.State, c rune) {
+	switch t.t {
+	case letter:
+		fmt.Fprintf(s, "{%c %d}", t.v, t.l)
+	case digit, sup, reduct, ref:
+		fmt.Fprintf(s, "{%d %d}", t.v, t.l)
+	case eos:
+		fmt.Fprintf(s, "{EOS %d}", t.l)
+	default:
+		fmt.Fprintf(s, "{%c %d}", t.v, t.l)
+	}
+}
+
+// maxInt is math.MaxInt, which needs Go 1.17.
+const maxInt = int(^uint(0) >> 1)
+
+// keywords are the words that the lexer recognizes. Longer words that share a prefix with shorter words come first.
+// The keywords are all ASCII, so the length of a word is also its length in runes.
+var keywords = []struct {
+	word string
+	t    tokentype
+	v    rune
+}{
+	{"DiagEmbed", kwDiagEmbed, 'D'},
+	{"Repeat", kwRepeat, 'R'},
+	{"where", kwWhere, 'W'},
+	{"forall", unop, '∀'},
+	{"bcast", cmpop, '⚟'},
+	{"Diag", kwDiag, 'D'},
+	{"Sz", kwSizes, 'S'},
+	{"D", unop, 'D'},
+	{"K", unop, 'K'},
+	{"P", unop, 'Π'},
+	{"S", unop, 'Σ'},
+	{"T", transposeop, 'T'},
+	{"X", axesL, 'X'},
+}
+
+// lexer turns a stream of runes into tokens.
+//
+// The runes are read from the io.RuneReader as they are needed. The runes that have been read are kept,
+// so that errors can show the input. All the buffers of a lexer are reused when it is reset, so a lexer that is reused
+// does not allocate in the steady state.
+type lexer struct {
+	r   io.RuneReader
+	src []rune // the runes that have been read so far
+	eof bool   // true if r has been exhausted
+	err error  // the error from r, if it's not io.EOF
+
+	// input is the input as a string, if known. Otherwise it is built from src when an error is reported.
+	input    string
+	hasInput bool
+
+	toks  []tok    // the tokens that have been lexed
+	names []string // names of the signatures referenced by ref tokens
+}
+
+// reset prepares the lexer to read from r, reusing its buffers.
+func (l *lexer) reset(r io.RuneReader) {
+	l.r = r
+	l.src = l.src[:0]
+	l.eof = false
+	l.err = nil
+	l.input = ""
+	l.hasInput = false
+	l.toks = l.toks[:0]
+	for i := range l.names {
+		l.names[i] = ""
+	}
+	l.names = l.names[:0]
+}
+
+// source returns the input, for use in errors.
+func (l *lexer) source() string {
+	if l.hasInput {
+		return l.input
+	}
+	return string(l.src)
+}
+
+// at returns the rune at position i of the input, reading from the underlying reader if required.
+// ok is false if the input ends before i.
+func (l *lexer) at(i int) (r rune, ok bool) {
+	for i >= len(l.src) && !l.eof {
+		r, _, err := l.r.ReadRune()
+		if err != nil {
+			l.eof = true
+			if err != io.EOF {
+				l.err = err
+			}
+			break
+		}
+		l.src = append(l.src, r)
+	}
+	if i < len(l.src) {
+		return l.src[i], true
+	}
+	return 0, false
+}
+
+// is returns true if the rune at position i is r.
+func (l *lexer) is(i int, r rune) bool {
+	r2, ok := l.at(i)
+	return ok && r2 == r
+}
+
+// hasPrefix returns true if the input at position i starts with prefix.
+func (l *lexer) hasPrefix(i int, prefix string) bool {
+	for _, r := range prefix {
+		if !l.is(i, r) {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+func (l *lexer) emit(t tokentype, v rune, pos int) { l.toks = append(l.toks, tok{t, int(v), pos}) }
+
+// emitInt emits a token whose value is a number.
+func (l *lexer) emitInt(t tokentype, v int, pos int) { l.toks = append(l.toks, tok{t, v, pos}) }
+
+// errAt creates a ParseError at position pos of the input.
+func (l *lexer) errAt(pos int, found string, expected []string, msg string) *ParseError {
+	return &ParseError{Input: l.source(), Pos: pos, Found: found, Expected: expected, Msg: msg}
+}
+
+// eosErr creates a ParseError for an input that ends unexpectedly.
+func (l *lexer) eosErr(expected ...string) *ParseError {
+	return l.errAt(len(l.src), "EOF", expected, "Unexpected end of input")
+}
+
+// isIdentRune returns true if r may be part of a signature name.
+func isIdentRune(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+// supValue returns the value of a superscript digit, or -1 if r is not one.
+func supValue(r rune) int {
+	for i, d := range supdigits {
+		if d == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// lexSup lexes a run of superscript runes starting at i. A run may start with '⁻'. The run '⁼' is AllAxes.
+// It returns the value and the index of the last rune of the run. ok is false if there is no superscript number at i.
+func (l *lexer) lexSup(i int) (val, last int, ok bool) {
+	if l.is(i, '⁼') {
+		return int(AllAxes), i, true
+	}
+	neg := l.is(i, '⁻')
+	j := i
+	if neg {
+		j++
+	}
+	start := j
+	for ; ; j++ {
+		r, ok := l.at(j)
+		if !ok || supValue(r) < 0 {
+			break
+		}
+		val = val*10 + supValue(r)
+	}
+	if j == start {
+		return 0, i, false
+	}
+	if neg {
+		val = -val
+	}
+	return val, j - 1, true
+}
+
+// lexCaret lexes the ASCII form of a superscript number, which is ^n, ^-n or ^= (for AllAxes). i is the index of the '^'.
+func (l *lexer) lexCaret(i int) (val, last int, ok bool) {
+	j := i + 1
+	if l.is(j, '=') {
+		return int(AllAxes), j, true
+	}
+	neg := l.is(j, '-')
+	if neg {
+		j++
+	}
+	start := j
+	for ; ; j++ {
+		r, ok := l.at(j)
+		if !ok || !unicode.IsDigit(r) {
+			break
+		}
+		val = val*10 + int(r-'0')
+	}
+	if j == start {
+		return 0, i, false
+	}
+	if neg {
+		val = -val
+	}
+	return val, j - 1, true
+}
+
+// lex is a function that takes a string and returns a slice of tokens.
+func lex(a string) (retVal []tok, err error) {
+	var l lexer
+	l.reset(strings.NewReader(a))
+	l.input, l.hasInput = a, true
+	// there are never more runes or tokens than bytes in the input, so the buffers do not need to grow
+	l.src = make([]rune, 0, len(a))
+	l.toks = make([]tok, 0, len(a))
+	return l.lex()
+}
+
+// lex lexes the entire input. The returned slice is owned by the lexer, and is only valid until the lexer is reset.
+//
+// it's fundamentally a giant state table in a for loop. Since the grammar of the language is very strict, it is a fairly straight forwards parse.
+func (l *lexer) lex() (retVal []tok, err error) {
+	for i := 0; ; i++ {
+		r, ok := l.at(i)
+		if !ok {
+			break
+		}
+		switch {
+		case r == '(':
+			l.emit(parenL, r, i)
+		case r == ')':
+			l.emit(parenR, r, i)
+		case r == '[':
+			l.emit(brackL, r, i)
+		case r == ']':
+			l.emit(brackR, r, i)
+		case r == '{':
+			l.emit(braceL, r, i)
+		case r == '}':
+			l.emit(braceR, r, i)
+		case r == '→':
+			l.emit(arrow, r, i)
+		case r == '@':
+			l.emit(appop, r, i)
+		case r == '$':
+			j := i + 1
+			for {
+				r2, ok := l.at(j)
+				if !ok || !isIdentRune(r2) {
+					break
+				}
+				j++
+			}
+			if j == i+1 {
+				return nil, l.errAt(i, "'$'", []string{"signature name"}, "Expected a signature name after '$'")
+			}
+			l.names = append(l.names, string(l.src[i+1:j]))
+			l.emitInt(ref, len(l.names)-1, i)
+			i = j - 1
+		case r == '-':
+			r2, ok := l.at(i + 1)
+			if !ok {
+				return nil, l.eosErr("'>'", "expression")
+			}
+			if r2 == '>' {
+				i++
+				l.emit(arrow, '→', i)
+				continue
+			}
+			l.emit(binop, r, i)
+		case r == '/', r == '∕':
+			// a '/' followed by an axis is a reduction. Otherwise it's a division.
+			var val, last int
+			var ok bool
+			if l.is(i+1, '^') {
+				val, last, ok = l.lexCaret(i + 1)
+			} else {
+				val, last, ok = l.lexSup(i + 1)
+			}
+			if ok {
+				l.emitInt(reduct, val, i)
+				i = last
+				continue
+			}
+			l.emit(binop, '÷', i)
+		case r == '+', r == '*', r == '×', r == '÷':
+			rr := r
+			if r == '*' {
+				rr = '×'
+			}
+			l.emit(binop, rr, i)
+		case r == '=', r == '≠', r == '≥', r == '≤', r == '≥', r == '≤', r == '⚟': // single symbol cmp op (note there are TWO acceptable unicode symbols for gte and lte)
+			l.emit(cmpop, r, i)
+		case r == '!':
+			r2, ok := l.at(i + 1)
+			if !ok {
+				return nil, l.eosErr("'='")
+			}
+			if r2 == '=' {
+				i++
+				l.emit(cmpop, '≠', i)
+			}
+		case r == '>', r == '<':
+			r2, ok := l.at(i + 1)
+			if !ok {
+				return nil, l.eosErr("'='", "expression")
+			}
+			if r2 == '=' {
+				i++
+				var rr rune
+				switch r {
+				case '>':
+					rr = '≥'
+				case '<':
+					rr = '≤'
+				}
+				l.emit(cmpop, rr, i)
+				continue
+			}
+			l.emit(cmpop, r, i)
+		case r == '∧', r == '∨', r == '⋀', r == '⋁': // single symbol logical op
+			rr := r
+			if r == '⋀' {
+				rr = '∧'
+			}
+			if r == '⋁' {
+				rr = '∨'
+			}
+			l.emit(logop, rr, i)
+		case r == '&':
+			r2, ok := l.at(i + 1)
+			if !ok {
+				return nil, l.eosErr("'&'")
+			}
+			if r2 == '&' { // for people who think AND is written "&&"
+				i++
+				l.emit(logop, '∧', i)
+				continue
+			}
+		case r == '|':
+			r2, ok := l.at(i + 1)
+			if !ok {
+				return nil, l.eosErr("'|'", "expression")
+			}
+			if r2 == '|' { // for people who think OR is written "||"
+				i++
+				l.emit(logop, '∨', i)
+				continue
+			}
+			l.emit(pipe, r, i)
+		case r == 'Π', r == 'Σ', r == '∀':
+			l.emit(unop, r, i)
+		case r == '⁽':
+			// ⁽¹ ⁰⁾ is the superscript form of X[1 0]
+			l.emit(axesL, 'X', i)
+			l.emit(brackL, '[', i)
+			for i++; ; i++ {
+				r2, ok := l.at(i)
+				if !ok {
+					return nil, l.eosErr("'⁾'")
+				}
+				if r2 == '⁾' {
+					break
+				}
+				if r2 == ' ' {
+					continue
+				}
+				val, last, ok := l.lexSup(i)
+				if !ok {
+					return nil, l.errAt(i, fmt.Sprintf("%q", r2), []string{"superscript number", "'⁾'"}, "Unexpected rune in superscript axes")
+				}
+				i = last
+				l.emitInt(digit, val, i)
+			}
+			l.emit(brackR, ']', i)
+		case r == '^':
+			val, last, ok := l.lexCaret(i)
+			if !ok {
+				return nil, l.errAt(i, "'^'", []string{"number"}, "Expected a number after '^'")
+			}
+			l.emitInt(sup, val, i)
+			i = last
+		case supValue(r) >= 0, r == '⁻', r == '⁼':
+			val, last, ok := l.lexSup(i)
+			if !ok {
+				return nil, l.errAt(i, fmt.Sprintf("%q", r), []string{"superscript number"}, "Dangling superscript sign")
+			}
+			l.emitInt(sup, val, i)
+			i = last
+		case r == ',':
+			l.emit(comma, r, i)
+		case r == ':':
+			l.emit(colon, r, i)
+		case r == 's' && l.hasPrefix(i+1, ".t."):
+			l.emit(kwWhere, 'W', i)
+			i += 3
+		case unicode.IsSpace(r):
+			continue // we ignore spaces and delimiters
+		case unicode.IsDigit(r):
+			start := i
+			var num int
+			var overflow bool
+			for {
+				r2, ok := l.at(i)
+				if !ok || !unicode.IsDigit(r2) {
+					break
+				}
+				d := int(r2 - '0')
+				if num > (maxInt-d)/10 {
+					overflow = true
+				}
+				num = num*10 + d
+				i++
+			}
+			i--
+			if overflow {
+				s := string(l.src[start : i+1])
+				err := &strconv.NumError{Func: "Atoi", Num: s, Err: strconv.ErrRange}
+				retVal := l.errAt(start, fmt.Sprintf("%q", s), nil, "Unable to parse number")
+				retVal.Err = err
+				return nil, retVal
+			}
+			l.emitInt(digit, num, i)
+		case unicode.IsLetter(r):
+			// a run of letters is split into keywords. Whatever is left must be a single letter variable.
+			j := i
+			for {
+				r2, ok := l.at(j)
+				if !ok || !unicode.IsLetter(r2) {
+					break
+				}
+				j++
+			}
+			k := i
+		words:
+			for k < j {
+				for _, kw := range keywords {
+					if n := len(kw.word); k+n <= j && rune(kw.word[0]) == l.src[k] && l.hasPrefix(k, kw.word) {
+						l.emit(kw.t, kw.v, k)
+						k += n
+						continue words
+					}
+				}
+				break
+			}
+			switch j - k {
+			case 0:
+			case 1:
+				l.emit(letter, l.src[k], k)
+			default:
+				return nil, l.errAt(k+1, fmt.Sprintf("%q", l.src[k+1]), nil, "Only single letters are allowed as variables")
+			}
+			i = j - 1
+		}
+	}
+	if l.err != nil {
+		return nil, errors.Wrap(l.err, "Unable to read the input")
+	}
+	l.keywordVars()
+	return l.toks, nil
+}
+
+// keywordVars turns the keywords that are a single letter (e.g. S and T) back into variables where they are not in operator
+// position, i.e. where they are not followed by an operand. Thus S a is Σ a, while (S) is the variable S.
+//
+// A '[' that follows a prefix operator is taken to start the indexing or slicing of a variable, e.g. S[0]. X[...] is always axes,
+// so a variable named X cannot be indexed or sliced directly.
+//
+// The tokens are looked at from last to first, so that the token that follows has already been decided.
+func (l *lexer) keywordVars() {
+	for i := len(l.toks) - 1; i >= 0; i-- {
+		t := l.toks[i]
+		switch t.t {
+		case unop, transposeop, axesL:
+		default:
+			continue
+		}
+		if !isKeywordLetter(l.src[t.l]) {
+			continue
+		}
+		next := eos
+		if i+1 < len(l.toks) {
+			next = l.toks[i+1].t
+		}
+		var isOp bool
+		switch t.t {
+		case unop, transposeop:
+			isOp = startsOperand(next) && next != brackL
+		case axesL:
+			isOp = startsOperand(next)
+		}
+		if !isOp {
+			l.toks[i] = tok{letter, int(l.src[t.l]), t.l}
+		}
+	}
+}
+
+// isKeywordLetter returns true if r is a keyword by itself, e.g. T. It must be kept in sync with keywords.
+func isKeywordLetter(r rune) bool {
+	switch r {
+	case 'D', 'K', 'P', 'S', 'T', 'X':
+		return true
+	}
+	return false
+}
+
+// startsOperand returns true if a token of type t may start the operand of a prefix operator.
+func startsOperand(t tokentype) bool {
+	switch t {
+	case parenL, brackL, axesL, braceL, digit, letter, ref,
+		unop, transposeop, reduct, kwSizes, kwRepeat, kwDiag, kwDiagEmbed:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package shapes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestIsKeywordLetter(t *testing.T) {
+	for _, kw := range keywords {
+		if len(kw.word) == 1 && !isKeywordLetter(rune(kw.word[0])) {
+			t.Errorf("Expected %q to be a keyword letter", kw.word)
+		}
+	}
+	for _, r := range "abcxyzABCEQRWZΠΣ" {
+		if isKeywordLetter(r) {
+			t.Errorf("Expected %q not to be a keyword letter", r)
+		}
+	}
+}
+
+// BenchmarkLex_oneShot lexes each input with a fresh lexer.
+func BenchmarkLex_oneShot(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, s := range benchSignatures {
+			if _, err := lex(s); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkLex_runes is the baseline for BenchmarkLex and BenchmarkLex_oneShot. It lexes with lexRunes.
+func BenchmarkLex_runes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, s := range benchSignatures {
+			if _, err := lexRunes(s); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// lexRunes is the lexer as it was before it read from an io.RuneReader. It converts the whole input to a []rune up front,
+// and allocates a new slice of tokens for every input. It is kept only as the baseline for the lexer benchmarks.
+func lexRunes(a string) (retVal []tok, err error) {
+	rs := []rune(a)
+	eosErr := func(expected ...string) error {
+		return &ParseError{Input: a, Pos: len(rs), Found: "EOF", Expected: expected, Msg: "Unexpected end of input"}
+	}
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '(':
+			retVal = append(retVal, tok{parenL, int(r), i})
+		case r == ')':
+			retVal = append(retVal, tok{parenR, int(r), i})
+		case r == '[':
+			retVal = append(retVal, tok{brackL, int(r), i})
+		case r == ']':
+			retVal = append(retVal, tok{brackR, int(r), i})
+		case r == '{':
+			retVal = append(retVal, tok{braceL, int(r), i})
+		case r == '}':
+			retVal = append(retVal, tok{braceR, int(r), i})
+		case r == '→':
+			retVal = append(retVal, tok{arrow, int(r), i})
+		case r == '@':
+			retVal = append(retVal, tok{appop, int(r), i})
+		case r == '$':
+			j := i + 1
+			for j < len(rs) && isIdentRune(rs[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, &ParseError{Input: a, Pos: i, Found: "'$'", Expected: []string{"signature name"}, Msg: "Expected a signature name after '$'"}
+			}
+			retVal = append(retVal, tok{ref, int(r), i})
+			i = j - 1
+		case r == '-':
+			if i+1 >= len(rs) {
+				return nil, eosErr("'>'", "expression")
+			}
+			if rs[i+1] == '>' {
+				i++
+				retVal = append(retVal, tok{arrow, '→', i})
+				continue
+			}
+			retVal = append(retVal, tok{binop, int(r), i})
+		case r == '/', r == '∕':
+			// a '/' followed by an axis is a reduction. Otherwise it's a division.
+			if i+1 < len(rs) {
+				var val, last int
+				var ok bool
+				if rs[i+1] == '^' {
+					val, last, ok = lexCaretRunes(rs, i+1)
+				} else {
+					val, last, ok = lexSupRunes(rs, i+1)
+				}
+				if ok {
+					retVal = append(retVal, tok{reduct, val, i})
+					i = last
+					continue
+				}
+			}
+			retVal = append(retVal, tok{binop, '÷', i})
+		case r == '+', r == '*', r == '×', r == '÷':
+			rr := r
+			if r == '*' {
+				rr = '×'
+			}
+			retVal = append(retVal, tok{binop, int(rr), i})
+		case r == '=', r == '≠', r == '≥', r == '≤', r == '≥', r == '≤', r == '⚟': // single symbol cmp op (note there are TWO acceptable unicode symbols for gte and lte)
+			retVal = append(retVal, tok{cmpop, int(r), i})
+		case r == '!':
+			if i+1 >= len(rs) {
+				return nil, eosErr("'='")
+			}
+			if rs[i+1] == '=' {
+				i++
+				retVal = append(retVal, tok{cmpop, '≠', i})
+			}
+		case r == '>', r == '<':
+			if i+1 >= len(rs) {
+				return nil, eosErr("'='", "expression")
+			}
+			if rs[i+1] == '=' {
+				i++
+				var rr rune
+				switch r {
+				case '>':
+					rr = '≥'
+				case '<':
+					rr = '≤'
+				}
+				retVal = append(retVal, tok{cmpop, int(rr), i})
+				continue
+			}
+			retVal = append(retVal, tok{cmpop, int(r), i})
+		case r == '∧', r == '∨', r == '⋀', r == '⋁': // single symbol logical op
+			rr := r
+			if r == '⋀' {
+				rr = '∧'
+			}
+			if r == '⋁' {
+				rr = '∨'
+			}
+			retVal = append(retVal, tok{logop, int(rr), i})
+		case r == '&':
+			if i+1 >= len(rs) {
+				return nil, eosErr("'&'")
+			}
+			if rs[i+1] == '&' { // for people who think AND is written "&&"
+				i++
+				retVal = append(retVal, tok{logop, '∧', i})
+				continue
+			}
+		case r == '|':
+			if i+1 >= len(rs) {
+				return nil, eosErr("'|'", "expression")
+			}
+			if rs[i+1] == '|' { // for people who think OR is written "||"
+				i++
+				retVal = append(retVal, tok{logop, '∨', i})
+				continue
+			}
+			retVal = append(retVal, tok{pipe, int(r), i})
+		case r == 'Π', r == 'Σ', r == '∀':
+			retVal = append(retVal, tok{unop, int(r), i})
+		case r == '⁽':
+			// ⁽¹ ⁰⁾ is the superscript form of X[1 0]
+			retVal = append(retVal, tok{axesL, 'X', i}, tok{brackL, '[', i})
+			for i++; i < len(rs) && rs[i] != '⁾'; i++ {
+				if rs[i] == ' ' {
+					continue
+				}
+				val, last, ok := lexSupRunes(rs, i)
+				if !ok {
+					return nil, &ParseError{Input: a, Pos: i, Found: fmt.Sprintf("%q", rs[i]), Expected: []string{"superscript number", "'⁾'"}, Msg: "Unexpected rune in superscript axes"}
+				}
+				i = last
+				retVal = append(retVal, tok{digit, val, i})
+			}
+			if i >= len(rs) {
+				return nil, eosErr("'⁾'")
+			}
+			retVal = append(retVal, tok{brackR, ']', i})
+		case r == '^':
+			val, last, ok := lexCaretRunes(rs, i)
+			if !ok {
+				return nil, &ParseError{Input: a, Pos: i, Found: "'^'", Expected: []string{"number"}, Msg: "Expected a number after '^'"}
+			}
+			retVal = append(retVal, tok{sup, val, i})
+			i = last
+		case supValue(r) >= 0, r == '⁻', r == '⁼':
+			val, last, ok := lexSupRunes(rs, i)
+			if !ok {
+				return nil, &ParseError{Input: a, Pos: i, Found: fmt.Sprintf("%q", r), Expected: []string{"superscript number"}, Msg: "Dangling superscript sign"}
+			}
+			retVal = append(retVal, tok{sup, val, i})
+			i = last
+		case r == ',':
+			retVal = append(retVal, tok{comma, int(r), i})
+		case r == ':':
+			retVal = append(retVal, tok{colon, int(r), i})
+		case unicode.IsSpace(r):
+			continue // we ignore spaces and delimiters
+		case unicode.IsDigit(r):
+			var rs2 = []rune{r}
+			var j int
+			for j = i + 1; j < len(rs); j++ {
+				r2 := rs[j]
+				if !unicode.IsDigit(r2) {
+					i = j - 1
+					break
+				}
+				rs2 = append(rs2, r2)
+			}
+			i = j - 1
+			s := string(rs2)
+			num, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, &ParseError{Input: a, Pos: i - len(rs2) + 1, Found: fmt.Sprintf("%q", s), Msg: "Unable to parse number", Err: err}
+			}
+
+			retVal = append(retVal, tok{digit, num, i})
+		case r == 's' && i+3 < len(rs) && string(rs[i+1:i+4]) == ".t.":
+			retVal = append(retVal, tok{kwWhere, 'W', i})
+			i += 3
+		case unicode.IsLetter(r):
+			// a run of letters is split into keywords. Whatever is left must be a single letter variable.
+			j := i
+			for j < len(rs) && unicode.IsLetter(rs[j]) {
+				j++
+			}
+			word := string(rs[i:j])
+			k := i
+		words:
+			for k < j {
+				for _, kw := range keywords {
+					if strings.HasPrefix(word, kw.word) {
+						retVal = append(retVal, tok{kw.t, int(kw.v), k})
+						k += len([]rune(kw.word))
+						word = word[len(kw.word):]
+						continue words
+					}
+				}
+				break
+			}
+			switch j - k {
+			case 0:
+			case 1:
+				retVal = append(retVal, tok{letter, int(rs[k]), k})
+			default:
+				return nil, &ParseError{Input: a, Pos: k + 1, Found: fmt.Sprintf("%q", rs[k+1]), Msg: "Only single letters are allowed as variables"}
+			}
+			i = j - 1
+		}
+
+	}
+	return retVal, nil
+}
+
+// lexSupRunes is lexer.lexSup over a []rune.
+func lexSupRunes(rs []rune, i int) (val, last int, ok bool) {
+	if i < len(rs) && rs[i] == '⁼' {
+		return int(AllAxes), i, true
+	}
+	neg := i < len(rs) && rs[i] == '⁻'
+	j := i
+	if neg {
+		j++
+	}
+	start := j
+	for ; j < len(rs) && supValue(rs[j]) >= 0; j++ {
+		val = val*10 + supValue(rs[j])
+	}
+	if j == start {
+		return 0, i, false
+	}
+	if neg {
+		val = -val
+	}
+	return val, j - 1, true
+}
+
+// lexCaretRunes is lexer.lexCaret over a []rune.
+func lexCaretRunes(rs []rune, i int) (val, last int, ok bool) {
+	j := i + 1
+	if j < len(rs) && rs[j] == '=' {
+		return int(AllAxes), j, true
+	}
+	neg := j < len(rs) && rs[j] == '-'
+	if neg {
+		j++
+	}
+	start := j
+	for ; j < len(rs) && unicode.IsDigit(rs[j]); j++ {
+		val = val*10 + int(rs[j]-'0')
+	}
+	if j == start {
+		return 0, i, false
+	}
+	if neg {
+		val = -val
+	}
+	return val, j - 1, true
+}
@@ -0,0 +1,125 @@
+package shapes
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// mlir.go describes the conversion between shapes and MLIR tensor types, e.g. tensor<?x3x224x224xf32>.
+
+// ParseMLIRTensorType parses an MLIR tensor type, and returns its shape and its element type.
+//
+// A ranked tensor type such as tensor<2x3xf32> has a Shape. Each dynamic dimension (?) is a fresh Var, so
+// tensor<?x3xf32> has the shape (a, 3). An unranked tensor type, tensor<*xf32>, has the shape of a single Var.
+// Encodings (e.g. tensor<2x3xf32, #enc>) are not supported.
+//
+// The Vars of every call start from a. Use DimParams.ParseMLIRTensorType to parse several types with distinct Vars.
+func ParseMLIRTensorType(s string) (shape Expr, elem string, err error) {
+	var p DimParams
+	return p.ParseMLIRTensorType(s)
+}
+
+// ParseMLIRTensorType parses an MLIR tensor type like the function ParseMLIRTensorType, except that the dynamic dimensions are
+// Vars that are not used by any other shape made by p.
+func (p *DimParams) ParseMLIRTensorType(s string) (shape Expr, elem string, err error) {
+	body := strings.TrimSpace(s)
+	if !strings.HasPrefix(body, "tensor<") || !strings.HasSuffix(body, ">") {
+		return nil, "", errors.Errorf("Expected an MLIR tensor type such as tensor<2x3xf32>. Got %q instead", s)
+	}
+	body = body[len("tensor<") : len(body)-1]
+
+	if strings.HasPrefix(body, "*x") {
+		elem = body[len("*x"):]
+		if shape, err = p.fresh(); err != nil {
+			return nil, "", err
+		}
+	} else {
+		var abs Abstract
+		isShape := true
+		for {
+			if strings.HasPrefix(body, "?x") {
+				v, err := p.fresh()
+				if err != nil {
+					return nil, "", err
+				}
+				abs = append(abs, v)
+				isShape = false
+				body = body[len("?x"):]
+				continue
+			}
+			i := strings.IndexFunc(body, func(r rune) bool { return r < '0' || r > '9' })
+			if i <= 0 || body[i] != 'x' {
+				break
+			}
+			d, err := strconv.Atoi(body[:i])
+			if err != nil {
+				return nil, "", errors.Wrapf(err, "Invalid dimension in %q", s)
+			}
+			abs = append(abs, Size(d))
+			body = body[i+1:]
+		}
+		elem = body
+		if isShape {
+			shape, _ = abs.ToShape()
+		} else {
+			shape = abs
+		}
+	}
+
+	// an element type that starts with an x is a dimension that is missing, e.g. tensor<x3xf32>
+	if elem == "" || strings.ContainsRune("0123456789?*x", rune(elem[0])) {
+		return nil, "", errors.Errorf("Expected an element type in %q", s)
+	}
+	depth := 0
+	for _, r := range elem {
+		switch r {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				return nil, "", errors.Errorf("Tensor encodings are not supported. Got %q", s)
+			}
+		}
+	}
+	return shape, elem, nil
+}
+
+// FormatMLIR formats the shape of a tensor and its element type as an MLIR tensor type.
+//
+// A Shape is written as a ranked tensor type, e.g. tensor<2x3xf32>. In an Abstract, every dimension that is not a Size is dynamic,
+// so (a, 3) is written as tensor<?x3xf32>. A Var is written as an unranked tensor type, tensor<*xf32>.
+func FormatMLIR(e Expr, elem string) (string, error) {
+	if elem == "" {
+		return "", errors.New("Expected an element type")
+	}
+
+	var buf strings.Builder
+	buf.WriteString("tensor<")
+	switch et := e.(type) {
+	case Shape:
+		for _, d := range et {
+			buf.WriteString(strconv.Itoa(d))
+			buf.WriteByte('x')
+		}
+	case Abstract:
+		for _, d := range et {
+			if sz, ok := d.(Size); ok {
+				buf.WriteString(strconv.Itoa(int(sz)))
+			} else {
+				buf.WriteByte('?')
+			}
+			buf.WriteByte('x')
+		}
+	case Var:
+		buf.WriteString("*x")
+	default:
+		return "", errors.Errorf("Cannot format %v of %T as an MLIR tensor type. Expected a Shape, an Abstract or a Var", e, e)
+	}
+	buf.WriteString(elem)
+	buf.WriteByte('>')
+	return buf.String(), nil
+}
@@ -0,0 +1,91 @@
+package shapes
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mlirTests = []struct {
+	in string
+
+	shape Expr
+	elem  string
+	err   bool
+}{
+	{"tensor<2x3xf32>", Shape{2, 3}, "f32", false},
+	{"tensor<?x3x224x224xf32>", Abstract{Var('a'), Size(3), Size(224), Size(224)}, "f32", false},
+	{"tensor<?x?xi8>", Abstract{Var('a'), Var('b')}, "i8", false},
+	{"tensor<f32>", Shape{}, "f32", false},
+	{"tensor<0x4xindex>", Shape{0, 4}, "index", false},
+	{"tensor<*xf32>", Var('a'), "f32", false},
+	{"tensor<4xcomplex<f32>>", Shape{4}, "complex<f32>", false},
+	{"tensor<2xvector<4xf32>>", Shape{2}, "vector<4xf32>", false},
+	{"  tensor<3xbf16> ", Shape{3}, "bf16", false},
+
+	{"tensor<2x3x>", nil, "", true},
+	{"tensor<2x3>", nil, "", true},
+	{"tensor<x3xf32>", nil, "", true},
+	{"tensor<2xx3xf32>", nil, "", true},
+	{"tensor<>", nil, "", true},
+	{"tensor<2x3xf32, #enc>", nil, "", true},
+	{"memref<2x3xf32>", nil, "", true},
+	{"tensor<2x3xf32", nil, "", true},
+	{"tensor<99999999999999999999x3xf32>", nil, "", true},
+}
+
+func TestParseMLIRTensorType(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range mlirTests {
+		shape, elem, err := ParseMLIRTensorType(c.in)
+		if checkErr(t, c.err, err, "ParseMLIRTensorType", c.in) {
+			continue
+		}
+		assert.Equal(c.shape, shape, "Shape of %q", c.in)
+		assert.Equal(c.elem, elem, "Element type of %q", c.in)
+
+		s, err := FormatMLIR(shape, elem)
+		if err != nil {
+			t.Errorf("Unable to format %v: %v", shape, err)
+			continue
+		}
+		assert.Equal(strings.TrimSpace(c.in), s, "Round trip of %q", c.in)
+	}
+}
+
+func TestFormatMLIR(t *testing.T) {
+	assert := assert.New(t)
+	s, err := FormatMLIR(Abstract{BinOp{Add, Var('a'), Size(1)}, Size(3)}, "f32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("tensor<?x3xf32>", s)
+
+	_, err = FormatMLIR(Shape{2}, "")
+	assert.NotNil(err, "Expected an error without an element type")
+	_, err = FormatMLIR(Arrow{Var('a'), Var('a')}, "f32")
+	assert.NotNil(err, "Expected an error formatting an Arrow")
+}
+
+func ExampleDimParams_ParseMLIRTensorType() {
+	var p DimParams
+	for _, typ := range []string{"tensor<?x784xf32>", "tensor<784x?xf32>", "tensor<*xi64>"} {
+		shape, elem, err := p.ParseMLIRTensorType(typ)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(shape, elem)
+	}
+
+	s, _ := FormatMLIR(Abstract{Var('a'), Size(10)}, "f32")
+	fmt.Println(s)
+
+	// Output:
+	// (a, 784) f32
+	// (784, b) f32
+	// c i64
+	// tensor<?x10xf32>
+}
@@ -0,0 +1,170 @@
+package shapes
+
+import (
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// onnx.go describes the conversion between shapes and the TensorShapeProto message of ONNX.
+// The messages are mirrored as plain structs, so that this package does not depend on protobuf.
+
+// ONNXShape mirrors the TensorShapeProto message of ONNX. A nil *ONNXShape is the shape of a tensor of unknown rank.
+type ONNXShape struct {
+	Dim []ONNXDim
+}
+
+// ONNXDim mirrors the TensorShapeProto.Dimension message of ONNX.
+//
+// dim_value and dim_param are a oneof, so a dimension is either a known size (HasValue is true),
+// a symbolic dimension (DimParam is not empty), or unknown.
+type ONNXDim struct {
+	DimValue   int64
+	DimParam   string
+	Denotation string
+
+	HasValue bool // whether dim_value is set
+}
+
+// DimParams maps the symbolic dimensions of ONNX shapes (i.e. dim_param) to Vars, and back.
+// A name always maps to the same Var, so a dimension such as "batch" that is shared by the inputs of a model is the same
+// Var in every shape.
+//
+// A name that is a single lower case letter maps to itself if it is not already taken. Any other name maps to the first
+// free letter. Unknown dimensions map to fresh Vars, which are written back as unknown dimensions.
+// DimParams also makes the Vars of the dynamic dimensions of MLIR tensor types (see ParseMLIRTensorType).
+//
+// The zero value is ready to use.
+type DimParams struct {
+	vars    map[string]Var
+	names   map[Var]string
+	unknown varset
+}
+
+// Var returns the Var of a dim_param, making a new one if needed.
+func (p *DimParams) Var(name string) (Var, error) {
+	if v, ok := p.vars[name]; ok {
+		return v, nil
+	}
+	if p.vars == nil {
+		p.vars = make(map[string]Var)
+		p.names = make(map[Var]string)
+	}
+
+	v := Var(-1)
+	if r, size := utf8.DecodeRuneInString(name); size == len(name) && r >= 'a' && r <= 'z' && !p.used(Var(r)) {
+		v = Var(r)
+	}
+	if v < 0 {
+		var err error
+		if v, err = freshVar(p.usedVars()); err != nil {
+			return 0, errors.Wrapf(err, "Unable to make a Var for dim_param %q", name)
+		}
+	}
+	p.vars[name] = v
+	p.names[v] = name
+	return v, nil
+}
+
+// Name returns the dim_param of a Var.
+func (p *DimParams) Name(v Var) (string, bool) {
+	name, ok := p.names[v]
+	return name, ok
+}
+
+func (p *DimParams) used(v Var) bool {
+	_, ok := p.names[v]
+	return ok || p.unknown.Contains(v)
+}
+
+func (p *DimParams) usedVars() varset {
+	retVal := make(varset, 0, len(p.names)+len(p.unknown))
+	for v := range p.names {
+		retVal = append(retVal, v)
+	}
+	return append(retVal, p.unknown...)
+}
+
+// fresh returns a new Var for an unknown dimension.
+func (p *DimParams) fresh() (Var, error) {
+	v, err := freshVar(p.usedVars())
+	if err != nil {
+		return 0, errors.Wrap(err, "Unable to make a Var for an unknown dimension")
+	}
+	p.unknown = append(p.unknown, v)
+	return v, nil
+}
+
+// FromONNX converts an ONNX shape into an Expr. If every dimension is known, the result is a Shape.
+// Otherwise, the result is an Abstract, where dim_params and unknown dimensions are Vars. A nil shape (i.e. unknown rank) is a Var.
+func (p *DimParams) FromONNX(s *ONNXShape) (Expr, error) {
+	if s == nil {
+		return p.fresh()
+	}
+
+	retVal := make(Abstract, 0, len(s.Dim))
+	isShape := true
+	for _, d := range s.Dim {
+		switch {
+		case d.HasValue && d.DimValue >= 0:
+			retVal = append(retVal, Size(d.DimValue))
+			continue
+		case d.DimParam != "" && !d.HasValue:
+			v, err := p.Var(d.DimParam)
+			if err != nil {
+				return nil, err
+			}
+			retVal = append(retVal, v)
+		default:
+			// a negative dim_value is treated as unknown, as some exporters write -1 for an unknown dimension
+			v, err := p.fresh()
+			if err != nil {
+				return nil, err
+			}
+			retVal = append(retVal, v)
+		}
+		isShape = false
+	}
+	if isShape {
+		s, _ := retVal.ToShape()
+		return s, nil
+	}
+	return retVal, nil
+}
+
+// ToONNX converts a Shape, an Abstract or a Var into an ONNX shape. A Var is a tensor of unknown rank, so its shape is nil.
+//
+// A Var in an Abstract is written as its dim_param. A Var that was made for an unknown dimension is written as an unknown dimension,
+// and any other Var is written as a dim_param of its letter. Only Sizes and Vars can be written.
+func (p *DimParams) ToONNX(e Expr) (*ONNXShape, error) {
+	switch et := e.(type) {
+	case Shape:
+		retVal := &ONNXShape{Dim: make([]ONNXDim, 0, len(et))}
+		for _, d := range et {
+			retVal.Dim = append(retVal.Dim, ONNXDim{DimValue: int64(d), HasValue: true})
+		}
+		return retVal, nil
+	case Abstract:
+		retVal := &ONNXShape{Dim: make([]ONNXDim, 0, len(et))}
+		for i, d := range et {
+			switch dt := d.(type) {
+			case Size:
+				retVal.Dim = append(retVal.Dim, ONNXDim{DimValue: int64(dt), HasValue: true})
+			case Var:
+				var dim ONNXDim
+				if name, ok := p.names[dt]; ok {
+					dim.DimParam = name
+				} else if !p.unknown.Contains(dt) {
+					dim.DimParam = string(rune(dt))
+				}
+				retVal.Dim = append(retVal.Dim, dim)
+			default:
+				return nil, errors.Errorf("Cannot write dimension %d of %v to ONNX. Only Sizes and Vars can be written. Got %v of %T", i, e, d, d)
+			}
+		}
+		return retVal, nil
+	case Var:
+		return nil, nil
+	}
+	return nil, errors.Errorf("Cannot write %v of %T to ONNX. Expected a Shape, an Abstract or a Var", e, e)
+}
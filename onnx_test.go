@@ -0,0 +1,117 @@
+package shapes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func onnxValue(n int64) ONNXDim     { return ONNXDim{DimValue: n, HasValue: true} }
+func onnxParam(name string) ONNXDim { return ONNXDim{DimParam: name} }
+
+var fromONNXTests = []struct {
+	name string
+	in   *ONNXShape
+
+	correct Expr
+	err     bool
+}{
+	{"known", &ONNXShape{Dim: []ONNXDim{onnxValue(2), onnxValue(3)}}, Shape{2, 3}, false},
+	{"scalar", &ONNXShape{}, Shape{}, false},
+	{"zero size", &ONNXShape{Dim: []ONNXDim{onnxValue(0), onnxValue(3)}}, Shape{0, 3}, false},
+	{"params", &ONNXShape{Dim: []ONNXDim{onnxParam("batch"), onnxValue(3), onnxParam("seq_len")}}, Abstract{Var('a'), Size(3), Var('b')}, false},
+	{"single letter params", &ONNXShape{Dim: []ONNXDim{onnxParam("n"), onnxParam("batch"), onnxParam("N")}}, Abstract{Var('n'), Var('a'), Var('b')}, false},
+	{"unknown", &ONNXShape{Dim: []ONNXDim{{}, onnxValue(3), onnxValue(-1)}}, Abstract{Var('a'), Size(3), Var('b')}, false},
+	{"unknown rank", nil, Var('a'), false},
+}
+
+func TestDimParams_FromONNX(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range fromONNXTests {
+		var p DimParams
+		e, err := p.FromONNX(c.in)
+		if checkErr(t, c.err, err, "FromONNX", c.name) {
+			continue
+		}
+		assert.Equal(c.correct, e, "FromONNX %q", c.name)
+
+		out, err := p.ToONNX(e)
+		if err != nil {
+			t.Errorf("%q: ToONNX failed: %v", c.name, err)
+			continue
+		}
+		if c.in == nil {
+			assert.Nil(out, "ToONNX %q", c.name)
+			continue
+		}
+		// negative values are written back as unknown dimensions
+		correct := &ONNXShape{Dim: make([]ONNXDim, 0, len(c.in.Dim))}
+		for _, d := range c.in.Dim {
+			if d.DimValue < 0 {
+				d = ONNXDim{}
+			}
+			correct.Dim = append(correct.Dim, d)
+		}
+		assert.Equal(correct, out, "ToONNX %q", c.name)
+	}
+}
+
+func TestDimParams_shared(t *testing.T) {
+	assert := assert.New(t)
+	var p DimParams
+	x, err := p.FromONNX(&ONNXShape{Dim: []ONNXDim{onnxParam("batch"), onnxValue(784)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := p.FromONNX(&ONNXShape{Dim: []ONNXDim{onnxValue(784), onnxParam("hidden")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := p.FromONNX(&ONNXShape{Dim: []ONNXDim{{}, {}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(Abstract{Var('a'), Size(784)}, x)
+	assert.Equal(Abstract{Size(784), Var('b')}, w)
+	assert.Equal(Abstract{Var('c'), Var('d')}, u)
+
+	// an inferred shape is written back with the names of the inputs. Vars that are not from ONNX are named by their letter
+	out, err := p.ToONNX(Abstract{Var('a'), Var('b'), Var('c'), Var('z')})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(&ONNXShape{Dim: []ONNXDim{onnxParam("batch"), onnxParam("hidden"), {}, onnxParam("z")}}, out)
+
+	_, err = p.ToONNX(Abstract{BinOp{Add, Var('a'), Size(1)}})
+	assert.NotNil(err, "Expected an error writing a BinOp dimension")
+	_, err = p.ToONNX(Arrow{Var('a'), Var('a')})
+	assert.NotNil(err, "Expected an error writing an Arrow")
+}
+
+func ExampleDimParams() {
+	var p DimParams
+	input, _ := p.FromONNX(&ONNXShape{Dim: []ONNXDim{{DimParam: "batch"}, {DimValue: 784, HasValue: true}}})
+	fmt.Println(input)
+
+	// the variables of matmul do not clash with the variables from ONNX
+	matmul := MakeArrow(Abstract{Var('x'), Var('y')}, Abstract{Var('y'), Var('z')}, Abstract{Var('x'), Var('z')})
+	fn, err := InferApp(matmul, input)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(fn)
+
+	// the output of fn is written back to ONNX
+	shape, _ := p.ToONNX(fn.(Arrow).B)
+	for _, d := range shape.Dim {
+		fmt.Printf("%+v\n", d)
+	}
+
+	// Output:
+	// (a, 784)
+	// (784, z) → (a, z)
+	// {DimValue:0 DimParam:batch Denotation: HasValue:false}
+	// {DimValue:0 DimParam:z Denotation: HasValue:false}
+}
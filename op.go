@@ -88,6 +88,14 @@ func (o OpType) String() string {
 	return retVal
 }
 
+// prec returns the precedence of the operation, as per opprec.
+func (o OpType) prec() int {
+	for _, r := range optypeStr[o] {
+		return opprec[r]
+	}
+	return 0
+}
+
 func parseOpType(a rune) (OpType, error) {
 	retVal, ok := optypeRune[a]
 	if !ok {
@@ -321,24 +329,14 @@ func (op BinOp) subExprs() []substitutableExpr {
 }
 
 // Format formats the BinOp into a nice string.
+//
+// Operands that bind less tightly than the operation are parenthesized.
+// Because binary operations are left associative, so is a right operand of the same precedence.
 func (op BinOp) Format(s fmt.State, r rune) {
-	var format string
-	switch r {
-	case 'P':
-		if op.A.depth() >= 3 || op.B.depth() >= 3 {
-			format = "(%P %v %P)"
-		} else {
-			format = "(%v %v %v)"
-		}
-	default:
-		if op.A.depth() >= 3 || op.B.depth() >= 3 {
-			format = "%P %v %P"
-		} else {
-			format = "%v %v %v"
-		}
-
-	}
-	fmt.Fprintf(s, format, op.A, op.Op, op.B)
+	prec := op.Op.prec()
+	fmtOperand(s, op.A, prec)
+	fmt.Fprintf(s, " %v ", op.Op)
+	fmtOperand(s, op.B, prec+1)
 }
 
 // UnaryOp represetns a unary operation on a shape expression.
@@ -479,4 +477,7 @@ func (op UnaryOp) subExprs() []substitutableExpr {
 }
 
 // Format makes UnaryOp implement fmt.Formatter.
-func (op UnaryOp) Format(s fmt.State, r rune) { fmt.Fprintf(s, "%v %v", op.Op, op.A) }
+func (op UnaryOp) Format(s fmt.State, r rune) {
+	fmt.Fprintf(s, "%v ", op.Op)
+	fmtOperand(s, op.A, precPrefix)
+}
@@ -3,19 +3,72 @@ package shapes
 import (
 	"bytes"
 	"fmt"
-	"strconv"
+	"io"
+	"strings"
+	"sync"
 	"unicode"
 
 	"github.com/pkg/errors"
 )
 
 // Parse parses a string and returns a shape expression.
-func Parse(a string) (retVal Expr, err error) {
-	q, err := lex(a)
-	if err != nil {
+//
+// Parse is the inverse of formatting: for any Expr e, Parse(fmt.Sprint(e)) returns an expression equivalent to e.
+// The ASCII spellings produced by FormatASCII are accepted as well.
+//
+// If the string is not a valid shape expression, the returned error is a *ParseError.
+func Parse(a string) (retVal Expr, err error) { return parse(a, nil) }
+
+// ParseApp parses an application such as f @ x @ y, and returns the function and its arguments, so that they may be inferred with InferArgs.
+// The Span of each argument is where it is in the string. If the string is not an application, the expression is returned with no arguments.
+//
+// Only the outermost applications are split up, so in (f @ x) @ y, the function is f @ x.
+func ParseApp(a string) (fn Expr, args []Arg, err error) {
+	p := getParser()
+	defer putParser(p)
+	p.sr.Reset(a)
+	p.lx.reset(&p.sr)
+	p.lx.input, p.lx.hasInput = a, true
+	if fn, err = p.run(); err != nil {
+		return nil, nil, err
+	}
+
+	if _, ok := fn.(Application); !ok {
+		return fn, nil, nil
+	}
+	args = make([]Arg, len(p.argSpans))
+	for i := len(args) - 1; i >= 0; i-- {
+		app := fn.(Application)
+		args[i] = Arg{Expr: app.B, Span: p.argSpans[i]}
+		fn = app.A
+	}
+	return fn, args, nil
+}
+
+// ParseReader is like Parse, but reads the expression from r.
+func ParseReader(r io.RuneReader) (retVal Expr, err error) {
+	p := getParser()
+	defer putParser(p)
+	p.lx.reset(r)
+	return p.run()
+}
+
+// parse parses a string. References to signatures (e.g. $matmul) are looked up in env.
+func parse(a string, env map[string]Expr) (retVal Expr, err error) {
+	p := getParser()
+	defer putParser(p)
+	p.sr.Reset(a)
+	p.lx.reset(&p.sr)
+	p.lx.input, p.lx.hasInput = a, true
+	p.env = env
+	return p.run()
+}
+
+// run lexes and parses the input of the lexer.
+func (p *parser) run() (retVal Expr, err error) {
+	if p.queue, err = p.lx.lex(); err != nil {
 		return nil, err
 	}
-	p := newParser(true)
 	defer func() {
 		if r := recover(); r != nil {
 			p.printTab(nil)
@@ -23,926 +76,985 @@ func Parse(a string) (retVal Expr, err error) {
 		}
 	}()
 
-	err = p.parse(q)
-	p.logstate()
-	if len(p.stack) == 0 {
-		p.printTab(nil)
-		return nil, errors.Errorf("WTF?")
-	}
-	if len(p.infixStack) > 0 {
-		p.printTab(nil)
-		return nil, errors.New("Incomplete expression. There are operators remaining that are unparsed.")
-	}
-
+	retVal, err = p.parseTop()
 	p.printTab(nil)
-	var ok bool
-	if retVal, ok = p.stack[0].(Expr); !ok {
-		return nil, errors.Errorf("Expected the final parse to be an Expr. Got %v of %T instead", p.stack[0], p.stack[0])
+	if err != nil {
+		return nil, p.wrapErr(err)
 	}
-	return
-}
-
-type parser struct {
-	queue      []tok           // incoming string of tokens
-	stack      []substitutable // "working" stack
-	infixStack []tok           // stack of operators
-	qptr       int             // queue pointer
-
-	log *bytes.Buffer
+	return retVal, nil
 }
 
-func newParser(log bool) *parser {
-	var l *bytes.Buffer
-	if log {
-		l = new(bytes.Buffer)
-	}
-	return &parser{
-		log: l,
-	}
+// ParseError is the error returned by Parse when the input is not a valid shape expression.
+type ParseError struct {
+	Input    string   // the input that was being parsed
+	Pos      int      // the offset (in runes) of the offending token in Input
+	Found    string   // the offending token. "EOF" if the input ended unexpectedly
+	Expected []string // the kinds of tokens that were expected instead, if known
+	Msg      string   // what went wrong. If empty, the message is made of Expected alone
+	Err      error    // the underlying error, if any
 }
 
-func (p *parser) pop() substitutable {
-	if len(p.stack) == 0 {
-		panic("cannot pop")
+// Error prints the message, followed by the input with a caret under the offending position.
+func (e *ParseError) Error() string {
+	var expected string
+	switch len(e.Expected) {
+	case 0:
+	case 1:
+		expected = "Expected " + e.Expected[0]
+	default:
+		expected = "Expected one of " + strings.Join(e.Expected, ", ")
 	}
 
-	retVal := p.stack[len(p.stack)-1]
-	p.stack = p.stack[:len(p.stack)-1]
-	return retVal
-}
-
-func (p *parser) popExpr() (Expr, error) {
-	s := p.pop()
-	e, ok := s.(Expr)
-	if !ok {
-		return nil, errors.Errorf("Expected an Expr. Got %v of %T instead", s, s)
+	var buf strings.Builder
+	msg := e.Msg
+	if msg == "" {
+		msg, expected = expected, ""
 	}
-	return e, nil
-}
-
-func (p *parser) push(a substitutable) { p.stack = append(p.stack, a) }
-
-func (p *parser) pushInfix(t tok) { p.infixStack = append(p.infixStack, t) }
-
-func (p *parser) popInfix() tok {
-	if len(p.infixStack) == 0 {
-		panic("Cannot pop infix stack")
+	fmt.Fprintf(&buf, "%s at position %d", msg, e.Pos)
+	if e.Found != "" {
+		fmt.Fprintf(&buf, " (found %s)", e.Found)
 	}
-	retVal := p.infixStack[len(p.infixStack)-1]
-	p.infixStack = p.infixStack[:len(p.infixStack)-1]
-	return retVal
-}
-
-func (p *parser) cur() (tok, error) {
-	if p.qptr < 0 || p.qptr >= len(p.queue) {
-		return tok{}, errors.Errorf("Cannot get current token. Pointer: %d. Queue: %v", p.qptr, len(p.queue))
+	if expected != "" {
+		fmt.Fprintf(&buf, ". %s", expected)
 	}
-	return p.queue[p.qptr], nil
-}
-
-// compose performs f() then g()
-func (p *parser) compose(g, f func() error, gname, fname string) func() error {
-	return func() error {
-		if err := f(); err != nil {
-			return errors.Wrapf(err, "In composed functions %v ∘ %v. %v failed", fname, gname, fname)
+	buf.WriteString("\n\t")
+	buf.WriteString(e.Input)
+	buf.WriteString("\n\t")
+	for i, r := range []rune(e.Input) {
+		if i >= e.Pos {
+			break
 		}
-		if err := g(); err != nil {
-			return errors.Wrapf(err, "In composed functions %v ∘ %v. %v failed", fname, gname, gname)
+		// keep tabs so that the caret lines up
+		if r == '\t' {
+			buf.WriteRune('\t')
+			continue
 		}
-		return nil
+		buf.WriteRune(' ')
 	}
+	buf.WriteRune('^')
+	return buf.String()
 }
 
-// compareCur compares the cur token with  the top of the infix stack. It returns a function that the parser should take
-func (p *parser) compareCur() func() error {
-	p.logstate()
-	t, _ := p.cur() // will never err here.
+// Cause returns the underlying error. It allows ParseError to be used with errors.Cause.
+func (e *ParseError) Cause() error { return e.Err }
 
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// describeTok describes a token for use in a ParseError.
+func describeTok(t tok) string {
 	switch t.t {
+	case eos:
+		return "EOF"
 	case digit:
-		return p.pushNum
+		return fmt.Sprintf("number %d", t.v)
 	case letter:
-		return p.pushVar
-	case axesL:
-		return p.compose(p.pushVar, p.pushCurTok, "pushVar", "pushCurTok") // X is a special "variable". It's used to  mark how many items are in a axes.
-	case transposeop:
-		return p.resolveTranspose
-	default:
-		if len(p.infixStack) == 0 {
-			// push the current to infixStack
-			return p.pushCurTok
-		}
-		// check if current token has greater op prec than top of stack
-		top := p.infixStack[len(p.infixStack)-1]
-		topPrec := opprec[top.v]
-		curPrec := opprec[t.v]
-
-		// if current is negative, we need to resolve until the infixStack has len 0 then pushCurTok
-		if curPrec < 0 {
-			if err := p.pushCurTok(); err != nil {
-				return func() error { return errors.Wrap(err, "curPrec < 0") }
-			}
-			// special case because ']' has -1 opprec but doesn't really require ALL infixes to be resolved before hand
-			if t.v == ']' {
-				return p.resolveInfix
+		return fmt.Sprintf("variable %q", t.v)
+	case sup:
+		return fmt.Sprintf("superscript %d", t.v)
+	case ref:
+		return "signature reference"
+	case reduct:
+		return "'/'"
+	case kwSizes, kwRepeat, kwDiag, kwDiagEmbed, kwWhere:
+		for _, kw := range keywords {
+			if kw.t == t.t {
+				return fmt.Sprintf("%q", kw.word)
 			}
-			return p.resolveAllInfix
-		}
-
-		if curPrec > topPrec {
-			return p.pushCurTok
 		}
-
-		// check special case of arrows (which are right assoc)
-		if top.t == arrow && t.t == arrow {
-			return p.pushCurTok
-		}
-
-		// otherwise resolve first then pushcurtok
-		return p.compose(p.pushCurTok, p.resolveInfixCompareCur, "pushCurTok", "resolveInfixCompareCur")
 	}
+	return fmt.Sprintf("%q", t.v)
 }
 
-func (p *parser) incrQPtr() error {
-	p.qptr++
-	if p.qptr >= len(p.queue) {
-		return errors.Errorf("XXX")
-	}
-	return nil
-}
+type parser struct {
+	queue []tok // incoming string of tokens
+	qptr  int   // queue pointer
 
-// pushVar pushes a var on to the values stack.
-func (p *parser) pushVar() error {
-	t, err := p.cur()
-	if err != nil {
-		return errors.Wrap(err, "Unable to pushVar")
-	}
-	p.push(Var(t.v))
+	lx lexer
+	sr strings.Reader // reused when parsing strings
 
-	// special cases: a[...] and X[...]
-	if p.qptr == len(p.queue)-1 {
-		return nil
-	}
+	// scratch stacks for the elements of lists, and where they start
+	elems  []substitutable
+	starts []tok
 
-	next := p.queue[p.qptr+1] // peek
-	switch {
-	case t.v != 'X' && next.v == '[':
-		p.push(SliceOf{})
-		// consume the '[' token
-		p.incrQPtr()
-		p.pushInfix(p.queue[p.qptr])
-	case t.v == 'X' && next.v != '[':
-		// error
-		return errors.Errorf("Expected '[' after X. Got %v instead", next)
-	case t.v == 'X' && next.v == '[':
-		// consume the '[' token
-		p.incrQPtr()
-		p.pushInfix(p.queue[p.qptr])
-	}
-
-	return nil
-}
+	env map[string]Expr // signatures that may be referenced, e.g. $matmul
 
-// pushNum pushes a number (typed as a Size) onto the values stack.
-func (p *parser) pushNum() error {
-	t, err := p.cur()
-	if err != nil {
-		return errors.Wrap(err, "Unable to pushNum")
-	}
+	// the spans of the arguments of the outermost application, for ParseApp
+	appDepth int
+	argSpans []Span
 
-	p.push(Size(int(t.v)))
-	return nil
+	log *bytes.Buffer // only allocated in debug builds
 }
 
-// pushCurTok pushes the current token into the infixStack
-func (p *parser) pushCurTok() error {
-	t, _ := p.cur()
-	p.pushInfix(t)
-	return nil
+// parsers are pooled, so that their buffers may be reused.
+var parserPool = sync.Pool{New: func() interface{} { return newParser() }}
+
+func getParser() *parser { return parserPool.Get().(*parser) }
+
+// putParser resets the parser and returns it to the pool.
+func putParser(p *parser) {
+	p.queue = nil
+	p.qptr = 0
+	p.lx.reset(nil)
+	p.sr.Reset("")
+	p.env = nil
+	p.appDepth = 0
+	p.argSpans = p.argSpans[:0]
+	p.popElems(0)
+	if p.log != nil {
+		p.log.Reset()
+	}
+	parserPool.Put(p)
 }
 
-// checkItems checks that there are at least `expected` number of items in the stack.
-func (p *parser) checkItems(expected int) error {
-	if len(p.stack) < expected {
-		return errors.Errorf("Expected at least %d items in stack. Stack %v", expected, p.stack)
+// errorAt creates a ParseError located at the given token.
+func (p *parser) errorAt(t tok, expected []string, format string, args ...interface{}) *ParseError {
+	return &ParseError{
+		Input:    p.lx.source(),
+		Pos:      t.l,
+		Found:    describeTok(t),
+		Expected: expected,
+		Msg:      fmt.Sprintf(format, args...),
 	}
-	return nil
 }
 
-// checkInfix checks that there are at least `expected` number of infix operataors in the infixStack.
-func (p *parser) checkInfix(expected int) error {
-	if len(p.infixStack) < expected {
-		return errors.Errorf("Expected at least %d items in infixStack. InfixStack: %v", expected, p.infixStack)
-	}
-	return nil
+// errorAtEOF creates a ParseError located at the end of the input.
+func (p *parser) errorAtEOF(expected []string, format string, args ...interface{}) *ParseError {
+	return p.errorAt(p.eos(), expected, format, args...)
 }
 
-// checkEOF checks that there are enough items on the queue. Otherwise it's an "EOF" error - something is dangling.
-func (p *parser) checkEOF() error {
-	if len(p.queue) <= p.qptr {
-		return errors.Errorf("Unexpected EOF.")
+// wrapErr turns any error that happens during parsing into a ParseError.
+// If a ParseError is already in the chain, it is returned. Otherwise the error is located at the current token.
+func (p *parser) wrapErr(err error) *ParseError {
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return pe
 	}
-	return nil
+	msg := strings.TrimSuffix(errors.Cause(err).Error(), ".")
+	retVal := p.errorAt(p.cur(), nil, "%s", msg)
+	retVal.Err = err
+	return retVal
 }
 
-func (p *parser) parse(q []tok) (err error) {
-	p.queue = q
-	for p.qptr < len(p.queue) {
-		if err = p.parseOne(); err != nil {
-			// p.printTab(nil)
-			return err
-		}
-		p.incrQPtr()
+// spanFrom returns the span from the start of the given token to the end of the last token consumed.
+func (p *parser) spanFrom(start tok) Span {
+	end := p.cur().l
+	for end > start.l && unicode.IsSpace(p.lx.src[end-1]) {
+		end--
 	}
-	if len(p.infixStack) > 0 {
-		if err := p.resolveAllInfix(); err != nil {
-			return errors.Wrap(err, "Unable to resolve all infixes while in .parse")
-		}
-	}
-	//p.printTab(nil)
-	return nil
+	return Span{Start: start.l, End: end}
 }
 
-func (p *parser) parseOne() error {
-	t, err := p.cur()
-	if err != nil {
-		return errors.Wrap(err, "Unable to parseOne.")
-	}
+// eos returns the token that marks the end of the input.
+func (p *parser) eos() tok { return tok{t: eos, l: len(p.lx.src)} }
 
-	// special cases: ()
-	if t.v == '(' {
-		if p.qptr == len(p.queue)-1 {
-			return errors.Errorf("Dangling open paren '(' at %v", t.l)
-		}
-		if p.queue[p.qptr+1].v == ')' {
-			p.push(Shape{})
-			p.incrQPtr()
-			return nil
-		}
+// cur returns the current token. If all the tokens have been consumed, the end of string token is returned.
+func (p *parser) cur() tok {
+	if p.qptr < len(p.queue) {
+		return p.queue[p.qptr]
 	}
+	return p.eos()
+}
 
-	fn := p.compareCur()
-	if err := fn(); err != nil {
-		return errors.Wrap(err, "Unable to parseOne")
+// peek returns the token after the current token.
+func (p *parser) peek() tok {
+	if p.qptr+1 < len(p.queue) {
+		return p.queue[p.qptr+1]
 	}
-	return nil
+	return p.eos()
 }
 
-// resolveAllInfix resolves all the infixes in the infixStack. The result will be an empty infix stack.
-func (p *parser) resolveAllInfix() error {
-	var count int
-	for len(p.infixStack) > 0 {
-		if err := p.resolveInfix(); err != nil {
-			if _, ok := err.(NoOpError); ok {
-				break
-			}
-			return errors.Wrapf(err, "Unable to resolve all infixes. %d processed.", count)
-		}
-		count++
+// next consumes the current token and returns it.
+func (p *parser) next() tok {
+	t := p.cur()
+	if p.qptr < len(p.queue) {
+		p.qptr++
 	}
-	return nil
+	return t
 }
 
-// resolveInfixCompareCur will resolve the infixes until such a time that the top of the infixStack has smaller precedence than the current.
-func (p *parser) resolveInfixCompareCur() error {
-	t, _ := p.cur() // will never err
-	top := p.infixStack[len(p.infixStack)-1]
-	topPrec := opprec[top.v]
-	curPrec := opprec[t.v]
-
-	for curPrec < topPrec && curPrec >= 0 {
-		if err := p.resolveInfix(); err != nil {
-			if _, ok := err.(NoOpError); ok {
-				break // No Op error is returned when there is a paren
-			}
-			return errors.Wrap(err, "Unable to resolveInfixCompareCur")
-		}
-		if len(p.infixStack) == 0 {
-			break
-		}
-		top = p.infixStack[len(p.infixStack)-1]
-		topPrec = opprec[top.v]
+// expect consumes the current token if it is of the given type. `what` describes the expected token.
+func (p *parser) expect(tt tokentype, what string) (tok, error) {
+	t := p.cur()
+	if t.t != tt {
+		return t, p.errorAt(t, []string{what}, "")
 	}
-
-	return nil
+	return p.next(), nil
 }
 
-// resolveInfix resolves one infix operator from the infixStack
-func (p *parser) resolveInfix() error {
-	last := p.popInfix()
-	var err error
-	switch last.t {
-	case unop:
-		if err = p.resolveUnOp(last); err != nil {
-			return errors.Wrapf(err, "Unable to resolve unop %v.", last)
-		}
-	case binop:
-		if err = p.resolveBinOp(last); err != nil {
-			return errors.Wrapf(err, "Unable to resolve binop %v.", last)
-		}
-	case cmpop:
-		if err = p.resolveCmpOp(last); err != nil {
-			return errors.Wrapf(err, "Unable to resolve cmpop %v.", last)
-		}
-	case logop:
-		if err = p.resolveLogOp(last); err != nil {
-			return errors.Wrapf(err, "Unable to resolve logop %v.", last)
-		}
-	case arrow:
-		if err := p.resolveArrow(last); err != nil {
-			return errors.Wrapf(err, "Cannot resolve arrow %v.", last)
-		}
-	case parenL:
-		p.pushInfix(last)
-		return noopError{}
-	case parenR:
-		if err := p.resolveA(); err != nil {
-			return errors.Wrap(err, "Cannot resolve A.")
-		}
-	case comma:
-		if err := p.resolveComma(last); err != nil {
-			return errors.Wrapf(err, "Cannot resolve comma %v", last)
-		}
-	case braceL:
-		p.pushInfix(last)
-		return noopError{}
-	case braceR:
-		if err := p.resolveCompound(); err != nil {
-			return errors.Wrapf(err, "Cannot resolve compound %v", last)
-		}
-
-	case brackL:
-		p.pushInfix(last)
-		return noopError{}
-	case brackR:
-		if err := p.resolveSlice(); err != nil {
-			return errors.Wrapf(err, "Cannot resolve slice %v", last)
-		}
-	case colon:
-		if err := p.resolveColon(); err != nil {
-			return errors.Wrapf(err, "Cannot resolve colon %v", last)
-		}
-	case axesL:
-		if err := p.resolveAxes(); err != nil {
-			return errors.Wrapf(err, "Cannot resolve Axes %v", last)
-		}
-	default:
-		//	log.Printf("last {%v %c %v} is unhandled", last.t, last.v, last.l)
+// expectClosing is like expect, but reports a missing closing token at the opening token.
+func (p *parser) expectClosing(open tok, tt tokentype, what string) (tok, error) {
+	if t := p.cur(); t.t == eos {
+		return t, p.errorAt(open, []string{what}, "Unclosed %q", open.v)
 	}
-
-	return nil
+	return p.expect(tt, what)
 }
 
-// resolveGroup resolves groupings of `(...)` and `[...]`
-func (p *parser) resolveGroup(want rune) error {
-	var bw []tok
-	var found bool
-loop:
-	for i := len(p.infixStack) - 1; i >= 0; i-- {
-		t := p.popInfix()
-		// keep going until you find the first '[' or '(', whatever that was passed into `want`
-
-		switch {
-		case t.v == want && want == '[':
-			// special case, iterate once more to find out if the infix operator before is 'X'
-			if i-1 < 0 {
-				found = true
-				break loop
-			}
-
-			x := p.popInfix()
-			if x.v != 'X' {
-				p.pushInfix(x) // undo the pop
-				found = true
-				break loop
-			}
-
-			bw = append(bw, x)
+// parenthesized marks a single parenthesized term.
+// Whether the parentheses make a shape out of the term (e.g. (a) is an Abstract)
+// or merely group it (e.g. (a + b) × c) depends on where the term is used.
+// It never leaves the parser.
+type parenthesized struct{ substitutable }
 
-			fallthrough
-		case t.v == want:
-			found = true
-			break loop
+func unparen(a substitutable) substitutable {
+	for {
+		p, ok := a.(parenthesized)
+		if !ok {
+			return a
 		}
-
-		bw = append(bw, t)
-	}
-	if !found {
-		return errors.Errorf("Could not find a corresponding %q in expression. Unable to resolveGroup. Popped Infix (in backwards order) %v", want, bw)
+		a = p.substitutable
 	}
-	reverse(bw)
-	backup := p.infixStack
+}
 
-	p.infixStack = bw
-	if err := p.resolveAllInfix(); err != nil {
-		return errors.Wrapf(err, "Unable to resolveGroup. Group: %q", want)
+// asExpr converts a parsed term into an Expr. `at` is where the term starts.
+func (p *parser) asExpr(a substitutable, at tok) (Expr, error) {
+	if pa, ok := a.(parenthesized); ok {
+		switch in := pa.substitutable.(type) {
+		case Size:
+			return Shape{int(in)}, nil
+		case Var:
+			return Abstract{in}, nil
+		case BinOp:
+			return Abstract{in}, nil
+		case E2:
+			return Abstract{in.BinOp}, nil
+		case UnaryOp:
+			return Abstract{in}, nil
+		case SliceOf:
+			return Abstract{sizelikeSliceOf{in}}, nil
+		default:
+			return p.asExpr(in, at)
+		}
 	}
-	if len(p.infixStack) > 0 {
-		// error? TODO
+	if e, ok := a.(Expr); ok {
+		return e, nil
 	}
-	p.infixStack = backup
-	return nil
+	return nil, p.errorAt(at, []string{"expression"}, "Expected an expression. Got %v of %T instead", a, a)
 }
 
-// resolveA resolves an Abstract{}. If it can be turned into a Shape{}, then the shape will be returned.
-func (p *parser) resolveA() error {
-	if err := p.resolveGroup('('); err != nil {
-		return errors.Wrap(err, "Unable to resolveA.")
+// asOperand converts a parsed term into an operand of a binary operation.
+func (p *parser) asOperand(a substitutable, at tok) (Expr, error) {
+	switch x := unparen(a).(type) {
+	case BinOp:
+		return E2{x}, nil
+	case Expr:
+		return x, nil
 	}
+	return p.asExpr(a, at)
+}
 
-	last := p.pop()
-	if abs, ok := last.(Abstract); ok {
-		if shp, ok := abs.ToShape(); ok {
-			p.push(shp)
-			return nil
+// asPostfixOperand converts a parsed term into an operand of slicing or indexing.
+// Unlike asExpr, a parenthesized size expression stays a size expression, e.g. (a + b)[1:3] slices a + b.
+func (p *parser) asPostfixOperand(a substitutable, at tok) (Expr, error) {
+	if _, ok := a.(parenthesized); ok {
+		switch in := unparen(a).(type) {
+		case BinOp:
+			return E2{in}, nil
+		case E2:
+			return in, nil
+		case UnaryOp:
+			return in, nil
 		}
 	}
-	// `last` is an Expr that is not a Shape or Abstract.
-	p.push(last)
-	return nil
+	return p.asExpr(a, at)
 }
 
-// resolveArrow resolves an Arrow.
-func (p *parser) resolveArrow(t tok) error {
-	if err := p.checkItems(2); err != nil {
-		return errors.Wrap(err, "Cannot resolveArrow: Not enough items on stack")
+// asOperation converts a parsed term into an operand of a comparison.
+func (p *parser) asOperation(a substitutable, at tok) (Operation, error) {
+	if op, ok := unparen(a).(Operation); ok {
+		return op, nil
 	}
+	return nil, p.errorAt(at, nil, "Expected an operation. Got %v of %T instead", unparen(a), unparen(a))
+}
 
-	snd, err := p.popExpr()
+/*
+The grammar is as follows, from the loosest binding rule to the tightest:
+
+	list    := expr (',' expr)* [',']
+	expr    := app [('s.t.' | 'where') infix (',' infix)*]
+	app     := arrow ('@' arrow)*
+	arrow   := concat ['→' arrow]
+	concat  := infix (':{' int '}:' infix)*
+	infix   := unary (op unary)*
+	unary   := unop unary | 'T' axes unary | reduct unary
+	         | 'Repeat' sup '{' ints '}' unary
+	         | ('Diag' | 'DiagEmbed') axes '{' int '}' unary
+	         | postfix
+	postfix := primary ('[' slices ']')*
+	primary := int | var | '$' name | '(' [list] ')' | '[' slices ']' | axes | 'Sz' ints | '{' expr '|' infix (',' infix)* '}'
+	axes    := 'X' ints
+	ints    := '[' int* ']'
+
+The infix operators are the logical, comparison and arithmetic operators, whose precedences are given by opprec.
+They are all left associative. The lexer turns ⁽¹ ⁰⁾ into X[1 0].
+*/
+
+// parseTop parses the entire input.
+func (p *parser) parseTop() (Expr, error) {
+	if len(p.queue) == 0 {
+		return nil, p.errorAtEOF([]string{"expression"}, "Empty expression")
+	}
+	start := p.cur()
+	a, _, err := p.parseList()
 	if err != nil {
-		return errors.Wrapf(err, "Cannot resolve snd of arrow at %d as Expr.", t.l)
-	}
-	fst, err := p.popExpr()
-	if err != nil {
-		return errors.Wrapf(err, "Cannot resolve fst of arrow at %d as Expr.", t.l)
+		return nil, err
 	}
-	arr := Arrow{
-		A: fst,
-		B: snd,
+	if t := p.cur(); t.t != eos {
+		return nil, p.errorAt(t, nil, "Unexpected %s after a complete expression", describeTok(t))
 	}
-	p.push(arr)
-	return nil
+	return p.asExpr(a, start)
 }
 
-// resolveComma resolves a comma in group/shape.
-func (p *parser) resolveComma(t tok) error {
-	// comma is a binary option. However if it's a trailing comma, then there is no need.
-	snd := p.pop()
-
-	// If it's a trailing comma:
-	// 	(a, )
-	// then the stacks will look something like this:
-	// 	[a] ['(' ',' ]
-	// Thus after the stack is popped
-	// 	[] ['(']  | WORKING: snd == 'a'
-	if len(p.stack) == 0 {
-		switch s := snd.(type) {
-		case Abstract:
-			p.push(s)
-		case Sizelike:
-			p.push(Abstract{s})
-		default:
-			return errors.Errorf("Failed to resolveComma. Cannot handle %v of %T as a Sizelike.", snd, snd)
+// parseList parses a comma separated list of expressions.
+// If there is more than one expression (or a trailing comma), they are concatenated into a Shape or Abstract.
+func (p *parser) parseList() (retVal substitutable, isTuple bool, err error) {
+	p.logstate("list")
+	start := p.cur()
+	if retVal, err = p.parseExpr(); err != nil {
+		return nil, false, err
+	}
+	if p.cur().t != comma {
+		return retVal, false, nil
+	}
+
+	// the elements are kept on the parser's scratch stacks, which nested lists push onto.
+	base := len(p.elems)
+	defer p.popElems(base)
+	p.elems = append(p.elems, retVal)
+	p.starts = append(p.starts, start)
+	for p.cur().t == comma {
+		p.next()
+		if t := p.cur(); t.t == parenR || t.t == eos {
+			break // trailing comma
 		}
-		return nil
+		start = p.cur()
+		var elem substitutable
+		if elem, err = p.parseExpr(); err != nil {
+			return nil, false, err
+		}
+		p.elems = append(p.elems, elem)
+		p.starts = append(p.starts, start)
+	}
+	retVal, err = p.cons(p.elems[base:], p.starts[base:])
+	return retVal, true, err
+}
+
+// popElems truncates the scratch stacks of list elements to n.
+func (p *parser) popElems(n int) {
+	for i := n; i < len(p.elems); i++ {
+		p.elems[i] = nil
 	}
+	p.elems = p.elems[:n]
+	p.starts = p.starts[:n]
+}
 
-	// if the length is not 0, then there are more values to pop off the stack.
-	fst := p.pop()
-	switch f := fst.(type) {
-	case Abstract:
-		switch s := snd.(type) {
-		case Sizelike:
-			f = append(f, s)
-			p.push(f)
-			return nil
-		case Conser:
-			ret := f.Cons(s).(substitutable)
-			p.push(ret)
-			return nil
-		}
-	case Shape:
-		switch s := snd.(type) {
-		case Size:
-			f = append(f, int(s))
-			p.push(f)
-			return nil
-		case Sizelike:
-			f2 := f.toAbs(len(f) + 1)
-			f2 = append(f2, s)
-			p.push(f2)
-			return nil
-		case Conser:
-			ret := f.Cons(s).(substitutable)
-			p.push(ret)
-			return nil
-		}
-	case Sizelike:
-		switch s := snd.(type) {
-		case Sizelike:
-			p.push(Abstract{f, s})
-			return nil
+// cons concatenates the elements of a list into a Shape or an Abstract.
+func (p *parser) cons(elems []substitutable, starts []tok) (Expr, error) {
+	abs := make(Abstract, 0, len(elems))
+	for i, elem := range elems {
+		if _, ok := elem.(parenthesized); ok {
+			e, err := p.asExpr(elem, starts[i])
+			if err != nil {
+				return nil, err
+			}
+			elem = e
+		}
+		switch e := elem.(type) {
 		case Shape:
-			abs := Abstract{f}
-			ret := abs.Cons(s).(substitutable)
-			p.push(ret)
-			return nil
+			for _, d := range e {
+				abs = append(abs, Size(d))
+			}
 		case Abstract:
-			s = append(s, f)
-			copy(s[1:], s[0:])
-			s[0] = f
-			p.push(s)
-			return nil
+			abs = append(abs, e...)
+		case SliceOf:
+			abs = append(abs, sizelikeSliceOf{e})
+		case Sizelike:
+			abs = append(abs, e)
+		default:
+			return nil, p.errorAt(starts[i], nil, "Expected a dimension. Got %v of %T instead", elem, elem)
 		}
 	}
-	return errors.Errorf("Unable to resolveComma. Arrived at an unreachable state. Check your input expression.")
+	if shp, ok := abs.ToShape(); ok {
+		return shp, nil
+	}
+	return abs, nil
 }
 
-// resolveUnOp resolves a unary op.
-func (p *parser) resolveUnOp(t tok) error {
-	if err := p.checkItems(1); err != nil {
-		return errors.Wrap(err, "Unable to resolveUnOp.")
+// parseExpr parses a single expression, which may be followed by a `s.t.` or `where` clause.
+func (p *parser) parseExpr() (substitutable, error) {
+	p.logstate("expr")
+	start := p.cur()
+	a, err := p.parseApp()
+	if err != nil || p.cur().t != kwWhere {
+		return a, err
 	}
-	expr, err := p.popExpr()
+	p.next()
+	e, err := p.asExpr(a, start)
 	if err != nil {
-		return errors.Wrapf(err, "Cannot resolve expr of unop %c at %d.", t.v, t.l)
+		return nil, err
 	}
-	op, err := parseOpType(t.v)
+	st, err := p.parseConstraints()
 	if err != nil {
-		return errors.Wrapf(err, "Unable to parse UnOp OpType %v", t)
+		return nil, err
 	}
-	o := UnaryOp{
-		Op: op,
-		A:  expr,
+	return Compound{Expr: e, SubjectTo: st}, nil
+}
+
+// parseConstraints parses a comma separated list of constraints, which are combined with ∧.
+func (p *parser) parseConstraints() (retVal SubjectTo, err error) {
+	for i := 0; ; i++ {
+		start := p.cur()
+		var c substitutable
+		if c, err = p.parseInfix(0); err != nil {
+			return retVal, err
+		}
+		st, ok := unparen(c).(SubjectTo)
+		if !ok {
+			return retVal, p.errorAt(start, []string{"constraint"}, "Expected a constraint. Got %v instead", unparen(c))
+		}
+		if i == 0 {
+			retVal = st
+		} else {
+			retVal = SubjectTo{OpType: And, A: retVal, B: st}
+		}
+		if p.cur().t != comma {
+			return retVal, nil
+		}
+		p.next()
 	}
-	p.push(o)
-	return nil
 }
 
-// resolveBinOp resolves a binary op.
-func (p *parser) resolveBinOp(t tok) error {
-	if err := p.checkItems(2); err != nil {
-		return errors.Wrap(err, "Unable to resolveBinOp")
+// parseApp parses an application, e.g. (a, b) → (b, c) → (a, c) @ (2, 3). Applications are left associative.
+func (p *parser) parseApp() (substitutable, error) {
+	p.logstate("app")
+	if p.appDepth++; p.appDepth == 1 {
+		p.argSpans = p.argSpans[:0]
+	}
+	defer func() { p.appDepth-- }()
+	start := p.cur()
+	lhs, err := p.parseArrow()
+	for err == nil && p.cur().t == appop {
+		p.next()
+		var a, b Expr
+		var rhs substitutable
+		if a, err = p.asExpr(lhs, start); err != nil {
+			return nil, err
+		}
+		rstart := p.cur()
+		if rhs, err = p.parseArrow(); err != nil {
+			return nil, err
+		}
+		if b, err = p.asExpr(rhs, rstart); err != nil {
+			return nil, err
+		}
+		if p.appDepth == 1 {
+			p.argSpans = append(p.argSpans, p.spanFrom(rstart))
+		}
+		lhs = Application{A: a, B: b}
 	}
+	return lhs, err
+}
 
-	snd, err := p.popExpr()
-	if err != nil {
-		return errors.Wrapf(err, "Unable to resolve snd of BinOp at %d as Expr.", t.l)
+// parseArrow parses an arrow. Arrows are right associative.
+func (p *parser) parseArrow() (substitutable, error) {
+	p.logstate("arrow")
+	start := p.cur()
+	lhs, err := p.parseConcat()
+	if err != nil || p.cur().t != arrow {
+		return lhs, err
 	}
-	fst, err := p.popExpr()
+	p.next()
+	a, err := p.asExpr(lhs, start)
 	if err != nil {
-		return errors.Wrapf(err, "Unable to resolve fst of BinOp at %d as Expr.", t.l)
+		return nil, err
 	}
-
-	op, err := parseOpType(t.v)
+	start = p.cur()
+	rhs, err := p.parseArrow()
 	if err != nil {
-		return errors.Wrapf(err, "Unable to parse BinOp OpType %v", t)
+		return nil, err
 	}
-
-	o := BinOp{
-		Op: op,
-		A:  fst,
-		B:  snd,
+	b, err := p.asExpr(rhs, start)
+	if err != nil {
+		return nil, err
 	}
-	p.push(o)
-	return nil
+	return Arrow{A: a, B: b}, nil
 }
 
-// resolveCmpOp resolves a comparison op.
-func (p *parser) resolveCmpOp(t tok) error {
-	if err := p.checkItems(2); err != nil {
-		return errors.Wrap(err, "Unable to resolveCmpOp")
-	}
-
-	snd := p.pop()
-	sndOp, ok := snd.(Operation)
-	if !ok {
-		return errors.Errorf("Cannot resolve snd of CmpOp %c at %d as Operation. Got %v of %T instead ", t.v, t.l, snd, snd)
-	}
-	fst := p.pop()
-	fstOp, ok := fst.(Operation)
-	if !ok {
-		return errors.Errorf("Cannot resolve fst of CmpOp %c at %d as Operation. Got %v of %T instead.", t.v, t.l, fst, fst)
+// parseConcat parses A :{n}: B. Concatenations are left associative.
+func (p *parser) parseConcat() (substitutable, error) {
+	p.logstate("concat")
+	start := p.cur()
+	lhs, err := p.parseInfix(0)
+	for err == nil && p.cur().t == colon && p.peek().t == braceL {
+		p.next()
+		p.next()
+		var along int
+		var a, b Expr
+		var rhs substitutable
+		if along, err = p.parseInt(); err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(braceR, "'}'"); err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(colon, "':'"); err != nil {
+			return nil, err
+		}
+		if a, err = p.asExpr(lhs, start); err != nil {
+			return nil, err
+		}
+		rstart := p.cur()
+		if rhs, err = p.parseInfix(0); err != nil {
+			return nil, err
+		}
+		if b, err = p.asExpr(rhs, rstart); err != nil {
+			return nil, err
+		}
+		lhs = ConcatOf{Along: Axis(along), A: a, B: b}
 	}
+	return lhs, err
+}
 
-	op, err := parseOpType(t.v)
+// parseInfix parses the binary, comparison and logical operators by precedence climbing.
+func (p *parser) parseInfix(minPrec int) (substitutable, error) {
+	p.logstate("infix %d", minPrec)
+	start := p.cur()
+	lhs, err := p.parseUnary()
 	if err != nil {
-		return errors.Wrapf(err, "Unable to parse CmpOp OpType %v", t)
+		return nil, err
 	}
-
-	o := SubjectTo{
-		OpType: op,
-		A:      fstOp,
-		B:      sndOp,
+	for {
+		t := p.cur()
+		if t.t != binop && t.t != cmpop && t.t != logop {
+			break
+		}
+		prec := opprec[rune(t.v)]
+		if prec < minPrec {
+			break
+		}
+		p.next()
+		rstart := p.cur()
+		rhs, err := p.parseInfix(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		if lhs, err = p.makeInfix(t, lhs, rhs, start, rstart); err != nil {
+			return nil, err
+		}
 	}
-	p.push(o)
-	return nil
-
+	return lhs, nil
 }
 
-// resolveLogOp resolves a logical op.
-func (p *parser) resolveLogOp(t tok) error {
-	if err := p.checkItems(2); err != nil {
-		return errors.Wrap(err, "Unable to resolveLogOp")
-	}
-
-	snd := p.pop()
-	sndOp, ok := snd.(Operation)
-	if !ok {
-		return errors.Errorf("Cannot resolve snd of LogOp %c at %d as Operation. Got %T instead ", t.v, t.l, snd)
-	}
-	fst := p.pop()
-	fstOp, ok := fst.(Operation)
-	if !ok {
-		return errors.Errorf("Cannot resolve fst of LogOp %c at %d as Operation. Got %v of %T instead", t.v, t.l, fst, fst)
-	}
-
-	op, err := parseOpType(t.v)
+// makeInfix creates the term for an infix operator.
+func (p *parser) makeInfix(t tok, a, b substitutable, aStart, bStart tok) (substitutable, error) {
+	op, err := parseOpType(rune(t.v))
 	if err != nil {
-		return errors.Wrapf(err, "Unable to parse LogOp OpType %v", t)
+		return nil, p.errorAt(t, nil, "%v", err)
 	}
-
-	o := SubjectTo{
-		OpType: op,
-		A:      fstOp,
-		B:      sndOp,
+	switch t.t {
+	case binop:
+		A, err := p.asOperand(a, aStart)
+		if err != nil {
+			return nil, err
+		}
+		B, err := p.asOperand(b, bStart)
+		if err != nil {
+			return nil, err
+		}
+		return BinOp{Op: op, A: A, B: B}, nil
+	case cmpop:
+		A, err := p.asOperation(a, aStart)
+		if err != nil {
+			return nil, err
+		}
+		B, err := p.asOperation(b, bStart)
+		if err != nil {
+			return nil, err
+		}
+		return SubjectTo{OpType: op, A: A, B: B}, nil
 	}
-	if !o.isValid() {
-		return errors.Errorf("SubjectTo %v is not a valid SubjectTo", o)
+
+	// logical operators
+	A, aok := unparen(a).(SubjectTo)
+	B, bok := unparen(b).(SubjectTo)
+	switch {
+	case aok && bok:
+		return SubjectTo{OpType: op, A: A, B: B}, nil
+	case op == Or:
+		// a || b, where a and b are not constraints, is the result of broadcasting a and b.
+		A, err := p.asExpr(a, aStart)
+		if err != nil {
+			return nil, err
+		}
+		B, err := p.asExpr(b, bStart)
+		if err != nil {
+			return nil, err
+		}
+		return BroadcastOf{A: A, B: B}, nil
+	case !aok:
+		return nil, p.errorAt(aStart, []string{"constraint"}, "Expected a constraint. Got %v instead", unparen(a))
+	default:
+		return nil, p.errorAt(bStart, []string{"constraint"}, "Expected a constraint. Got %v instead", unparen(b))
 	}
-	p.push(o)
-	return nil
 }
 
-// resolveCompound expects the stack to look like this:
-// 	[..., Expr, SubjectTo{...}]
-// The result will look like this
-// 	[..., Compound{...}] (the Compound{} now has data)
-func (p *parser) resolveCompound() error {
-	if err := p.checkItems(2); err != nil {
-		return errors.Wrap(err, "Unable to resolveCompound")
-	}
-	if err := p.checkInfix(1); err != nil {
-		return errors.Wrap(err, "Unable to resolveCompound: Cannot find '{'")
+// parseUnary parses the prefix operators.
+func (p *parser) parseUnary() (substitutable, error) {
+	p.logstate("unary")
+	t := p.cur()
+	switch t.t {
+	case unop:
+		p.next()
+		op, err := parseOpType(rune(t.v))
+		if err != nil {
+			return nil, p.errorAt(t, nil, "%v", err)
+		}
+		A, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return UnaryOp{Op: op, A: A}, nil
+	case transposeop:
+		p.next()
+		axes, err := p.parseAxes()
+		if err != nil {
+			return nil, err
+		}
+		A, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return TransposeOf{Axes: axes, A: A}, nil
+	case reduct:
+		p.next()
+		A, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return ReductOf{A: A, Along: Axis(t.v)}, nil
+	case kwRepeat:
+		p.next()
+		along, err := p.expect(sup, "superscript axis")
+		if err != nil {
+			return nil, err
+		}
+		open, err := p.expect(braceL, "'{'")
+		if err != nil {
+			return nil, err
+		}
+		ints, err := p.parseInts()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expectClosing(open, braceR, "'}'"); err != nil {
+			return nil, err
+		}
+		A, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		var repeats []Size
+		for _, i := range ints {
+			repeats = append(repeats, Size(i))
+		}
+		return RepeatOf{Along: Axis(along.v), Repeats: repeats, A: A}, nil
+	case kwDiag, kwDiagEmbed:
+		p.next()
+		axes, err := p.parseAxes()
+		if err != nil {
+			return nil, err
+		}
+		if len(axes) != 2 {
+			return nil, p.errorAt(t, nil, "Expected exactly 2 axes for %s. Got %v instead", describeTok(t), axes)
+		}
+		open, err := p.expect(braceL, "'{'")
+		if err != nil {
+			return nil, err
+		}
+		offset, err := p.parseInt()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expectClosing(open, braceR, "'}'"); err != nil {
+			return nil, err
+		}
+		A, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if t.t == kwDiag {
+			return DiagOf{Offset: offset, Axis1: axes[0], Axis2: axes[1], A: A}, nil
+		}
+		return DiagEmbedOf{Offset: offset, Axis1: axes[0], Axis2: axes[1], A: A}, nil
 	}
+	return p.parsePostfix()
+}
 
-	// find '{'
-	opening := p.popInfix()
-	if opening.t != braceL {
-		return errors.Errorf("Unable to resolveCompound. No corresponding'{' for a given '}")
+// parseOperand parses the operand of a prefix operator.
+func (p *parser) parseOperand() (Expr, error) {
+	start := p.cur()
+	a, err := p.parseUnary()
+	if err != nil {
+		return nil, err
 	}
+	return p.asExpr(a, start)
+}
 
-	// first check
-	var st SubjectTo
-	var e Expr
-	var ok bool
-
-	top := p.pop() // SubjectTo
-	if st, ok = top.(SubjectTo); !ok {
-		return errors.Errorf("Expected Top of Stack to be a SubjectTo is %v of %T. Stack: %v", top, top, p.stack)
-	}
-	snd := p.pop() // Expr
-	if e, ok = snd.(Expr); !ok {
-		return errors.Errorf("Expected Second of Stack to be a Expr is %v of %T. Stack: %v", snd, snd, p.stack)
-	}
-	c := Compound{
-		Expr:      e,
-		SubjectTo: st,
+// parsePostfix parses slicing and indexing, e.g. a[0:2] and a[0].
+func (p *parser) parsePostfix() (substitutable, error) {
+	start := p.cur()
+	a, err := p.parsePrimary()
+	for err == nil && p.cur().t == brackL {
+		var A Expr
+		var b bracketed
+		if A, err = p.asPostfixOperand(a, start); err != nil {
+			return nil, err
+		}
+		if b, err = p.parseBrackets(); err != nil {
+			return nil, err
+		}
+		switch {
+		case b.isVar:
+			a = SliceOf{Slice: b.v, A: A}
+		case b.isIndex:
+			a = IndexOf{I: Size(b.ranges[0].start), A: A}
+		case len(b.ranges) == 1 && !b.isList:
+			a = SliceOf{Slice: b.ranges[0], A: A}
+		default:
+			slices := make(Slices, 0, len(b.ranges))
+			for _, r := range b.ranges {
+				slices = append(slices, r)
+			}
+			a = SliceOf{Slice: slices, A: A}
+		}
 	}
+	return a, err
+}
 
-	p.push(c)
-	return nil
+// bracketed is the contents of a `[...]`.
+type bracketed struct {
+	ranges  []Range
+	v       Var
+	isVar   bool // e.g. a[b]
+	isIndex bool // a single number without any colons, e.g. a[0]
+	isList  bool // there is a comma, e.g. a[0:2, 1:3] or a[0:2,]
 }
 
-// resolveSlice resolves a slice. It calls resolveGroup().
-func (p *parser) resolveSlice() error {
-	// five cases:
-	// 1. single slice (e.g. a[0])
-	// 2. range (e.g. a[0:2])
-	// 3. stepped range (e.g. a[0:2:2])
-	// 4. open range (e.g. a[1:]) CURRENTLY UNSUPPORTED. TODO.
-	// 5. limit range (e.g. a[:2]) CURRENTLY UNSUPPORTED. TODO.
-
-	// pop infixStack - this will handle any of the cases with colons.
-	if err := p.resolveGroup('['); err != nil {
-		return errors.Wrap(err, "Unable to resolveSlice.")
-	}
-
-	// resolve any potential SliceOf{} or IndexOf{}
-	if err := p.checkItems(1); err != nil {
-		return errors.Wrap(err, "Unable to resolveSlice. Expected at least a SliceOf{} or an IndexOf{}")
-	}
-	top := p.pop()
-
-	// check for case 1
-	var idxof bool
-	var idx int
-	var slice Range
-	switch t := top.(type) {
-	case Range:
-		slice = t
-	case Size:
-		idx = int(t)
-		idxof = true
-	case Axes:
-		// oops it's not actually a slice
-		p.push(top)
-		return nil
-	default:
-		return errors.Errorf("Unable to resolveSlice. top can either be Sli or Size. Got %v of %T instead", top, top)
+// parseBrackets parses the contents of a `[...]`. It is a comma separated list of start[:end[:step]] or a single variable.
+// The list may have a trailing comma, so that a list of one range may be told apart from the range alone.
+func (p *parser) parseBrackets() (retVal bracketed, err error) {
+	open := p.next()
+	if t := p.cur(); t.t == letter {
+		p.next()
+		retVal.v = Var(t.v)
+		retVal.isVar = true
+		_, err = p.expectClosing(open, brackR, "']'")
+		return retVal, err
 	}
 
-	if err := p.checkItems(1); err != nil {
-		return errors.Wrap(err, "Unable to resolveSlice. Expected at least one item on the stack.")
-	}
-	snd := p.pop()
-	so, ok := snd.(SliceOf)
-	if !ok {
-		p.push(snd)
-		// check if idxof is true
-		if idxof {
-			// top should no longer just be an int
-			top = Range{start: idx, end: idx + 1, step: 1}
+	for {
+		var r Range
+		if r.start, err = p.parseInt(); err != nil {
+			return retVal, err
+		}
+		r.end, r.step = r.start+1, 1
+		retVal.isIndex = true
+		if p.cur().t == colon {
+			p.next()
+			retVal.isIndex = false
+			if r.end, err = p.parseInt(); err != nil {
+				return retVal, err
+			}
+			if p.cur().t == colon {
+				p.next()
+				if r.step, err = p.parseInt(); err != nil {
+					return retVal, err
+				}
+			}
+		}
+		retVal.ranges = append(retVal.ranges, r)
+		if p.cur().t != comma {
+			break
+		}
+		p.next()
+		retVal.isList = true
+		if p.cur().t == brackR {
+			break
 		}
-		p.push(top)
-		return nil
-	}
-
-	// if it's ok, then the third from the stack would be an Expr
-	thd := p.pop().(Expr)
-	if idxof {
-		// then use IndexOf instead of SliceOf
-		iof := IndexOf{I: Size(idx), A: thd}
-		p.push(iof)
-		return nil
 	}
-	so.Slice = slice
-	so.A = thd
-	p.push(so)
-	return nil
+	retVal.isIndex = retVal.isIndex && !retVal.isList
+	_, err = p.expectClosing(open, brackR, "']'")
+	return retVal, err
 }
 
-// resolveColon resolves a colon much like resolveComma.
-func (p *parser) resolveColon() error {
-	// five cases:
-	// 1. single slice (e.g. a[0])
-	// 2. range (e.g. a[0:2])
-	// 3. stepped range (e.g. a[0:2:2])
-	// 4. open range (e.g. a[1:]) CURRENTLY UNSUPPORTED. TODO.
-	// 5. limit range (e.g. a[:2]) CURRENTLY UNSUPPORTED. TODO.
-
-	if err := p.checkItems(2); err != nil {
-		return errors.Wrap(err, "Unable to resolveColon.")
-	}
-
-	// a colon is a binop
-	snd := p.pop()
-	fst := p.pop()
-
-	switch s := snd.(type) {
-	case Size:
-		// case 2
-		f, ok := substToInt(fst)
+// parsePrimary parses the terms that are not made of smaller terms joined by operators.
+func (p *parser) parsePrimary() (substitutable, error) {
+	p.logstate("primary")
+	t := p.cur()
+	switch t.t {
+	case digit:
+		p.next()
+		return Size(t.v), nil
+	case letter:
+		p.next()
+		return Var(t.v), nil
+	case ref:
+		p.next()
+		name := p.refName(t)
+		e, ok := p.env[name]
 		if !ok {
-			return errors.Errorf("Expected fst to be a Size. Got %v of %T instead", fst, fst)
+			return nil, p.errorAt(t, nil, "Undefined signature %q", name)
 		}
-		retVal := Range{start: f, end: int(s), step: 1}
-		p.push(retVal)
-	case Range:
-		// case 3
-		f, ok := substToInt(fst)
-		if !ok {
-			return errors.Errorf("Expected fst to be a Size. Got %v of %T instead", fst, fst)
+		return e, nil
+	case parenL:
+		return p.parseGroup()
+	case brackL:
+		b, err := p.parseBrackets()
+		if err != nil {
+			return nil, err
 		}
-		s.step = s.end
-		s.end = s.start
-		s.start = f
-		p.push(s)
-	default:
-		return errors.Errorf("Unsupported: case 4 and 5")
-
-	}
-	return nil
-}
-func (p *parser) resolveAxes() error {
-	var bw Axes
-	for i := len(p.stack) - 1; i >= 0; i-- {
-		t := p.pop()
-		if v, ok := t.(Var); ok && v == Var('X') {
-			break
+		if b.isVar || len(b.ranges) != 1 {
+			return nil, p.errorAt(t, nil, "Expected a single range")
 		}
-		ax, ok := substToInt(t)
-		if !ok {
-			return errors.Errorf("Failed to resolveAxes. %dth item in stack is expected to be an int-like. Got %v of %T instead", i, t, t)
+		return b.ranges[0], nil
+	case axesL:
+		return p.parseAxes()
+	case kwSizes:
+		p.next()
+		ints, err := p.parseInts()
+		if err != nil {
+			return nil, err
 		}
-		bw = append(bw, Axis(ax))
+		retVal := make(Sizes, 0, len(ints))
+		for _, i := range ints {
+			retVal = append(retVal, Size(i))
+		}
+		return retVal, nil
+	case braceL:
+		return p.parseCompound()
+	case eos:
+		return nil, p.errorAt(t, []string{"expression"}, "Unexpected end of input")
 	}
-	reverseAxes(bw)
-	p.push(bw)
-	return nil
+	return nil, p.errorAt(t, []string{"expression"}, "Unexpected %s", describeTok(t))
 }
 
-// resolveTranspose is a janky way of resolving a transpose operator.
-func (p *parser) resolveTranspose() error {
-	p.incrQPtr()
-	backup1 := p.stack
-	backup2 := p.infixStack
+// refName returns the name of the signature referenced by a ref token.
+func (p *parser) refName(t tok) string { return p.lx.names[t.v] }
 
-	// check
-	if err := p.checkEOF(); err != nil {
-		return errors.Wrap(err, "Dangling T operator.")
+// parseGroup parses `(...)`.
+func (p *parser) parseGroup() (substitutable, error) {
+	open := p.next()
+	switch p.cur().t {
+	case parenR:
+		p.next()
+		return Shape{}, nil
+	case eos:
+		return nil, p.errorAt(open, []string{"')'", "expression"}, "Dangling open paren '('")
 	}
-
-	p.stack = nil
-	p.infixStack = nil
-	if err := p.expectAxes(); err != nil {
-		return errors.Wrapf(err, " failed to transpose")
+	a, isTuple, err := p.parseList()
+	if err != nil {
+		return nil, err
 	}
-	axes := p.stack[len(p.stack)-1].(Axes)
-
-	p.stack = nil
-	p.infixStack = nil
-	if err := p.expectExpr(); err != nil {
-		return errors.Wrap(err, "failed to transposeOf")
+	if _, err = p.expectClosing(open, parenR, "')'"); err != nil {
+		return nil, err
 	}
-
-	A, ok := p.stack[len(p.stack)-1].(Expr)
-	if !ok {
-		return errors.Errorf("Cannot resolveTranspose. Expected the top of the stack to be an Expr. Got %v of %T instead", p.stack[len(p.stack)-1], p.stack[len(p.stack)-1])
+	if isTuple {
+		return a, nil
 	}
-
-	p.stack = backup1
-	p.infixStack = backup2
-	p.push(TransposeOf{Axes: axes, A: A})
-	return nil
-
+	return parenthesized{a}, nil
 }
 
-// expectAxes expects the next expression in the queue to be an Axes. Used only for transpose
-func (p *parser) expectAxes() error {
-	x, _ := p.cur() // will never err
-	if x.v != 'X' {
-		return errors.Errorf("Expected 'X'. Got %q instead", x.v)
+// parseCompound parses `{ expr | constraints }`.
+func (p *parser) parseCompound() (substitutable, error) {
+	open := p.next()
+	start := p.cur()
+	a, err := p.parseExpr()
+	if err != nil {
+		return nil, err
 	}
-	p.incrQPtr()
-
-	lbrack, err := p.cur()
+	e, err := p.asExpr(a, start)
 	if err != nil {
-		return errors.Wrap(err, "Dangling X operator.")
+		return nil, err
 	}
-	if lbrack.v != '[' {
-		return errors.Errorf("Expected '[. Got %q instead.", lbrack.v)
+	if _, err = p.expectClosing(open, pipe, "'|'"); err != nil {
+		return nil, err
 	}
+	st, err := p.parseConstraints()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = p.expectClosing(open, braceR, "'}'"); err != nil {
+		return nil, err
+	}
+	return Compound{Expr: e, SubjectTo: st}, nil
+}
 
-	p.incrQPtr()
-
-	var axes Axes
-	var next tok
-	for next, err = p.cur(); err == nil && next.v != ']'; next, err = p.cur() {
-		if next.t != digit {
-			// TODO: error?
-		}
-		axes = append(axes, Axis(next.v))
-
-		p.incrQPtr()
+// parseAxes parses X[...]. ⁽...⁾ is lexed into the same tokens.
+func (p *parser) parseAxes() (Axes, error) {
+	x := p.cur()
+	if x.t != axesL {
+		return nil, p.errorAt(x, []string{"'X'"}, "")
 	}
+	p.next()
+	if t := p.cur(); t.t != brackL {
+		return nil, p.errorAt(t, []string{"'['"}, "")
+	}
+	ints, err := p.parseInts()
 	if err != nil {
-		return errors.Wrap(err, "Danging X[... operator.")
+		return nil, err
 	}
-
-	p.incrQPtr() // because this was not automatically incremented
-	p.push(axes)
-	return nil
+	retVal := make(Axes, 0, len(ints))
+	for _, i := range ints {
+		retVal = append(retVal, Axis(i))
+	}
+	return retVal, nil
 }
 
-// expectExpr parses the next Expr from the queue. Used only for transpose.
-func (p *parser) expectExpr() error {
+// parseInts parses a space separated list of integers in square brackets, e.g. [1 2 3].
+func (p *parser) parseInts() (retVal []int, err error) {
+	open, err := p.expect(brackL, "'['")
+	if err != nil {
+		return nil, err
+	}
 	for {
-		if err := p.parseOne(); err != nil {
-			return errors.Wrap(err, "Failed to expect Expr")
+		t := p.cur()
+		switch {
+		case t.t == brackR:
+			p.next()
+			return retVal, nil
+		case t.t == eos:
+			return nil, p.errorAt(open, []string{"']'"}, "Unclosed '['")
+		case t.t != digit && !(t.t == binop && t.v == '-'):
+			return nil, p.errorAt(t, []string{"number", "']'"}, "")
 		}
-
-		if len(p.infixStack) == 0 {
-			if _, ok := p.stack[0].(Expr); ok {
-				break
-			}
+		var i int
+		if i, err = p.parseInt(); err != nil {
+			return nil, err
 		}
-		p.incrQPtr() // because this will not be automatically incremented as we are working outside the regular scheme.
+		retVal = append(retVal, i)
 	}
-	return nil
 }
 
-// operator precedence table
+// parseInt parses an integer, which may be negative.
+func (p *parser) parseInt() (int, error) {
+	var neg bool
+	if t := p.cur(); t.t == binop && t.v == '-' {
+		p.next()
+		neg = true
+	}
+	t := p.cur()
+	if t.t != digit {
+		return 0, p.errorAt(t, []string{"number"}, "")
+	}
+	p.next()
+	if neg {
+		return -t.v, nil
+	}
+	return t.v, nil
+}
+
+// operator precedence table of the infix operators. The higher the number, the tighter the binding.
 var opprec = map[rune]int{
-	'(': 70,
-	')': -1,
-	'[': 1,
-	']': -1,
-	'{': 70,
-	'}': -1,
-	',': 2,
-	':': 75,
-	'|': -1,
 	'→': 0,
 
 	// unop
@@ -965,202 +1077,9 @@ var opprec = map[rune]int{
 	'>': 30,
 	'≤': 30,
 	'≥': 30,
+	'⚟': 30,
 
 	// logop
 	'∧': 20,
 	'∨': 10,
-
-	// axes
-	'X': 60,
-
-	// TransposeOf
-	'T': 60,
-}
-
-type tokentype int
-
-const (
-	eos tokentype = iota
-	parenL
-	parenR
-	brackL
-	brackR
-	axesL // use brackR for closing
-	braceL
-	braceR
-	digit
-	letter
-	comma
-	arrow
-	colon
-	pipe
-	unop
-	binop
-	cmpop
-	logop
-	transposeop
-)
-
-type tok struct {
-	t tokentype // type
-	v rune      // value of the token
-	l int       // location
-}
-
-func (t tok) Format(s fmt.State, c rune) {
-	switch t.t {
-	case letter:
-		fmt.Fprintf(s, "{%c %d}", t.v, t.l)
-	case digit:
-		fmt.Fprintf(s, "{%d %d}", t.v, t.l)
-	case eos:
-		fmt.Fprintf(s, "{EOS %d}", t.l)
-	default:
-		fmt.Fprintf(s, "{%c %d}", t.v, t.l)
-	}
-}
-
-const eoserr = "Lex Error: sudden end of string found in %q. Position %d"
-
-// lex is a function that takes a string and returns a slice of tokens.
-//
-// it's fundamentally a giant state table in a for loop. Since the grammar of the language is very strict, it is a fairly straight forwards parse.
-func lex(a string) (retVal []tok, err error) {
-	rs := []rune(a)
-	for i := 0; i < len(rs); i++ {
-		r := rs[i]
-		switch {
-		case r == '(':
-			retVal = append(retVal, tok{parenL, r, i})
-		case r == ')':
-			retVal = append(retVal, tok{parenR, r, i})
-		case r == '[':
-			retVal = append(retVal, tok{brackL, r, i})
-		case r == ']':
-			retVal = append(retVal, tok{brackR, r, i})
-		case r == '{':
-			retVal = append(retVal, tok{braceL, r, i})
-		case r == '}':
-			retVal = append(retVal, tok{braceR, r, i})
-		case r == '→':
-			retVal = append(retVal, tok{arrow, r, i})
-		case r == '-':
-			if i+1 >= len(rs) {
-				return nil, errors.Errorf(eoserr, a, i)
-			}
-			if rs[i+1] == '>' {
-				i++
-				retVal = append(retVal, tok{arrow, '→', i})
-				continue
-			}
-			retVal = append(retVal, tok{binop, r, i})
-		case r == '+', r == '*', r == '×', r == '/', r == '∕', r == '÷':
-			rr := r
-			if r == '*' {
-				rr = '×'
-			}
-			if r == '/' || r == '∕' {
-				rr = '÷'
-			}
-			retVal = append(retVal, tok{binop, rr, i})
-		case r == '=', r == '≠', r == '≥', r == '≤', r == '≥', r == '≤': // single symbol cmp op (note there are TWO acceptable unicode symbols for gte and lte)
-			retVal = append(retVal, tok{cmpop, r, i})
-		case r == '!':
-			if i+1 >= len(rs) {
-				return nil, errors.Errorf(eoserr, a, i)
-			}
-			if rs[i+1] == '=' {
-				i++
-				retVal = append(retVal, tok{cmpop, '≠', i})
-			}
-		case r == '>', r == '<':
-			if i+1 >= len(rs) {
-				return nil, errors.Errorf(eoserr, a, i)
-			}
-			if rs[i+1] == '=' {
-				i++
-				var rr rune
-				switch r {
-				case '>':
-					rr = '≥'
-				case '<':
-					rr = '≤'
-				}
-				retVal = append(retVal, tok{cmpop, rr, i})
-				continue
-			}
-			retVal = append(retVal, tok{cmpop, r, i})
-		case r == '∧', r == '∨', r == '⋀', r == '⋁': // single symbol logical op
-			retVal = append(retVal, tok{logop, r, i})
-		case r == '&':
-			if i+1 >= len(rs) {
-				return nil, errors.Errorf(eoserr, a, i)
-			}
-			if rs[i+1] == '&' { // for people who think AND is written "&&"
-				i++
-				retVal = append(retVal, tok{logop, '∧', i})
-				continue
-			}
-		case r == '|':
-			if i+1 >= len(rs) {
-				return nil, errors.Errorf(eoserr, a, i)
-			}
-			if rs[i+1] == '|' { // for people who think OR is written "||"
-				i++
-				retVal = append(retVal, tok{logop, '∨', i})
-				continue
-			}
-			retVal = append(retVal, tok{pipe, r, i})
-		case r == 'Π', r == 'Σ', r == '∀', r == 'D', r == 'P', r == 'S':
-			rr := r
-			if r == 'P' {
-				rr = 'Π'
-			}
-			if r == 'S' {
-				rr = 'Σ'
-			}
-			retVal = append(retVal, tok{unop, rr, i})
-		case r == 'T':
-			retVal = append(retVal, tok{transposeop, r, i})
-		case r == 'X':
-			retVal = append(retVal, tok{axesL, r, i})
-		case r == ',':
-			retVal = append(retVal, tok{comma, r, i})
-		case r == ':':
-			retVal = append(retVal, tok{colon, r, i})
-		case unicode.IsSpace(r):
-			continue // we ignore spaces and delimiters
-		case unicode.IsDigit(r):
-			var rs2 = []rune{r}
-			var j int
-			for j = i + 1; j < len(rs); j++ {
-				r2 := rs[j]
-				if !unicode.IsDigit(r2) {
-					i = j - 1
-					break
-				}
-				rs2 = append(rs2, r2)
-			}
-			i = j - 1
-			s := string(rs2)
-			num, err := strconv.Atoi(s)
-			if err != nil {
-				return nil, errors.Wrapf(err, "Unable to parse %q as a number", s)
-			}
-
-			retVal = append(retVal, tok{digit, rune(int32(num)), i})
-		case unicode.IsLetter(r):
-			retVal = append(retVal, tok{letter, r, i})
-			// check if next is a letter. if it is  then error out
-			if i+1 >= len(rs) {
-				return retVal, nil
-			}
-			if unicode.IsLetter(rs[i+1]) {
-				return nil, errors.Errorf("Only single letters are allowed as variables.")
-			}
-
-		}
-
-	}
-	return retVal, nil
 }
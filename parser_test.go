@@ -1,9 +1,13 @@
 package shapes
 
 import (
+	"bufio"
+	"fmt"
 	"log"
+	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,6 +56,23 @@ var lexCases = map[string][]tok{
 	},
 	"[0:2:1]": []tok{{brackL, '[', 0}, {digit, 0, 1}, {colon, ':', 2}, {digit, 2, 3}, {colon, ':', 4}, {digit, 1, 5}, {brackR, ']', 6}},
 
+	// superscripts and keywords
+	"T⁽¹ ⁰⁾ a":  []tok{{transposeop, 'T', 0}, {axesL, 'X', 1}, {brackL, '[', 1}, {digit, 1, 2}, {digit, 0, 4}, {brackR, ']', 5}, {letter, 'a', 7}},
+	"/⁰a":       []tok{{reduct, 0, 0}, {letter, 'a', 2}},
+	"/^-1a":     []tok{{reduct, -1, 0}, {letter, 'a', 4}},
+	"a / b":     []tok{{letter, 'a', 0}, {binop, '÷', 2}, {letter, 'b', 4}},
+	"Repeat¹":   []tok{{kwRepeat, 'R', 0}, {sup, 1, 6}},
+	"DiagX[":    []tok{{kwDiag, 'D', 0}, {axesL, 'X', 4}, {brackL, '[', 5}},
+	"Sz[2]":     []tok{{kwSizes, 'S', 0}, {brackL, '[', 2}, {digit, 2, 3}, {brackR, ']', 4}},
+	"forall a":  []tok{{unop, '∀', 0}, {letter, 'a', 7}},
+	"a s.t. b":  []tok{{letter, 'a', 0}, {kwWhere, 'W', 2}, {letter, 'b', 7}},
+	"a where b": []tok{{letter, 'a', 0}, {kwWhere, 'W', 2}, {letter, 'b', 8}},
+	"$mm @ a":   []tok{{ref, 0, 0}, {appop, '@', 4}, {letter, 'a', 6}},
+	"f @ a":     []tok{{letter, 'f', 0}, {appop, '@', 2}, {letter, 'a', 4}},
+	"K a bcast": []tok{{unop, 'K', 0}, {letter, 'a', 2}, {cmpop, '⚟', 4}},
+	"S a, S":    []tok{{unop, 'Σ', 0}, {letter, 'a', 2}, {comma, ',', 3}, {letter, 'S', 5}},
+	"T → S[0]":  []tok{{letter, 'T', 0}, {arrow, '→', 2}, {letter, 'S', 4}, {brackL, '[', 5}, {digit, 0, 6}, {brackR, ']', 7}},
+
 	// dubious API design wise
 	"& a": []tok{{letter, 'a', 2}}, // note that the singular '&' is ignored.
 }
@@ -263,6 +284,33 @@ var parseCases = map[string]Expr{
 
 	// please don't write something like this.
 	"(),(0)": Shape{0},
+
+	// s.t. and where clauses
+	"a → b → b s.t. (Πa = Πb)": Compound{
+		Expr:      MakeArrow(Var('a'), Var('b'), Var('b')),
+		SubjectTo: SubjectTo{Eq, UnaryOp{Prod, Var('a')}, UnaryOp{Prod, Var('b')}},
+	},
+	"a → b → b where Π a = Π b, D a = 2, D b > 1": Compound{
+		Expr: MakeArrow(Var('a'), Var('b'), Var('b')),
+		SubjectTo: SubjectTo{
+			And,
+			SubjectTo{
+				And,
+				SubjectTo{Eq, UnaryOp{Prod, Var('a')}, UnaryOp{Prod, Var('b')}},
+				SubjectTo{Eq, UnaryOp{Dims, Var('a')}, Size(2)},
+			},
+			SubjectTo{Gt, UnaryOp{Dims, Var('b')}, Size(1)},
+		},
+	},
+	"{ a → a | D a = 2, Π a > 1 }": Compound{
+		Expr: Arrow{Var('a'), Var('a')},
+		SubjectTo: SubjectTo{
+			And,
+			SubjectTo{Eq, UnaryOp{Dims, Var('a')}, Size(2)},
+			SubjectTo{Gt, UnaryOp{Prod, Var('a')}, Size(1)},
+		},
+	},
+	"(s, t) → s": Arrow{Abstract{Var('s'), Var('t')}, Var('s')},
 }
 
 /*
@@ -352,3 +400,246 @@ func TestParseBadInputs(t *testing.T) {
 		}
 	}
 }
+
+var parseErrorTests = []struct {
+	in       string
+	pos      int
+	found    string
+	expected []string
+}{
+	{"", 0, "EOF", []string{"expression"}},
+	{"X1000", 4, "number 1000", []string{"'['"}},
+	{"(99999999999999999999)", 1, `"99999999999999999999"`, nil},
+	{"(a, b) → X a", 11, "variable 'a'", []string{"'['"}},
+	{"T X[0 a] b", 6, "variable 'a'", []string{"number", "']'"}},
+	{"((a, b)", 0, "'('", []string{"')'"}},
+	{"(ab)", 2, "'b'", nil},
+	{"a -", 3, "EOF", []string{"'>'", "expression"}},
+	{"(", 0, "'('", []string{"')'", "expression"}},
+	{"a → a s.t. D a = 2, a", 20, "variable 'a'", []string{"constraint"}},
+	{"a → a where", 11, "EOF", []string{"expression"}},
+	{"$f @ (2, 3)", 0, "signature reference", nil},
+	{"$ @ (2, 3)", 0, "'$'", []string{"signature name"}},
+}
+
+func TestParseError(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range parseErrorTests {
+		_, err := Parse(c.in)
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("Expected a *ParseError when parsing %q. Got %v of %T instead", c.in, err, err)
+			continue
+		}
+		assert.Equal(c.in, pe.Input)
+		assert.Equal(c.pos, pe.Pos, "Position of error in %q", c.in)
+		assert.Equal(c.found, pe.Found, "Offending token in %q", c.in)
+		assert.Equal(c.expected, pe.Expected, "Expected tokens in %q", c.in)
+	}
+
+	// every bad input should yield a ParseError
+	for _, in := range badInputs {
+		_, err := Parse(in)
+		var pe *ParseError
+		assert.True(errors.As(err, &pe), "Expected a *ParseError when parsing %q. Got %v of %T instead", in, err, err)
+	}
+}
+
+func ExampleFormatASCII() {
+	expr, _ := Parse("{ a → b → (a||b) | (K a ⚟ K b) }")
+	fmt.Println(expr)
+	fmt.Println(FormatASCII(expr))
+
+	expr, _ = Parse("(a, b) -> T X[1 0] (a, b)")
+	fmt.Println(expr)
+	fmt.Println(FormatASCII(expr))
+
+	// Output:
+	// { a → b → (a||b) | (K a ⚟ K b) }
+	// { a -> b -> (a||b) | (K a bcast K b) }
+	// (a, b) → T⁽¹ ⁰⁾ (a, b)
+	// (a, b) -> TX[1 0] (a, b)
+}
+
+func ExampleParseError() {
+	_, err := Parse("(a, b) → X a")
+	fmt.Println(err)
+
+	// Output:
+	// Expected '[' at position 11 (found variable 'a')
+	// 	(a, b) → X a
+	// 	           ^
+}
+
+var roundTripCases = []Expr{
+	Shape{},
+	Shape{2, 3},
+	Shape{maxInt >> 30, 3}, // 2³³ - 1 on 64 bit platforms, which is larger than a rune
+	Abstract{Var('a'), Size(2), Var('b')},
+	Var('a'),
+	Arrow{Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('a')}},
+	Arrow{Arrow{Var('a'), Var('b')}, Var('c')},
+	Arrow{Var('a'), Arrow{Var('b'), Var('c')}},
+	Abstract{BinOp{Mul, Var('a'), Var('b')}, Var('c')},
+	Abstract{BinOp{Mul, E2{BinOp{Add, Var('a'), Var('b')}}, Var('c')}},
+	Abstract{BinOp{Sub, Var('a'), E2{BinOp{Sub, Var('b'), Var('c')}}}},
+	Abstract{BinOp{Add, E2{BinOp{Mul, Var('a'), Var('b')}}, Var('c')}},
+	Arrow{Var('a'), UnaryOp{Dims, Var('a')}},
+	Arrow{Var('a'), UnaryOp{Prod, Var('a')}},
+	Arrow{Var('a'), UnaryOp{Sum, Var('a')}},
+	Arrow{Var('a'), TransposeOf{Axes{1, 0}, Var('a')}},
+	Arrow{Var('a'), ReductOf{Var('a'), 1}},
+	Arrow{Var('a'), ReductOf{Var('a'), AllAxes}},
+	Arrow{Var('a'), IndexOf{0, Var('a')}},
+	Arrow{Var('a'), SliceOf{Range{0, 2, 1}, Var('a')}},
+	Arrow{Var('a'), SliceOf{Range{1, 6, 2}, Var('a')}},
+	Arrow{Var('a'), SliceOf{Slices{Range{0, 1, 1}, Range{1, 3, 1}}, Var('a')}},
+	Arrow{Var('a'), SliceOf{Slices{Range{0, 1, 1}}, Var('a')}},
+	Arrow{Var('a'), SliceOf{Var('b'), Var('a')}},
+	Arrow{Var('a'), Arrow{Var('b'), ConcatOf{1, Var('a'), Var('b')}}},
+	Arrow{Var('a'), Arrow{Var('b'), BroadcastOf{Var('a'), Var('b')}}},
+	Arrow{Var('a'), RepeatOf{0, []Size{2, 3}, Var('a')}},
+	Arrow{Var('a'), DiagOf{0, 0, 1, Var('a')}},
+	Arrow{Var('a'), DiagOf{-1, -2, -1, Var('a')}},
+	Arrow{Var('a'), DiagEmbedOf{2, 0, 1, Var('a')}},
+	Arrow{Var('a'), Abstract{UnaryOp{Dims, Var('a')}, BinOp{Add, Var('a'), Size(1)}}},
+	Abstract{Var('D'), Var('K'), Var('P'), Var('S'), Var('T'), Var('X')},
+	Var('S'),
+	Arrow{Var('S'), UnaryOp{Sum, Var('S')}},
+	Arrow{Var('D'), UnaryOp{Dims, Var('D')}},
+	Arrow{Var('T'), TransposeOf{Axes{1, 0}, Var('T')}},
+	Arrow{Var('X'), IndexOf{0, Var('S')}},
+	Arrow{Var('T'), IndexOf{0, Var('T')}},
+	Arrow{Var('K'), SliceOf{Range{0, 2, 1}, Var('K')}},
+	Arrow{Var('a'), SliceOf{Var('P'), Var('a')}},
+	Arrow{Var('a'), SliceOf{Range{3, 0, -1}, Var('a')}},
+	Arrow{Axes{1, 0}, Var('a')},
+	Arrow{Sizes{1, 2}, Var('a')},
+	Application{MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')}), Shape{2, 3}},
+	Application{Application{Arrow{Var('a'), Arrow{Var('b'), Var('a')}}, Shape{2}}, Var('c')},
+	Application{Arrow{Var('a'), Var('a')}, Application{Arrow{Var('b'), Var('b')}, Shape{2}}},
+	Arrow{Var('a'), Application{Arrow{Var('b'), Var('b')}, Var('a')}},
+	Compound{
+		Expr: Arrow{Var('a'), Arrow{Var('b'), Var('a')}},
+		SubjectTo: SubjectTo{
+			And,
+			SubjectTo{Eq, UnaryOp{Dims, Var('a')}, UnaryOp{Dims, Var('b')}},
+			SubjectTo{Gte, IndexOf{0, Var('a')}, Size(2)},
+		},
+	},
+	Compound{
+		Expr:      Arrow{Var('a'), Arrow{Var('b'), BroadcastOf{Var('a'), Var('b')}}},
+		SubjectTo: SubjectTo{Bc, UnaryOp{Const, Var('a')}, UnaryOp{Const, Var('b')}},
+	},
+	Compound{
+		Expr:      Arrow{Var('a'), Var('a')},
+		SubjectTo: SubjectTo{Or, SubjectTo{Ne, Size(1), Size(2)}, SubjectTo{Lt, UnaryOp{Prod, Var('a')}, Size(10)}},
+	},
+}
+
+func TestParse_roundTrip(t *testing.T) {
+	assert := assert.New(t)
+	for _, e := range roundTripCases {
+		s := fmt.Sprint(e)
+		parsed, err := Parse(s)
+		if err != nil {
+			t.Errorf("Unable to parse %q (%#v): %v", s, e, err)
+			continue
+		}
+		assert.Equal(e, parsed, "Round trip of %q", s)
+
+		ascii := FormatASCII(e)
+		for _, r := range ascii {
+			if r > 127 {
+				t.Errorf("Expected FormatASCII(%v) to only contain ASCII. Got %q", e, ascii)
+				break
+			}
+		}
+		if parsed, err = Parse(ascii); err != nil {
+			t.Errorf("Unable to parse %q (%#v): %v", ascii, e, err)
+			continue
+		}
+		assert.Equal(e, parsed, "Round trip of %q", ascii)
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	assert := assert.New(t)
+	for k, v := range parseCases {
+		expr, err := ParseReader(strings.NewReader(k))
+		if err != nil {
+			t.Errorf("Unable to parse %q: %+v", k, err)
+			continue
+		}
+		assert.Equal(v, expr, "Failed to parse %q", k)
+	}
+
+	_, err := ParseReader(bufio.NewReader(strings.NewReader("(a, b) → X a")))
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Expected a *ParseError. Got %v of %T instead", err, err)
+	}
+	assert.Equal("(a, b) → X a", pe.Input)
+	assert.Equal(11, pe.Pos)
+}
+
+func TestLexer_reuse(t *testing.T) {
+	var l lexer
+	var r strings.Reader
+	in := "{ (a, b) → T⁽¹ ⁰⁾ (a, b) | (D a = 2) }"
+	lexit := func() {
+		r.Reset(in)
+		l.reset(&r)
+		if _, err := l.lex(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lexit()
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, lexit), "Expected a reused lexer to not allocate")
+}
+
+var benchSignatures = []string{
+	"(a, b) → (b, c) → (a, c)",
+	"{ a → b → (a||b) | (K a ⚟ K b) }",
+	"(a, b, c, d) → T⁽⁰ ³ ¹ ²⁾ (a, b, c, d)",
+	"a → b → b s.t. (Πa = Πb)",
+}
+
+func BenchmarkParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, s := range benchSignatures {
+			if _, err := Parse(s); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkParseReader(b *testing.B) {
+	b.ReportAllocs()
+	var r strings.Reader
+	for i := 0; i < b.N; i++ {
+		for _, s := range benchSignatures {
+			r.Reset(s)
+			if _, err := ParseReader(&r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkLex(b *testing.B) {
+	b.ReportAllocs()
+	var l lexer
+	var r strings.Reader
+	for i := 0; i < b.N; i++ {
+		for _, s := range benchSignatures {
+			r.Reset(s)
+			l.reset(&r)
+			if _, err := l.lex(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package shapes
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// printer.go describes a pretty printer for expressions.
+
+// Style is the set of symbols that a Printer uses.
+type Style byte
+
+const (
+	// UnicodeStyle is the style used by Format, e.g. (a, b) → T⁽¹ ⁰⁾ (a, b).
+	UnicodeStyle Style = iota
+	// ASCIIStyle only uses ASCII characters, e.g. (a, b) -> TX[1 0] (a, b). Parse accepts it.
+	ASCIIStyle
+	// LaTeXStyle is for use in LaTeX math mode, e.g. (a, b) \to \operatorname{T}^{(1\,0)} (a, b). It requires amsmath.
+	LaTeXStyle
+)
+
+// String returns the name of the style.
+func (s Style) String() string {
+	switch s {
+	case UnicodeStyle:
+		return "Unicode"
+	case ASCIIStyle:
+		return "ASCII"
+	case LaTeXStyle:
+		return "LaTeX"
+	}
+	return fmt.Sprintf("UNKNOWN STYLE %d", byte(s))
+}
+
+// Printer prints expressions in a given style, breaking long lines at arrows.
+//
+// When a line is broken, each arrow after the first starts a new line, aligned with the first arrow:
+//
+//	(a, b) → (b, c)
+//	       → (a, c)
+//
+// In LaTeXStyle, lines are separated by \\ and the arrows are aligned with &, for use in an aligned environment.
+// Except in LaTeXStyle, the broken lines are still accepted by Parse.
+type Printer struct {
+	Style Style
+	Width int // the maximum width of a line, in runes. If Width is 0, lines are never broken.
+}
+
+// FormatASCII formats an expression using only ASCII characters. For example, (a, b) → ⁽¹ ⁰⁾ is written as (a, b) -> X[1 0].
+// Parse accepts the result, so Parse(FormatASCII(e)) returns an expression equivalent to e.
+func FormatASCII(e Expr) string { return Printer{Style: ASCIIStyle}.Sprint(e) }
+
+// Sprint prints the expression to a string.
+func (p Printer) Sprint(e Expr) string {
+	s := p.sprint(e, precApp)
+	if p.Width <= 0 || utf8.RuneCountInString(s) <= p.Width {
+		return s
+	}
+	sep := "\n"
+	if p.Style == LaTeXStyle {
+		sep = ` \\` + "\n"
+	}
+	return strings.Join(p.lines(e), sep)
+}
+
+// Fprint prints the expression to w.
+func (p Printer) Fprint(w io.Writer, e Expr) (n int, err error) {
+	return io.WriteString(w, p.Sprint(e))
+}
+
+// lines breaks an expression into lines. Only arrows, applications and compounds are broken.
+func (p Printer) lines(e Expr) []string {
+	arrow, align := p.Style.sym('→'), ""
+	if p.Style == LaTeXStyle {
+		align = "&"
+	}
+
+	switch et := e.(type) {
+	case Arrow:
+		parts := p.arrowParts(et, nil)
+		retVal := []string{fmt.Sprintf("%s %s%s %s", parts[0], align, arrow, parts[1])}
+		pad := strings.Repeat(" ", utf8.RuneCountInString(parts[0])+1)
+		if p.Style == LaTeXStyle {
+			pad = ""
+		}
+		for _, part := range parts[2:] {
+			retVal = append(retVal, fmt.Sprintf("%s%s%s %s", pad, align, arrow, part))
+		}
+		return retVal
+	case Application:
+		retVal := p.lines(et.A)
+		return append(retVal, fmt.Sprintf("%s@ %s", align, p.sprint(et.B, precApp+1)))
+	case Compound:
+		open, pipe, closing := p.Style.sym('{')+" ", p.Style.sym('|')+" ", " "+p.Style.sym('}')
+		retVal := p.lines(et.Expr)
+		for i := range retVal {
+			if i == 0 {
+				retVal[i] = open + retVal[i]
+				continue
+			}
+			retVal[i] = "  " + retVal[i]
+		}
+		return append(retVal, fmt.Sprintf("  %s%s%s%s", align, pipe, p.sprint(et.SubjectTo, precAtom), closing))
+	}
+	return []string{p.sprint(e, precApp)}
+}
+
+// arrowParts returns the printed operands of a chain of arrows, e.g. a → b → c has the parts a, b and c.
+func (p Printer) arrowParts(a Arrow, parts []string) []string {
+	parts = append(parts, p.sprint(a.A, precArrow+1))
+	if b, ok := a.B.(Arrow); ok {
+		return p.arrowParts(b, parts)
+	}
+	return append(parts, p.sprint(a.B, precArrow))
+}
+
+// sprint prints an operand, with parentheses if it binds less tightly than prec.
+func (p Printer) sprint(e interface{}, prec int) string {
+	w := styleWriter{style: p.Style}
+	w.operand(e, prec)
+	return w.String()
+}
+
+// asciiRunes are the ASCII spellings of the non-ASCII runes used when formatting expressions.
+var asciiRunes = map[rune]string{
+	'→': "->",
+	'×': "*",
+	'÷': "/",
+	'Π': "P",
+	'Σ': "S",
+	'≠': "!=",
+	'≤': "<=",
+	'≥': ">=",
+	'∧': "&&",
+	'∨': "||",
+	'∀': "forall",
+	'⚟': "bcast",
+}
+
+// latexRunes are the LaTeX spellings of the runes used when formatting expressions.
+var latexRunes = map[rune]string{
+	'→': `\to`,
+	'×': `\times`,
+	'÷': `\div`,
+	'Π': `\Pi`,
+	'Σ': `\Sigma`,
+	'≠': `\neq`,
+	'≤': `\leq`,
+	'≥': `\geq`,
+	'∧': `\land`,
+	'∨': `\lor`,
+	'∀': `\forall`,
+	'⚟': `\bowtie`,
+	'{': `\{`,
+	'}': `\}`,
+	'|': `\mid`,
+}
+
+// styleWriter writes an expression in a style by walking it, as its Format method would write it.
+type styleWriter struct {
+	strings.Builder
+	style Style
+}
+
+// sym returns the spelling of a symbol in the style.
+func (s Style) sym(r rune) string {
+	switch s {
+	case ASCIIStyle:
+		if sp, ok := asciiRunes[r]; ok {
+			return sp
+		}
+	case LaTeXStyle:
+		if sp, ok := latexRunes[r]; ok {
+			return sp
+		}
+	}
+	return string(r)
+}
+
+// keyword returns the spelling of a keyword of the lexer (e.g. T or Diag) in the style.
+func (s Style) keyword(word string) string {
+	if s == LaTeXStyle {
+		return `\operatorname{` + word + `}`
+	}
+	return word
+}
+
+// op returns the spelling of an operator in the style. The operators that are letters (e.g. K) are keywords.
+func (s Style) op(o OpType) string {
+	str := o.String()
+	if r, _ := utf8.DecodeRuneInString(str); r < utf8.RuneSelf && unicode.IsLetter(r) {
+		return s.keyword(str)
+	}
+	var b strings.Builder
+	for _, r := range str {
+		b.WriteString(s.sym(r))
+	}
+	return b.String()
+}
+
+// axes returns the spelling of the superscript axes of e.g. a transpose, ⁽¹ ⁰⁾ in Format.
+func (s Style) axes(a Axes) string {
+	ints := make([]string, 0, len(a))
+	for _, ax := range a {
+		ints = append(ints, strconv.Itoa(int(ax)))
+	}
+	switch s {
+	case ASCIIStyle:
+		return "X[" + strings.Join(ints, " ") + "]"
+	case LaTeXStyle:
+		return `^{(` + strings.Join(ints, `\,`) + `)}`
+	}
+	return supInts(axesToInts(a))
+}
+
+// axis returns the spelling of a lone superscript axis, e.g. the ¹ of /¹a.
+func (s Style) axis(a Axis) string {
+	str := "="
+	if a != AllAxes {
+		str = strconv.Itoa(int(a))
+	}
+	switch s {
+	case ASCIIStyle:
+		return "^" + str
+	case LaTeXStyle:
+		return "^{" + str + "}"
+	}
+	return fmt.Sprintf("%x", a)
+}
+
+// operand writes an operand, with parentheses if it binds less tightly than prec.
+func (w *styleWriter) operand(e interface{}, prec int) {
+	if exprPrec(e) < prec {
+		w.WriteString("(")
+		w.expr(e)
+		w.WriteString(")")
+		return
+	}
+	w.expr(e)
+}
+
+// expr writes an expression. The layout of each expression follows its Format method.
+func (w *styleWriter) expr(e interface{}) {
+	switch et := e.(type) {
+	case Var, Size, Shape, Axis, Range, Slices:
+		fmt.Fprintf(w, "%v", et)
+	case Abstract:
+		w.WriteString("(")
+		for i, d := range et {
+			if i > 0 {
+				w.WriteString(", ")
+			}
+			w.expr(d)
+		}
+		w.WriteString(")")
+	case Axes:
+		fmt.Fprintf(w, "%s%v", w.style.keyword("X"), axesToInts(et))
+	case Sizes:
+		fmt.Fprintf(w, "%s%v", w.style.keyword("Sz"), sizesToInts(et))
+	case Arrow:
+		w.operand(et.A, precArrow+1)
+		fmt.Fprintf(w, " %s ", w.style.sym('→'))
+		w.operand(et.B, precArrow)
+	case Application:
+		w.operand(et.A, precApp)
+		w.WriteString(" @ ")
+		w.operand(et.B, precApp+1)
+	case Compound:
+		fmt.Fprintf(w, "%s ", w.style.sym('{'))
+		w.expr(et.Expr)
+		fmt.Fprintf(w, " %s ", w.style.sym('|'))
+		w.expr(et.SubjectTo)
+		fmt.Fprintf(w, " %s", w.style.sym('}'))
+	case SubjectTo:
+		w.WriteString("(")
+		w.expr(et.A)
+		fmt.Fprintf(w, " %s ", w.style.op(et.OpType))
+		w.expr(et.B)
+		w.WriteString(")")
+	case E2:
+		w.expr(et.BinOp)
+	case BinOp:
+		prec := et.Op.prec()
+		w.operand(et.A, prec)
+		fmt.Fprintf(w, " %s ", w.style.op(et.Op))
+		w.operand(et.B, prec+1)
+	case UnaryOp:
+		fmt.Fprintf(w, "%s ", w.style.op(et.Op))
+		w.operand(et.A, precPrefix)
+	case TransposeOf:
+		fmt.Fprintf(w, "%s%s ", w.style.keyword("T"), w.style.axes(et.Axes))
+		w.operand(et.A, precPrefix)
+	case ReductOf:
+		fmt.Fprintf(w, "/%s", w.style.axis(et.Along))
+		// a number that follows ^n would be read as part of it
+		operand := Printer{Style: w.style}.sprint(et.A, precPrefix)
+		if r, _ := utf8.DecodeRuneInString(operand); w.style == ASCIIStyle && unicode.IsDigit(r) {
+			w.WriteString(" ")
+		}
+		w.WriteString(operand)
+	case RepeatOf:
+		fmt.Fprintf(w, "%s%s%s%v%s ", w.style.keyword("Repeat"), w.style.axis(et.Along), w.style.sym('{'), et.Repeats, w.style.sym('}'))
+		w.operand(et.A, precPrefix)
+	case DiagOf:
+		fmt.Fprintf(w, "%s%s%s%d%s ", w.style.keyword("Diag"), w.style.axes(Axes{et.Axis1, et.Axis2}), w.style.sym('{'), et.Offset, w.style.sym('}'))
+		w.operand(et.A, precPrefix)
+	case DiagEmbedOf:
+		fmt.Fprintf(w, "%s%s%s%d%s ", w.style.keyword("DiagEmbed"), w.style.axes(Axes{et.Axis1, et.Axis2}), w.style.sym('{'), et.Offset, w.style.sym('}'))
+		w.operand(et.A, precPrefix)
+	case IndexOf:
+		w.operand(et.A, precPostfix)
+		fmt.Fprintf(w, "[%d]", et.I)
+	case SliceOf:
+		w.operand(et.A, precPostfix)
+		fmtSlicing(w, et.Slice)
+	case sizelikeSliceOf:
+		w.expr(et.SliceOf)
+	case ConcatOf:
+		w.operand(et.A, precConcat)
+		fmt.Fprintf(w, " :%s%d%s: ", w.style.sym('{'), et.Along, w.style.sym('}'))
+		w.operand(et.B, precConcat+1)
+	case BroadcastOf:
+		w.WriteString("(")
+		w.operand(et.A, opprec['∨'])
+		if w.style == LaTeXStyle {
+			w.WriteString(`\|`)
+		} else {
+			w.WriteString("||")
+		}
+		w.operand(et.B, opprec['∨']+1)
+		w.WriteString(")")
+	default:
+		fmt.Fprintf(w, "%v", et)
+	}
+}
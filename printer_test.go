@@ -0,0 +1,94 @@
+package shapes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var printerTests = []struct {
+	in string
+	p  Printer
+
+	correct string
+}{
+	{"(a, b) → (b, c) → (a, c)", Printer{}, "(a, b) → (b, c) → (a, c)"},
+	{"(a, b) → (b, c) → (a, c)", Printer{Style: ASCIIStyle}, "(a, b) -> (b, c) -> (a, c)"},
+	{"(a, b) → (b, c) → (a, c)", Printer{Style: LaTeXStyle}, `(a, b) \to (b, c) \to (a, c)`},
+	{"a → T⁽¹ ⁰⁾ a", Printer{Style: LaTeXStyle}, `a \to \operatorname{T}^{(1\,0)} a`},
+	{"a → /¹a", Printer{Style: LaTeXStyle}, `a \to /^{1}a`},
+	{"{ a → b → (a||b) | (K a ⚟ K b) }", Printer{Style: LaTeXStyle}, `\{ a \to b \to (a\|b) \mid (\operatorname{K} a \bowtie \operatorname{K} b) \}`},
+	{"(a × (b + c), Π a)", Printer{Style: LaTeXStyle}, `(a \times (b + c), \Pi a)`},
+
+	// the line is short enough
+	{"(a, b) → (b, c) → (a, c)", Printer{Width: 40}, "(a, b) → (b, c) → (a, c)"},
+
+	// wrapping
+	{"(a, b) → (b, c) → (a, c)", Printer{Width: 20}, "(a, b) → (b, c)\n       → (a, c)"},
+	{"(a, b) → (b, c) → (a, c)", Printer{Style: ASCIIStyle, Width: 20}, "(a, b) -> (b, c)\n       -> (a, c)"},
+	{"(a, b) → (b, c) → (a, c)", Printer{Style: LaTeXStyle, Width: 20}, "(a, b) &\\to (b, c) \\\\\n&\\to (a, c)"},
+	{"((a, b) → (b, c)) → a → (a, c)", Printer{Width: 20}, "((a, b) → (b, c)) → a\n                  → (a, c)"},
+	{"{ a → b → (a||b) | (K a ⚟ K b) }", Printer{Width: 20}, "{ a → b\n    → (a||b)\n  | (K a ⚟ K b) }"},
+	{"(a, b) → (b, c) → (a, c) @ (2, 3)", Printer{Width: 20}, "(a, b) → (b, c)\n       → (a, c)\n@ (2, 3)"},
+	{"(a, b, c, d, e, f, g, h)", Printer{Width: 10}, "(a, b, c, d, e, f, g, h)"}, // only arrows are broken
+}
+
+func TestPrinter(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range printerTests {
+		e, err := Parse(c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := c.p.Sprint(e)
+		assert.Equal(c.correct, got, "Printing %q in %v", c.in, c.p.Style)
+
+		// Parse accepts any broken lines
+		if c.p.Style == LaTeXStyle {
+			continue
+		}
+		e2, err := Parse(got)
+		if err != nil {
+			t.Errorf("Unable to parse %q: %v", got, err)
+			continue
+		}
+		assert.Equal(e, e2, "Round trip of %q", got)
+	}
+}
+
+func TestPrinter_keywordVars(t *testing.T) {
+	assert := assert.New(t)
+	e := Arrow{Var('S'), Arrow{Var('T'), Abstract{UnaryOp{Sum, Var('S')}, UnaryOp{Dims, Var('D')}}}}
+	assert.Equal(`S \to T \to (\Sigma S, \operatorname{D} D)`, Printer{Style: LaTeXStyle}.Sprint(e))
+	assert.Equal("S -> T -> (S S, D D)", FormatASCII(e))
+
+	e = Arrow{Var('T'), TransposeOf{Axes{1, 0}, Var('T')}}
+	assert.Equal(`T \to \operatorname{T}^{(1\,0)} T`, Printer{Style: LaTeXStyle}.Sprint(e))
+	assert.Equal("T -> TX[1 0] T", FormatASCII(e))
+}
+
+// The Unicode style is the same as Format.
+func TestPrinter_unicode(t *testing.T) {
+	for _, e := range roundTripCases {
+		assert.Equal(t, fmt.Sprint(e), Printer{}.Sprint(e))
+	}
+}
+
+func ExamplePrinter() {
+	matmul := MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')})
+	for _, style := range []Style{UnicodeStyle, ASCIIStyle, LaTeXStyle} {
+		fmt.Printf("%v:\n%s\n", style, Printer{Style: style, Width: 20}.Sprint(matmul))
+	}
+
+	// Output:
+	// Unicode:
+	// (a, b) → (b, c)
+	//        → (a, c)
+	// ASCII:
+	// (a, b) -> (b, c)
+	//        -> (a, c)
+	// LaTeX:
+	// (a, b) &\to (b, c) \\
+	// &\to (a, c)
+}
@@ -0,0 +1,263 @@
+package shapes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// provenance.go describes where the constraints of an application come from, so that a failure can say which argument caused it.
+
+// Span is the range [Start, End) of the runes of an expression in the string that it was parsed from.
+type Span struct {
+	Start, End int
+}
+
+// Arg is an argument of an application, along with where it came from.
+type Arg struct {
+	Expr  Expr
+	Label string // a name for the argument, e.g. "weights". It is optional.
+	Span  Span   // where the argument is in the string it was parsed from (see ParseApp). It is optional.
+}
+
+// Provenance describes where a constraint came from.
+type Provenance struct {
+	Arg   int    // the position of the argument in the application, starting from 1
+	Label string // the label of the argument, if any
+	Span  Span   // where the argument is in the string it was parsed from, if known
+}
+
+func (p Provenance) Format(s fmt.State, r rune) {
+	fmt.Fprintf(s, "arg %d", p.Arg)
+	if p.Label != "" {
+		fmt.Fprintf(s, " (%s)", p.Label)
+	}
+	if p.Span != (Span{}) {
+		fmt.Fprintf(s, " at position %d", p.Span.Start)
+	}
+}
+
+// ArgError is returned by InferArgs and InferApp when an argument cannot be applied.
+//
+// For example, applying (a, b) → (b, c) → (a, c) to (64, 128) and then to (64, 10) fails with
+//
+//	arg 2 dim 0: expected b=128 from arg 1, got 64
+type ArgError struct {
+	Provenance
+
+	// Path is where the failure is in the argument, as the indices of the sub-expressions (e.g. the dimensions of a shape)
+	// from the root of the argument. It is empty if the argument as a whole is at fault.
+	Path []int
+
+	Expected Expr // the part of the function that could not be unified with Got, if any
+	Got      Expr // the part of the argument that could not be unified with Expected, if any
+
+	Var  Var // the Var of the function that was bound to Expected, or 0 if there is none
+	From int // the argument that bound Var, if any
+
+	Err error
+
+	where string // describes Path, e.g. "dim 0"
+	plain bool   // Error is the message of Err alone, as InferApp does not say where its arguments came from
+}
+
+// newArgError makes an ArgError for the failure to solve a constraint made by App.
+func newArgError(c exprConstraint, err error) error {
+	retVal := &ArgError{Provenance: *c.prov, Err: err}
+
+	var ue *UnificationError
+	if !errors.As(err, &ue) || ue.Recursive {
+		return errors.WithStack(retVal)
+	}
+	retVal.Expected, retVal.Got = ue.A, ue.B
+
+	// the constraint is f = x → r, so the argument is at the sub-expression 0 of the right hand side
+	in, ok := c.b.(Arrow)
+	if !ok || len(ue.Path) == 0 || ue.Path[0] != 0 {
+		return errors.WithStack(retVal)
+	}
+	retVal.Path = ue.Path[1:]
+
+	var where []string
+	e, _ := in.A.(substitutableExpr)
+	for _, i := range retVal.Path {
+		switch e.(type) {
+		case Shape, Abstract:
+			where = append(where, fmt.Sprintf("dim %d", i))
+		default:
+			where = append(where, fmt.Sprintf("sub-expression %d", i))
+		}
+		if e == nil {
+			break
+		}
+		subs := e.subExprs()
+		if i >= len(subs) {
+			break
+		}
+		e = subs[i]
+	}
+	retVal.where = strings.Join(where, " ")
+	return errors.WithStack(retVal)
+}
+
+func (e *ArgError) Error() string {
+	if e.plain {
+		return e.Err.Error()
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%v", e.Provenance)
+	if e.where != "" {
+		buf.WriteString(" ")
+		buf.WriteString(e.where)
+	}
+	buf.WriteString(": ")
+	switch {
+	case e.Expected == nil || e.Got == nil:
+		buf.WriteString(e.Err.Error())
+	case e.Var != 0:
+		fmt.Fprintf(&buf, "expected %v=%v", e.Var, e.Expected)
+		if e.From > 0 {
+			fmt.Fprintf(&buf, " from arg %d", e.From)
+		}
+		fmt.Fprintf(&buf, ", got %v", e.Got)
+	default:
+		fmt.Fprintf(&buf, "expected %v, got %v", e.Expected, e.Got)
+	}
+	return buf.String()
+}
+
+// Cause returns the underlying error. It allows ArgError to be used with errors.Cause.
+func (e *ArgError) Cause() error { return e.Err }
+
+// Unwrap returns the underlying error.
+func (e *ArgError) Unwrap() error { return e.Err }
+
+// InferArgs is like InferApp, except that the arguments may be labelled. A failure to apply an argument is an *ArgError
+// that says which argument, and which part of it, caused the failure.
+//
+// If the part of the function that the argument failed to match was a Var that an earlier argument bound, the error says so, e.g.
+//
+//	arg 2 (weights) dim 0: expected b=128 from arg 1, got 64
+func InferArgs(fn Expr, args ...Arg) (retVal Expr, err error) {
+	if len(args) == 0 {
+		return nil, errors.New("Expected at least one argument in order to InferArgs")
+	}
+	return inferArgs(nil, fn, args...)
+}
+
+// inferArgs is InferArgs, recording each step in tr (which may be nil).
+func inferArgs(tr *Trace, fn Expr, args ...Arg) (retVal Expr, err error) {
+	if ap, ok := fn.(Application); ok {
+		if fn, err = ap.resolveTrace(tr); err != nil {
+			return nil, err
+		}
+	}
+
+	// sym is what is left of fn before any substitutions, so that the Vars of fn may be found when an argument fails
+	sym := fn
+	from := make(map[Var]int) // the arguments that bound the Vars of fn
+	retVal = fn
+	for i, arg := range args {
+		prov := Provenance{Arg: i + 1, Label: arg.Label, Span: arg.Span}
+		if tr != nil {
+			tr.arg = i + 1
+		}
+
+		x := arg.Expr
+		if ap, ok := x.(Application); ok {
+			if x, err = ap.resolveTrace(tr); err != nil {
+				return nil, errors.WithStack(&ArgError{Provenance: prov, Err: err})
+			}
+		}
+		tr.step(StepApply, "%v @ %v", retVal, x)
+		if !isApplicable(retVal) {
+			return nil, errors.WithStack(&ArgError{Provenance: prov, Err: errors.Errorf(notArrow, retVal, retVal)})
+		}
+
+		ce := app(tr, retVal, x)
+		ce.cs[0].prov = &prov
+		var subs substitutions
+		if subs, err = solveWith(tr, ce.cs, nil); err != nil {
+			var ae *ArgError
+			if !errors.As(err, &ae) {
+				return nil, errors.Wrapf(err, "Failed to solve %v", ce)
+			}
+			if ae.Path != nil {
+				ae.Var, ae.From = symVar(sym, ae.Path, from, i+1)
+			}
+			ae.Err = errors.Wrapf(ae.Err, "Failed to solve %v", ce)
+			return nil, err
+		}
+
+		symFV := sym.freevars()
+		for _, s := range subs {
+			if _, ok := from[s.For]; !ok && symFV.Contains(s.For) {
+				from[s.For] = i + 1
+			}
+		}
+		if retVal, err = inferSolved(tr, ce, subs); err != nil {
+			return nil, errors.WithStack(&ArgError{Provenance: prov, Err: err})
+		}
+		sym = symResult(sym)
+	}
+	return retVal, nil
+}
+
+// isApplicable returns true if e can be applied, i.e. if it is an Arrow or a Compound of an Arrow.
+func isApplicable(e Expr) bool {
+	if c, ok := e.(Compound); ok {
+		e = c.Expr
+	}
+	_, ok := e.(Arrow)
+	return ok
+}
+
+// symInput returns the input of a function before any substitutions.
+func symInput(sym Expr) (substitutableExpr, bool) {
+	if c, ok := sym.(Compound); ok {
+		sym = c.Expr
+	}
+	if a, ok := sym.(Arrow); ok {
+		return a.A.(substitutableExpr), true
+	}
+	return nil, false
+}
+
+// symResult returns what is left of a function before any substitutions, once it has been applied.
+func symResult(sym Expr) Expr {
+	if c, ok := sym.(Compound); ok {
+		sym = c.Expr
+	}
+	if a, ok := sym.(Arrow); ok {
+		return a.B
+	}
+	return sym
+}
+
+// symVar finds the Var at the given path of the input of a function before any substitutions, and the argument that bound it.
+// A Var that was not bound by an earlier argument was bound by the current one.
+func symVar(sym Expr, path []int, from map[Var]int, current int) (Var, int) {
+	e, ok := symInput(sym)
+	if !ok {
+		return 0, 0
+	}
+	for _, i := range path {
+		if _, ok := e.(Var); ok {
+			break
+		}
+		subs := e.subExprs()
+		if i >= len(subs) {
+			return 0, 0
+		}
+		e = subs[i]
+	}
+	v, ok := e.(Var)
+	if !ok {
+		return 0, 0
+	}
+	if arg, ok := from[v]; ok {
+		return v, arg
+	}
+	return v, current
+}
@@ -0,0 +1,133 @@
+package shapes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var provMatMul = MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')})
+
+var inferArgsTests = []struct {
+	name string
+	fn   Expr
+	args []Arg
+
+	correct Expr
+	msg     string
+	path    []int
+	v       Var
+	from    int
+}{
+	{"matmul", provMatMul, []Arg{{Expr: Shape{2, 3}}, {Expr: Shape{3, 4}}}, Shape{2, 4}, "", nil, 0, 0},
+	{"bound by an earlier arg", provMatMul, []Arg{{Expr: Shape{32, 128}, Label: "x"}, {Expr: Shape{64, 10}, Label: "weights"}},
+		nil, "arg 2 (weights) dim 0: expected b=128 from arg 1, got 64", []int{0}, 'b', 1},
+	{"bound by the same arg", MakeArrow(Abstract{Var('a'), Var('a')}, Abstract{Var('a')}), []Arg{{Expr: Shape{2, 3}}},
+		nil, "arg 1 dim 1: expected a=2 from arg 1, got 3", []int{1}, 'a', 1},
+	{"constant", MakeArrow(Shape{2, 3}, Shape{3}), []Arg{{Expr: Shape{2, 4}}},
+		nil, "arg 1 dim 1: expected 3, got 4", []int{1}, 0, 0},
+	{"rank", provMatMul, []Arg{{Expr: Shape{2, 3}}, {Expr: Shape{3, 4, 5}}},
+		nil, "arg 2: expected (3, c), got (3, 4, 5)", []int{}, 0, 0},
+	{"span", provMatMul, []Arg{{Expr: Shape{2, 3}, Span: Span{27, 33}}, {Expr: Shape{4, 5}, Span: Span{36, 42}}},
+		nil, "arg 2 at position 36 dim 0: expected b=3 from arg 1, got 4", []int{0}, 'b', 1},
+	{"nested", MakeArrow(Arrow{Abstract{Var('a')}, Abstract{Var('b')}}, Abstract{Var('b')}), []Arg{{Expr: Arrow{Shape{2}, Shape{3, 4}}}},
+		nil, "arg 1 sub-expression 1: expected (b), got (3, 4)", []int{1}, 0, 0},
+	{"SubjectTo", Compound{MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('a')}), SubjectTo{Lt, IndexOf{1, Abstract{Var('a'), Var('b')}}, Size(4)}},
+		[]Arg{{Expr: Shape{2, 5}, Label: "q"}}, nil, "arg 1 (q): SubjectTo ((2, 5)[1] < 4) resolved to false. Cannot continue", nil, 0, 0},
+	{"too many args", provMatMul, []Arg{{Expr: Shape{2, 3}}, {Expr: Shape{3, 4}}, {Expr: Shape{4}}},
+		nil, "arg 3: Cannot apply (2, 4) of shapes.Shape. Only an Arrow or a Compound of an Arrow can be applied.", nil, 0, 0},
+	{"Compound of a Var", Compound{Var('a'), SubjectTo{Eq, Size(1), Size(1)}}, []Arg{{Expr: Shape{2}}},
+		nil, "arg 1: Cannot apply { a | (1 = 1) } of shapes.Compound. Only an Arrow or a Compound of an Arrow can be applied.", nil, 0, 0},
+}
+
+func TestInferArgs(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range inferArgsTests {
+		got, err := InferArgs(c.fn, c.args...)
+		if checkErr(t, c.msg != "", err, "InferArgs", c.name) {
+			if err == nil {
+				continue
+			}
+			var ae *ArgError
+			if !assert.True(errors.As(err, &ae), "%v: expected an *ArgError. Got %v", c.name, err) {
+				continue
+			}
+			assert.Equal(c.msg, err.Error(), c.name)
+			assert.Equal(c.path, ae.Path, c.name)
+			assert.Equal(c.v, ae.Var, c.name)
+			assert.Equal(c.from, ae.From, c.name)
+			continue
+		}
+		checkShape(t, c.correct, got, "InferArgs", c.name)
+	}
+
+	_, err := InferArgs(provMatMul)
+	assert.Error(err)
+
+	// InferApp fails the same way, but its message is that of the underlying error
+	_, err = InferApp(provMatMul, Shape{64, 128}, Shape{64, 10})
+	var ae *ArgError
+	if assert.True(errors.As(err, &ae), "Expected an *ArgError. Got %v", err) {
+		assert.Equal(2, ae.Arg)
+		assert.Equal([]int{0}, ae.Path)
+		assert.Equal(Var('b'), ae.Var)
+		assert.Equal(1, ae.From)
+		assert.EqualError(err, "Failed to solve [{(128, c) → (64, c) = (64, 10) → d}] | d: Unification Fail. 128 ~ 64 cannot proceed")
+	}
+}
+
+func TestParseApp(t *testing.T) {
+	assert := assert.New(t)
+	s := "(a, b) → (b, c) → (a, c) @ (2, 3) @ ( 3,  4 )  "
+	fn, args, err := ParseApp(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(provMatMul, fn)
+	if assert.Len(args, 2) {
+		assert.Equal(Arg{Expr: Shape{2, 3}, Span: Span{27, 33}}, args[0])
+		assert.Equal(Arg{Expr: Shape{3, 4}, Span: Span{36, 45}}, args[1])
+		assert.Equal("( 3,  4 )", string([]rune(s)[args[1].Span.Start:args[1].Span.End]))
+	}
+
+	// only the outermost applications are split
+	fn, args, err = ParseApp("((a) → (a) @ (2)) @ (b) → (b)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(Application{MakeArrow(Abstract{Var('a')}, Abstract{Var('a')}), Shape{2}}, fn)
+	if assert.Len(args, 1) {
+		assert.Equal(MakeArrow(Abstract{Var('b')}, Abstract{Var('b')}), args[0].Expr)
+	}
+
+	fn, args, err = ParseApp("(a, b)")
+	assert.NoError(err)
+	assert.Equal(Abstract{Var('a'), Var('b')}, fn)
+	assert.Empty(args)
+
+	_, _, err = ParseApp("(a, b) @")
+	assert.Error(err)
+}
+
+func ExampleInferArgs() {
+	fn, args, err := ParseApp("(a, b) → (b, c) → (a, c) @ (32, 128) @ (64, 10)")
+	if err != nil {
+		panic(err)
+	}
+	args[0].Label = "x"
+	args[1].Label = "weights"
+
+	_, err = InferArgs(fn, args...)
+	fmt.Println(err)
+
+	var ae *ArgError
+	if errors.As(err, &ae) {
+		fmt.Printf("dim %d of arg %d (runes %d to %d) is %v\n", ae.Path[0], ae.Arg, ae.Span.Start, ae.Span.End, ae.Got)
+	}
+
+	// Output:
+	// arg 2 (weights) at position 39 dim 0: expected b=128 from arg 1, got 64
+	// dim 0 of arg 2 (runes 39 to 47) is 64
+}
@@ -155,7 +155,7 @@ func (s Shape) DimSize(d int) (retVal Sizelike, err error) {
 // Dim returns the dimension wanted,
 func (s Shape) Dim(d int) (retVal int, err error) {
 	if (s.IsScalar() && d != 0) || (!s.IsScalar() && d >= len(s)) {
-		return -1, errors.Errorf(dimMismatch, len(s), d)
+		return -1, newDimMismatchError(dimMismatch, len(s), d, s)
 	}
 	switch {
 	case s.IsScalar():
@@ -164,7 +164,7 @@ func (s Shape) Dim(d int) (retVal int, err error) {
 		od := d
 		d = s.Dims() + d
 		if d < 0 {
-			return -1, errors.Errorf(dimMismatch, len(s), od)
+			return -1, newDimMismatchError(dimMismatch, len(s), od, s)
 		}
 		fallthrough
 	default:
@@ -184,7 +184,7 @@ func (s Shape) T(axes ...Axis) (newShape Shapelike, err error) {
 func (s Shape) S(slices ...Slice) (newShape Shapelike, err error) {
 	opDims := len(s)
 	if len(slices) > opDims {
-		err = errors.Errorf(dimsMismatch, opDims, len(slices))
+		err = newDimMismatchError(dimsMismatch, opDims, len(slices), s)
 		return
 	}
 
@@ -250,7 +250,7 @@ func (s Shape) Repeat(axis Axis, repeats ...int) (retVal Shapelike, finalRepeats
 	default:
 		if int(axis) >= s.Dims() {
 			// error
-			err = errors.Errorf(invalidAxis, axis, s.Dims())
+			err = newInvalidAxisError(axis, s.Dims(), s)
 			return
 		}
 		size = s[axis]
@@ -266,7 +266,7 @@ func (s Shape) Repeat(axis Axis, repeats ...int) (retVal Shapelike, finalRepeats
 	}
 	reps := len(repeats)
 	if reps != size {
-		err = errors.Errorf(broadcastError, size, reps)
+		err = newDimMismatchError(broadcastError, size, reps, s)
 		return
 	}
 
@@ -284,7 +284,8 @@ func (s Shape) Concat(axis Axis, ss ...Shapelike) (retVal Shapelike, err error)
 	// check that all the concatenates have the same dimensions
 	for _, shp := range ss {
 		if shp.Dims() != dims {
-			err = errors.Errorf(dimMismatch, dims, shp.Dims())
+			e, _ := shp.(Expr)
+			err = newDimMismatchError(dimMismatch, dims, shp.Dims(), e)
 			return
 		}
 	}
@@ -296,12 +297,12 @@ func (s Shape) Concat(axis Axis, ss ...Shapelike) (retVal Shapelike, err error)
 
 	// nope... no negative indexing here.
 	if axis < 0 {
-		err = errors.Errorf(invalidAxis, axis, len(s))
+		err = newInvalidAxisError(axis, len(s), s)
 		return
 	}
 
 	if int(axis) >= dims {
-		err = errors.Errorf(invalidAxis, axis, len(s))
+		err = newInvalidAxisError(axis, len(s), s)
 		return
 	}
 
@@ -314,7 +315,7 @@ func (s Shape) Concat(axis Axis, ss ...Shapelike) (retVal Shapelike, err error)
 			} else {
 				// validate that the rest of the dimensions match up
 				if newShape[d] != shp[d] {
-					err = errors.Wrapf(errors.Errorf(dimMismatch, newShape[d], shp[d]), "Axis: %d, dimension it failed at: %d", axis, d)
+					err = errors.Wrapf(newDimMismatchError(dimMismatch, newShape[d], shp[d], shp), "Axis: %d, dimension it failed at: %d", axis, d)
 					return
 				}
 			}
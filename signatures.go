@@ -0,0 +1,127 @@
+package shapes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// signatures.go describes a small text format for declaring the shape signatures of named operations.
+// A declaration file looks like this:
+//
+//	// comments start with // and run to the end of the line
+//	matmul :: (a, b) → (b, c) → (a, c)
+//	add    :: { a → b → (a||b) | (K a ⚟ K b) }
+//	gemv   :: $matmul @ (a, b) // $name references a signature declared earlier in the file
+//
+// Each non-blank line declares exactly one signature.
+
+// SignatureError is an error found on a line of a declaration file.
+type SignatureError struct {
+	Line int    // line number, starting from 1
+	Name string // name of the signature being declared, if known
+	Err  error
+}
+
+func (e *SignatureError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %s: %v", e.Line, e.Name, e.Err)
+}
+
+// Cause returns the underlying error. It allows SignatureError to be used with errors.Cause.
+func (e *SignatureError) Cause() error { return e.Err }
+
+// Unwrap returns the underlying error.
+func (e *SignatureError) Unwrap() error { return e.Err }
+
+// SignatureErrors is a list of errors found in a declaration file, in order of line number.
+type SignatureErrors []*SignatureError
+
+func (e SignatureErrors) Error() string {
+	var buf strings.Builder
+	for i, err := range e {
+		if i > 0 {
+			buf.WriteRune('\n')
+		}
+		buf.WriteString(err.Error())
+	}
+	return buf.String()
+}
+
+// LoadSignatures reads a declaration file of `name :: expression` lines, and returns the signatures by name.
+//
+// A signature may reference any signature declared on an earlier line as $name. The referenced expression is used as is,
+// without renaming its variables.
+//
+// Every line is checked, so that all the errors in the file are reported at once. If there are any errors,
+// the returned error is a SignatureErrors, and the returned map holds the signatures that were declared correctly.
+func LoadSignatures(r io.Reader) (map[string]Expr, error) {
+	retVal := make(map[string]Expr)
+	declared := make(map[string]int) // name → line number
+	var errs SignatureErrors
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(text, "::", 2)
+		if len(parts) != 2 {
+			errs = append(errs, &SignatureError{Line: line, Err: errors.New("Expected a declaration of the form `name :: expression`")})
+			continue
+		}
+		name := strings.TrimSpace(parts[0])
+		if !isSignatureName(name) {
+			errs = append(errs, &SignatureError{Line: line, Err: errors.Errorf("Invalid signature name %q", name)})
+			continue
+		}
+		if first, ok := declared[name]; ok {
+			errs = append(errs, &SignatureError{Line: line, Name: name, Err: errors.Errorf("Duplicate signature. It was first declared on line %d", first)})
+			continue
+		}
+
+		expr, err := parse(strings.TrimSpace(parts[1]), retVal)
+		if err != nil {
+			// the position of a parse error is moved from the expression to the line it is on
+			var pe *ParseError
+			if errors.As(err, &pe) {
+				lead := len(parts[1]) - len(strings.TrimLeftFunc(parts[1], unicode.IsSpace))
+				pe.Pos += utf8.RuneCountInString(text[:len(parts[0])+len("::")+lead])
+				pe.Input = sc.Text()
+			}
+			errs = append(errs, &SignatureError{Line: line, Name: name, Err: err})
+			continue
+		}
+		declared[name] = line
+		retVal[name] = expr
+	}
+	if err := sc.Err(); err != nil {
+		return retVal, errors.Wrap(err, "Unable to read signatures")
+	}
+	if len(errs) > 0 {
+		return retVal, errs
+	}
+	return retVal, nil
+}
+
+// isSignatureName checks that a name starts with a letter or underscore, followed by letters, digits or underscores.
+func isSignatureName(name string) bool {
+	for i, r := range name {
+		if !isIdentRune(r) || (i == 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return name != ""
+}
@@ -0,0 +1,97 @@
+package shapes
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSignatures = `// linear algebra
+matmul :: (a, b) → (b, c) → (a, c)
+gemv   :: $matmul @ (2, 3)   // refers to matmul
+
+add :: { a → b → (a||b) | (K a ⚟ K b) }
+transpose :: a → T⁽¹ ⁰⁾ a
+id_2 :: $transpose
+`
+
+func TestLoadSignatures(t *testing.T) {
+	assert := assert.New(t)
+	sigs, err := LoadSignatures(strings.NewReader(testSignatures))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(5, len(sigs))
+	assert.Equal(MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')}), sigs["matmul"])
+	assert.Equal(Application{sigs["matmul"], Shape{2, 3}}, sigs["gemv"])
+	assert.Equal(sigs["transpose"], sigs["id_2"])
+
+	gemv, err := recursiveResolve(sigs["gemv"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal("(3, c) → (2, c)", fmt.Sprint(gemv))
+}
+
+var loadSignaturesErrTests = []struct {
+	name  string
+	in    string
+	lines []int
+}{
+	{"no ::", "matmul (a, b) → (b, c) → (a, c)", []int{1}},
+	{"bad name", "mat mul :: a → a\n1x :: a → a", []int{1, 2}},
+	{"duplicate", "id :: a → a\n\nid :: b → b", []int{3}},
+	{"redeclared after an error", "id :: a →\nid :: a → a", []int{1}},
+	{"parse error", "id :: a → \nok :: a → a\nbad :: (a, b", []int{1, 3}},
+	{"undefined", "f :: $g @ (2, 3)\ng :: a → a", []int{1}},
+	{"reference to a bad line", "g :: a →\nf :: $g", []int{1, 2}},
+}
+
+func TestLoadSignatures_errors(t *testing.T) {
+	for _, c := range loadSignaturesErrTests {
+		sigs, err := LoadSignatures(strings.NewReader(c.in))
+		var errs SignatureErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%q: expected SignatureErrors. Got %v of %T instead", c.name, err, err)
+			continue
+		}
+		var lines []int
+		for _, e := range errs {
+			lines = append(lines, e.Line)
+		}
+		assert.Equal(t, c.lines, lines, "%q: lines with errors", c.name)
+		for name := range sigs {
+			assert.True(t, strings.Contains(c.in, name+" ::"), "%q: unexpected signature %q", c.name, name)
+		}
+	}
+
+	// parse errors are kept
+	_, err := LoadSignatures(strings.NewReader("bad :: (a, b"))
+	var pe *ParseError
+	assert.True(t, errors.As(err.(SignatureErrors)[0], &pe), "Expected the underlying error to be a *ParseError")
+
+	// the position is that on the line
+	_, err = LoadSignatures(strings.NewReader("f ::  (a, b) → X a // transpose"))
+	if !errors.As(err.(SignatureErrors)[0], &pe) {
+		t.Fatalf("Expected the underlying error to be a *ParseError. Got %v instead", err)
+	}
+	assert.Equal(t, 17, pe.Pos)
+	assert.Equal(t, "f ::  (a, b) → X a // transpose", pe.Input)
+}
+
+func ExampleLoadSignatures() {
+	sigs, err := LoadSignatures(strings.NewReader(`
+matmul :: (a, b) → (b, c) → (a, c)
+vecmat :: $matmul @ (1, 3) // a row vector times a matrix
+matmul :: (a, b) → (b, c) → (a, c)
+`))
+	fmt.Println(err)
+	fmt.Println(sigs["vecmat"])
+
+	// Output:
+	// line 4: matmul: Duplicate signature. It was first declared on line 2
+	// (a, b) → (b, c) → (a, c) @ (1, 3)
+}
@@ -36,13 +36,8 @@ func (s Range) Format(st fmt.State, r rune) {
 		fmt.Fprintf(st, "{%d:%d:%d}", s.start, s.end, s.step)
 		return
 	}
-	fmt.Fprintf(st, "[%d", s.start)
-	if s.end-s.start > 1 {
-		fmt.Fprintf(st, ":%d", s.end)
-	}
-	if s.step > 1 {
-		fmt.Fprintf(st, "~:%d", s.step)
-	}
+	st.Write([]byte("["))
+	fmtSlice(st, s, false)
 	st.Write([]byte("]"))
 }
 
@@ -125,16 +120,7 @@ func (ss Slices) subExprs() []substitutableExpr       { return nil }
 func (ss Slices) Format(st fmt.State, r rune) {
 	st.Write([]byte("["))
 	for i, s := range ss {
-		start := s.Start()
-		end := s.End()
-		step := s.Step()
-		fmt.Fprintf(st, "%d", start)
-		if end-start > 1 {
-			fmt.Fprintf(st, ":%d", end)
-		}
-		if step > 1 {
-			fmt.Fprintf(st, "~:%d", step)
-		}
+		fmtSlice(st, s, false)
 		if i < len(ss)-1 {
 			st.Write([]byte(", "))
 		}
@@ -0,0 +1,420 @@
+package shapes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// smt.go describes how to export constraints on shapes as an SMT-LIB 2 script of integer arithmetic, so that they may be checked
+// by an external solver, e.g.
+//
+//	z3 constraints.smt2
+//
+// A Var in a dimension (e.g. the a of (a, 3)) is an integer constant. A Var that stands for a whole shape is not written out;
+// instead it is bound to the expressions that it is unified with, and its dimensions are used wherever it appears.
+
+// smtExporter collects the assertions of an SMT-LIB script.
+type smtExporter struct {
+	bound    map[Var]Expr // the expressions bound to Vars that stand for shapes
+	sizeVars varset       // the Vars that are integer constants
+	buf      bytes.Buffer // assertions
+}
+
+// ExportSMT writes the constraints cs as an SMT-LIB 2 script in the logic of quantifier free nonlinear integer arithmetic (QF_NIA).
+// The script is satisfiable if and only if the constraints can be met. A constraint that cannot be written is an error;
+// it is never left out of the script.
+//
+// Each constraint may be a ConstraintsExpr (as made by App), whose unification constraints and SubjectTo are both written,
+// the SubjectTo of a Compound, a SubjectTo, or a comparison BinOp. The unification constraints are written before the others,
+// so that the shapes of the Vars in a SubjectTo are known.
+//
+// Only the dimensions of shapes can be written, so the shapes in a SubjectTo must have known ranks,
+// and unification constraints may only equate Shapes, Abstracts, Sizes, Axes, Arrows and Vars.
+// Division is written as SMT-LIB's div, which is the same as Go's integer division for non-negative sizes.
+// K a ⚟ K b is written dimension by dimension: each pair of dimensions must be equal, or one of them must be 1.
+// As with AreBroadcastable, shapes of different ranks are not broadcastable.
+func ExportSMT(w io.Writer, cs ...interface{}) error {
+	x := &smtExporter{bound: make(map[Var]Expr)}
+
+	var unifs constraints
+	var bools []substitutable
+	for _, c := range cs {
+		switch ct := c.(type) {
+		case ConstraintsExpr:
+			unifs = append(unifs, ct.cs...)
+			if ct.st.A != nil && ct.st.B != nil {
+				bools = append(bools, ct.st)
+			}
+		case Compound:
+			bools = append(bools, ct.SubjectTo)
+		case SubjectTo:
+			bools = append(bools, ct)
+		case BinOp:
+			bools = append(bools, ct)
+		case E2:
+			bools = append(bools, ct.BinOp)
+		default:
+			return errors.Errorf("Cannot export %v of %T to SMT-LIB. Expected a ConstraintsExpr, a Compound, a SubjectTo or a BinOp", c, c)
+		}
+	}
+
+	for _, c := range unifs {
+		fmt.Fprintf(&x.buf, "; %v\n", c)
+		if err := x.unify(c.a, c.b); err != nil {
+			return errors.Wrapf(err, "Cannot export %v to SMT-LIB", c)
+		}
+	}
+	for _, b := range bools {
+		term, err := x.boolTerm(b)
+		if err != nil {
+			return errors.Wrapf(err, "Cannot export %v to SMT-LIB", b)
+		}
+		fmt.Fprintf(&x.buf, "; %v\n(assert %s)\n", b, term)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("(set-option :produce-models true)\n")
+	out.WriteString("(set-logic QF_NIA)\n")
+	sort.Sort(x.sizeVars)
+	for _, v := range x.sizeVars {
+		fmt.Fprintf(&out, "(declare-const %s Int)\n(assert (>= %s 0))\n", smtSymbol(v), smtSymbol(v))
+	}
+	x.buf.WriteTo(&out)
+	out.WriteString("(check-sat)\n(get-model)\n")
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// smtSymbol returns the SMT-LIB symbol of a Var. Vars that are not ASCII letters are quoted.
+func smtSymbol(v Var) string {
+	if (v >= 'a' && v <= 'z') || (v >= 'A' && v <= 'Z') {
+		return string(rune(v))
+	}
+	return "|" + string(rune(v)) + "|"
+}
+
+// smtInt returns the SMT-LIB term of an integer. SMT-LIB has no negative literals.
+func smtInt(n int) string {
+	if n < 0 {
+		return "(- " + strconv.Itoa(-n) + ")"
+	}
+	return strconv.Itoa(n)
+}
+
+// smtApply returns the SMT-LIB term of op applied to the terms, where unit is the result of applying op to no terms.
+func smtApply(op, unit string, terms []string) string {
+	switch len(terms) {
+	case 0:
+		return unit
+	case 1:
+		return terms[0]
+	}
+	return "(" + op + " " + strings.Join(terms, " ") + ")"
+}
+
+func (x *smtExporter) assert(term string) { fmt.Fprintf(&x.buf, "(assert %s)\n", term) }
+
+// resolve returns the expression that a Var which stands for a shape is bound to.
+func (x *smtExporter) resolve(e Expr) Expr {
+	for {
+		v, ok := e.(Var)
+		if !ok {
+			return e
+		}
+		b, ok := x.bound[v]
+		if !ok {
+			return e
+		}
+		e = b
+	}
+}
+
+// unify writes the assertions that make a and b equal.
+func (x *smtExporter) unify(a, b Expr) error {
+	a, b = x.resolve(a), x.resolve(b)
+	if av, ok := a.(Var); ok {
+		if av != b {
+			x.bound[av] = b
+		}
+		return nil
+	}
+	if bv, ok := b.(Var); ok {
+		x.bound[bv] = a
+		return nil
+	}
+
+	if aa, ok := a.(Arrow); ok {
+		ba, ok := b.(Arrow)
+		if !ok {
+			return errors.Errorf("Cannot unify %v with %v", a, b)
+		}
+		if err := x.unify(aa.A, ba.A); err != nil {
+			return err
+		}
+		return x.unify(aa.B, ba.B)
+	}
+
+	ad, err := x.dims(a)
+	if err != nil {
+		return err
+	}
+	bd, err := x.dims(b)
+	if err != nil {
+		return err
+	}
+	if len(ad) != len(bd) {
+		fmt.Fprintf(&x.buf, "; the ranks of %v and %v differ\n", a, b)
+		x.assert("false")
+		return nil
+	}
+	for i := range ad {
+		if ad[i] != bd[i] {
+			x.assert("(= " + ad[i] + " " + bd[i] + ")")
+		}
+	}
+	return nil
+}
+
+// dims returns the SMT-LIB terms of the dimensions of a shape.
+func (x *smtExporter) dims(e Expr) ([]string, error) {
+	switch et := x.resolve(e).(type) {
+	case Shape:
+		retVal := make([]string, 0, len(et))
+		for _, d := range et {
+			retVal = append(retVal, smtInt(d))
+		}
+		return retVal, nil
+	case UnaryOp:
+		if et.Op == Const {
+			return x.dims(et.A)
+		}
+	case Abstract:
+		retVal := make([]string, 0, len(et))
+		for _, d := range et {
+			term, err := x.intTerm(d.(substitutable))
+			if err != nil {
+				return nil, err
+			}
+			retVal = append(retVal, term)
+		}
+		return retVal, nil
+	case intslike:
+		ints := et.AsInts()
+		retVal := make([]string, 0, len(ints))
+		for _, d := range ints {
+			retVal = append(retVal, smtInt(d))
+		}
+		return retVal, nil
+	case Var:
+		return nil, errors.Errorf("The rank of %v is unknown", et)
+	}
+	return nil, errors.Errorf("Cannot write the dimensions of %v of %T", e, e)
+}
+
+// intTerm returns the SMT-LIB term of an expression that resolves to a Size.
+func (x *smtExporter) intTerm(a substitutable) (string, error) {
+	switch at := a.(type) {
+	case Size:
+		return smtInt(int(at)), nil
+	case Var:
+		if !x.sizeVars.Contains(at) {
+			x.sizeVars = append(x.sizeVars, at)
+		}
+		return smtSymbol(at), nil
+	case E2:
+		return x.intTerm(at.BinOp)
+	case BinOp:
+		var op string
+		switch at.Op {
+		case Add:
+			op = "+"
+		case Sub:
+			op = "-"
+		case Mul:
+			op = "*"
+		case Div:
+			op = "div"
+		default:
+			return "", errors.Errorf("Expected %v to be an arithmetic operation", at)
+		}
+		A, err := x.intTerm(at.A)
+		if err != nil {
+			return "", err
+		}
+		B, err := x.intTerm(at.B)
+		if err != nil {
+			return "", err
+		}
+		return "(" + op + " " + A + " " + B + ")", nil
+	case UnaryOp:
+		if at.Op == Const {
+			return x.intTerm(at.A)
+		}
+		dims, err := x.dims(at.A)
+		if err != nil {
+			return "", err
+		}
+		switch at.Op {
+		case Dims:
+			return strconv.Itoa(len(dims)), nil
+		case Prod:
+			return smtApply("*", "1", dims), nil
+		case Sum:
+			return smtApply("+", "0", dims), nil
+		}
+		return "", errors.Errorf(unaryOpResolveErr, at)
+	case IndexOf:
+		dims, err := x.dims(at.A)
+		if err != nil {
+			return "", err
+		}
+		if int(at.I) < 0 || int(at.I) >= len(dims) {
+			return "", newInvalidAxisError(Axis(at.I), len(dims), at.A)
+		}
+		return dims[at.I], nil
+	}
+	return "", errors.Errorf("Cannot write %v of %T as an integer", a, a)
+}
+
+// boolTerm returns the SMT-LIB term of a SubjectTo, a comparison BinOp or a broadcast.
+func (x *smtExporter) boolTerm(a substitutable) (string, error) {
+	var op OpType
+	var A, B substitutable
+	switch at := a.(type) {
+	case SubjectTo:
+		op, A, B = at.OpType, at.A, at.B
+	case BinOp:
+		op, A, B = at.Op, at.A, at.B
+	case E2:
+		op, A, B = at.Op, at.A, at.B
+	default:
+		return "", errors.Errorf("Expected %v of %T to be a SubjectTo or a BinOp", a, a)
+	}
+
+	switch op {
+	case And, Or:
+		l, err := x.boolTerm(A)
+		if err != nil {
+			return "", err
+		}
+		r, err := x.boolTerm(B)
+		if err != nil {
+			return "", err
+		}
+		if op == And {
+			return "(and " + l + " " + r + ")", nil
+		}
+		return "(or " + l + " " + r + ")", nil
+	case Bc:
+		return x.bcTerm(A, B)
+	case Eq, Ne, Lt, Gt, Lte, Gte:
+	default:
+		return "", errors.Errorf("Expected %v to be a comparison, a broadcast or a logical operation", a)
+	}
+
+	// ∀a < ∀b compares every dimension of a to the same dimension of b, and n < ∀b compares n to every dimension of b.
+	ls, lok, err := x.forAll(A)
+	if err != nil {
+		return "", err
+	}
+	rs, rok, err := x.forAll(B)
+	if err != nil {
+		return "", err
+	}
+	if !lok {
+		if ls, err = x.intTerms(A); err != nil {
+			return "", err
+		}
+	}
+	if !rok {
+		if rs, err = x.intTerms(B); err != nil {
+			return "", err
+		}
+	}
+	switch {
+	case lok && rok:
+		if len(ls) != len(rs) {
+			return "false", nil
+		}
+	case lok:
+		rs = repeatString(rs[0], len(ls))
+	case rok:
+		ls = repeatString(ls[0], len(rs))
+	}
+
+	terms := make([]string, 0, len(ls))
+	for i := range ls {
+		terms = append(terms, smtCmp(op, ls[i], rs[i]))
+	}
+	return smtApply("and", "true", terms), nil
+}
+
+// bcTerm returns the SMT-LIB term of A ⚟ B, i.e. that the shapes A and B are mutually broadcastable.
+func (x *smtExporter) bcTerm(A, B substitutable) (string, error) {
+	ls, err := x.dims(A.(Expr))
+	if err != nil {
+		return "", err
+	}
+	rs, err := x.dims(B.(Expr))
+	if err != nil {
+		return "", err
+	}
+	if len(ls) != len(rs) {
+		return "false", nil
+	}
+	terms := make([]string, 0, len(ls))
+	for i := range ls {
+		terms = append(terms, "(or (= "+ls[i]+" "+rs[i]+") (= "+ls[i]+" 1) (= "+rs[i]+" 1))")
+	}
+	return smtApply("and", "true", terms), nil
+}
+
+// forAll returns the terms of the dimensions of the shape under a ForAll.
+func (x *smtExporter) forAll(a substitutable) ([]string, bool, error) {
+	u, ok := a.(UnaryOp)
+	if !ok || u.Op != ForAll {
+		return nil, false, nil
+	}
+	dims, err := x.dims(u.A)
+	return dims, true, err
+}
+
+func (x *smtExporter) intTerms(a substitutable) ([]string, error) {
+	term, err := x.intTerm(a)
+	if err != nil {
+		return nil, err
+	}
+	return []string{term}, nil
+}
+
+func repeatString(s string, n int) []string {
+	retVal := make([]string, n)
+	for i := range retVal {
+		retVal[i] = s
+	}
+	return retVal
+}
+
+func smtCmp(op OpType, a, b string) string {
+	switch op {
+	case Eq:
+		return "(= " + a + " " + b + ")"
+	case Ne:
+		return "(not (= " + a + " " + b + "))"
+	case Lt:
+		return "(< " + a + " " + b + ")"
+	case Gt:
+		return "(> " + a + " " + b + ")"
+	case Lte:
+		return "(<= " + a + " " + b + ")"
+	case Gte:
+		return "(>= " + a + " " + b + ")"
+	}
+	panic("unreachable")
+}
@@ -0,0 +1,154 @@
+package shapes
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+var smtMatMul = MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')})
+
+var smtTests = []struct {
+	name string
+	cs   []interface{}
+
+	contains []string
+	err      bool
+}{
+	{"App", []interface{}{App(smtMatMul, Shape{2, 3})}, []string{"(declare-const a Int)", "(assert (= a 2))", "(assert (= b 3))"}, false},
+	{"rank mismatch", []interface{}{App(smtMatMul, Shape{2, 3, 4})}, []string{"(assert false)"}, false},
+	{"Prod", []interface{}{SubjectTo{Eq, UnaryOp{Prod, Abstract{Var('a'), Var('b')}}, UnaryOp{Prod, Shape{6}}}}, []string{"(assert (= (* a b) 6))"}, false},
+	{"Sum and Dims", []interface{}{SubjectTo{Gt, UnaryOp{Sum, Abstract{Var('a')}}, UnaryOp{Dims, Shape{2, 3}}}}, []string{"(assert (> a 2))"}, false},
+	{"BinOp", []interface{}{BinOp{Lt, Var('a'), Size(4)}}, []string{"(assert (< a 4))"}, false},
+	{"arithmetic", []interface{}{BinOp{Eq, E2{BinOp{Div, Var('a'), Size(2)}}, E2{BinOp{Sub, Var('b'), Size(1)}}}}, []string{"(assert (= (div a 2) (- b 1)))"}, false},
+	{"Ne", []interface{}{BinOp{Ne, Var('a'), Size(0)}}, []string{"(assert (not (= a 0)))"}, false},
+	{"Or", []interface{}{SubjectTo{Or, BinOp{Eq, Var('a'), Size(1)}, BinOp{Eq, Var('a'), Size(3)}}}, []string{"(assert (or (= a 1) (= a 3)))"}, false},
+	{"ForAll", []interface{}{SubjectTo{Lt, UnaryOp{ForAll, Abstract{Var('a'), Size(2)}}, UnaryOp{ForAll, Shape{3, 4}}}}, []string{"(assert (and (< a 3) (< 2 4)))"}, false},
+	{"ForAll of unequal ranks", []interface{}{SubjectTo{Lt, UnaryOp{ForAll, Shape{1}}, UnaryOp{ForAll, Shape{3, 4}}}}, []string{"(assert false)"}, false},
+	{"scalar and ForAll", []interface{}{SubjectTo{Gte, IndexOf{0, Abstract{Var('a')}}, UnaryOp{ForAll, Axes{1, 2}}}}, []string{"(assert (and (>= a 1) (>= a 2)))"}, false},
+	{"IndexOf", []interface{}{SubjectTo{Eq, IndexOf{1, Abstract{Var('a'), Var('b')}}, Size(5)}}, []string{"(assert (= b 5))"}, false},
+	{"quoted symbol", []interface{}{BinOp{Gt, Var('α'), Size(1)}}, []string{"(declare-const |α| Int)", "(assert (> |α| 1))"}, false},
+	{"SubjectTo before its App", []interface{}{
+		SubjectTo{Eq, UnaryOp{Prod, Var('x')}, Size(6)},
+		App(MakeArrow(Var('x'), Var('x')), Shape{2, 3}),
+	}, []string{"(assert (= (* 2 3) 6))"}, false},
+	{"Compound", []interface{}{Compound{MakeArrow(Abstract{Var('a')}, Abstract{Var('a')}), SubjectTo{Lt, IndexOf{0, Abstract{Var('a')}}, Size(8)}}}, []string{"(assert (< a 8))"}, false},
+	{"K of a Size", []interface{}{BinOp{Eq, UnaryOp{Const, Var('a')}, Size(2)}}, []string{"(assert (= a 2))"}, false},
+	{"broadcast", []interface{}{SubjectTo{Bc, UnaryOp{Const, Abstract{Var('a'), Size(3)}}, UnaryOp{Const, Shape{1, 3}}}}, []string{"(assert (and (or (= a 1) (= a 1) (= 1 1)) (or (= 3 3) (= 3 1) (= 3 1))))"}, false},
+	{"broadcast of unequal ranks", []interface{}{SubjectTo{Bc, UnaryOp{Const, Shape{3}}, UnaryOp{Const, Shape{1, 3}}}}, []string{"(assert false)"}, false},
+
+	{"unknown rank", []interface{}{SubjectTo{Eq, UnaryOp{Prod, Var('a')}, Size(6)}}, nil, true},
+	{"not a constraint", []interface{}{Shape{2, 3}}, nil, true},
+	{"not a comparison", []interface{}{BinOp{Add, Var('a'), Size(1)}}, nil, true},
+	{"intrinsic", []interface{}{App(MakeArrow(Var('a'), TransposeOf{Axes{1, 0}, Var('a')}), Shape{2, 3})}, nil, false},
+	{"unification with an intrinsic", []interface{}{
+		App(MakeArrow(Abstract{Var('a'), Var('b')}, TransposeOf{Axes{1, 0}, Var('b')}, Var('b')), Shape{2, 3}),
+		App(MakeArrow(TransposeOf{Axes{1, 0}, Var('x')}, Var('x')), Shape{2, 3}),
+	}, nil, true},
+}
+
+func TestExportSMT(t *testing.T) {
+	for _, c := range smtTests {
+		var buf bytes.Buffer
+		err := ExportSMT(&buf, c.cs...)
+		if checkErr(t, c.err, err, "ExportSMT", c.name) {
+			continue
+		}
+		out := buf.String()
+		if !strings.HasPrefix(out, "(set-option :produce-models true)\n(set-logic QF_NIA)\n") || !strings.HasSuffix(out, "(check-sat)\n(get-model)\n") {
+			t.Errorf("%v: expected a complete SMT-LIB script. Got\n%v", c.name, out)
+		}
+		for _, s := range c.contains {
+			if !strings.Contains(out, s) {
+				t.Errorf("%v: expected %q in\n%v", c.name, s, out)
+			}
+		}
+	}
+}
+
+// TestExportSMT_oracle checks Infer against an external solver, if z3 is installed.
+func TestExportSMT_oracle(t *testing.T) {
+	z3, err := exec.LookPath("z3")
+	if err != nil {
+		t.Skip("z3 is not installed")
+	}
+
+	fns := []Expr{
+		smtMatMul,
+		Compound{MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('a')}), SubjectTo{Lt, IndexOf{1, Abstract{Var('a'), Var('b')}}, Size(4)}},
+		Compound{MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b')}), SubjectTo{Eq, UnaryOp{Prod, Abstract{Var('a'), Var('b')}}, Size(6)}},
+	}
+	args := []Expr{Shape{2, 3}, Shape{3, 2}, Shape{6, 1}, Shape{2, 5}, Shape{2, 3, 4}}
+	for _, fn := range fns {
+		for _, arg := range args {
+			ce := App(fn, arg)
+			var buf bytes.Buffer
+			if err := ExportSMT(&buf, ce); err != nil {
+				t.Errorf("Unable to export %v @ %v: %v", fn, arg, err)
+				continue
+			}
+			cmd := exec.Command(z3, "-in")
+			cmd.Stdin = &buf
+			out, _ := cmd.Output()
+			sat := strings.HasPrefix(string(out), "sat")
+
+			_, err := Infer(App(fn, arg))
+			if sat != (err == nil) {
+				t.Errorf("%v @ %v: z3 says sat is %t, but Infer returned %v", fn, arg, sat, err)
+			}
+		}
+	}
+}
+
+// TestExportSMT_add exports the constraints of the add signature once both of its arguments are known.
+func TestExportSMT_add(t *testing.T) {
+	add, err := Parse("{ a → b → (a||b) | (K a ⚟ K b) }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial, err := InferApp(add, Abstract{Var('x'), Size(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportSMT(&buf, App(partial, Shape{2, 3})); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		"(declare-const x Int)",
+		"(assert (and (or (= x 2) (= x 1) (= 2 1)) (or (= 3 3) (= 3 1) (= 3 1))))",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in\n%v", s, out)
+		}
+	}
+
+	// the rank of b is not known until the second argument is applied
+	if err := ExportSMT(&buf, add); err == nil {
+		t.Errorf("Expected an error when the ranks of the shapes are unknown")
+	}
+}
+
+func ExampleExportSMT() {
+	matmul := MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')})
+	if err := ExportSMT(os.Stdout, App(matmul, Shape{2, 3})); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// (set-option :produce-models true)
+	// (set-logic QF_NIA)
+	// (declare-const a Int)
+	// (assert (>= a 0))
+	// (declare-const b Int)
+	// (assert (>= b 0))
+	// ; {(a, b) → (b, c) → (a, c) = (2, 3) → d}
+	// (assert (= a 2))
+	// (assert (= b 3))
+	// (check-sat)
+	// (get-model)
+}
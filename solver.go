@@ -9,20 +9,26 @@ import (
 
 // solver.go implements the constraint solvers
 // there are two kinds of constraints to solve: variable constraints and SubjectTo constraints.
+//
+// Variable constraints are solved by a unifier that keeps the Vars in a union-find, so that binding a Var costs about the same
+// no matter how many constraints have already been solved. Substitutions are only applied once, when the solution is read out.
 
 // exprConstraint says that A must be equal to B
 type exprConstraint struct {
 	a, b Expr
+
+	prov *Provenance // where the constraint came from, if known
 }
 
 func (c exprConstraint) apply(ss substitutions) substitutable {
 	return exprConstraint{
-		a: c.a.apply(ss).(Expr),
-		b: c.b.apply(ss).(Expr),
+		a:    c.a.apply(ss).(Expr),
+		b:    c.b.apply(ss).(Expr),
+		prov: c.prov,
 	}
 }
 
-func (c exprConstraint) freevars() varset { return exprtup(c).freevars() }
+func (c exprConstraint) freevars() varset { return (exprtup{c.a, c.b}).freevars() }
 
 func (c exprConstraint) Format(f fmt.State, r rune) { fmt.Fprintf(f, "{%v = %v}", c.a, c.b) }
 
@@ -46,66 +52,90 @@ func (cs constraints) freevars() (retVal varset) {
 }
 
 func solve(cs constraints, subs substitutions) (newSubs substitutions, err error) {
-	switch len(cs) {
-	case 0:
+	return solveWith(nil, cs, subs)
+}
+
+// solveWith solves the constraints, recording each step in tr (which may be nil).
+func solveWith(tr *Trace, cs constraints, subs substitutions) (newSubs substitutions, err error) {
+	return solveEach(tr, cs, subs, nil)
+}
+
+// solveEach is like solveWith, but calls each (which may be nil) with the substitutions that solve the constraints so far,
+// every time a constraint is solved. When each is not nil, a recursive substitution fails the constraint that makes it,
+// so the constraint that fails is always the one after the last call to each.
+func solveEach(tr *Trace, cs constraints, subs substitutions, each func(i int, ss substitutions)) (newSubs substitutions, err error) {
+	if len(cs) == 0 {
 		return subs, nil
-	default:
-		var ss substitutions
-		c := cs[0]
-		if ss, err = unify(c.a.(substitutableExpr), c.b.(substitutableExpr)); err != nil {
+	}
+	u := newUnifier(tr)
+	for _, s := range subs {
+		sub, ok := s.Sub.(substitutableExpr)
+		if !ok {
+			return nil, errors.Errorf(cannotBind, s.For, s.Sub, s.Sub)
+		}
+		if err = u.unify(s.For, sub); err != nil {
 			return nil, err
 		}
-		newSubs = compose(ss, subs)
-		cs2 := cs[1:].apply(newSubs).(constraints)
-		return solve(cs2, newSubs)
 	}
-}
-
-func unify(a, b substitutableExpr) (ss substitutions, err error) {
-	switch at := a.(type) {
-	case Var:
-		return bind(at, b)
-	default:
-		if eq(a, b) {
-			return nil, nil
+	for i, c := range cs {
+		u.c = i
+		tr.step(StepConstraint, "%v", c)
+		if err = u.unify(c.a.(substitutableExpr), c.b.(substitutableExpr)); err != nil {
+			return nil, cs.constraintErr(i, err)
 		}
-		if v, ok := b.(Var); ok {
-			return bind(v, a)
+		if tr == nil && each == nil {
+			continue
 		}
-
-		aExprs := a.subExprs()
-		bExprs := b.subExprs()
-		if len(aExprs) == 0 && len(bExprs) == 0 {
-			return nil, errors.Errorf("Unification Fail. %v ~ %v cannot proceed", a, b)
+		ss, err := u.substitutions()
+		switch {
+		case err != nil && each != nil:
+			return nil, cs.constraintErr(i, err)
+		case err != nil:
+			continue
+		}
+		tr.subs(StepCompose, ss)
+		if each != nil {
+			each(i, ss)
 		}
-		if len(aExprs) != len(bExprs) {
-			return nil, errors.Errorf("Unification Fail. %v ~ %v cannot proceed as they do not contain the same amount of sub-expressions. %v has %d subexpressions while %v has %d subexpressions", a, b, a, len(aExprs), b, len(bExprs))
+	}
+	if newSubs, err = u.substitutions(); err != nil {
+		var ue *UnificationError
+		if errors.As(err, &ue) && ue.Recursive {
+			return nil, cs.constraintErr(ue.c, err)
 		}
-		return unifyMany(aExprs, bExprs)
+		return nil, err
 	}
-	panic("TODO")
+	return newSubs, nil
 }
 
-func unifyMany(as, bs []substitutableExpr) (ss substitutions, err error) {
-	for i, a := range as {
-		b := bs[i]
-		if len(ss) > 0 {
-			a = a.apply(ss).(substitutableExpr)
-			b = b.apply(ss).(substitutableExpr)
-		}
+// constraintErr attributes an error to the i-th constraint.
+func (cs constraints) constraintErr(i int, err error) error {
+	if c := cs[i]; c.prov != nil {
+		return newArgError(c, err)
+	}
+	return err
+}
 
-		var s2 substitutions
-		if s2, err = unify(a, b); err != nil {
-			return nil, err
-		}
+func unify(a, b substitutableExpr) (ss substitutions, err error) { return unifyWith(nil, a, b) }
 
-		if ss == nil {
-			ss = s2
-		} else {
-			ss = compose(ss, s2)
+// unifyWith unifies a and b, recording each step in tr (which may be nil).
+func unifyWith(tr *Trace, a, b substitutableExpr) (ss substitutions, err error) {
+	u := newUnifier(tr)
+	if err = u.unify(a, b); err != nil {
+		return nil, err
+	}
+	return u.substitutions()
+}
+
+// termVars returns the Vars of a term, including those that freevars does not find (e.g. those in an operation in an Abstract).
+func termVars(e substitutable) varset {
+	retVal := e.freevars()
+	if se, ok := e.(substitutableExpr); ok {
+		for _, sub := range se.subExprs() {
+			retVal = append(retVal, termVars(sub)...)
 		}
 	}
-	return
+	return unique(retVal)
 }
 
 // tmp solution
@@ -113,14 +143,319 @@ func eq(a, b interface{}) bool {
 	return reflect.DeepEqual(a, b)
 }
 
-func bind(v Var, E substitutable) (substitutions, error) {
-	if occurs(v, E) {
-		return nil, errors.Errorf("Recursive unification")
+// unifier unifies pairs of expressions. The Vars are the nodes of a union-find: each class of Vars that have been unified
+// with one another is either bound to a term (an expression that is not a Var), or stands for its representative Var.
+//
+// Unification is iterative: the pairs of sub-expressions that remain to be unified are kept on a worklist.
+// The occurs check is done when a class is bound to a term, as a search of the term and the terms of its Vars for the class.
+type unifier struct {
+	ids    map[Var]int // the node of each Var
+	vars   []Var       // the Var of each node
+	parent []int
+	rank   []int
+
+	// of the roots only
+	rep  []int               // the node that the class stands for if it is not bound to a term
+	term []substitutableExpr // the term that the class is bound to, if any
+
+	bound []int // the nodes that have been bound, in the order that they were bound
+	to    []int // the node of the Var that each node was bound to, or -1 if it was bound to a term
+	cons  []int // the constraint that each node was bound by
+	c     int   // the constraint being unified
+
+	work []unifyPair
+	tr   *Trace
+}
+
+// unifyPair is a pair of expressions on the worklist of a unifier.
+type unifyPair struct {
+	a, b substitutableExpr
+	path []int // the indices of the sub-expressions from the root of the constraint
+}
+
+func newUnifier(tr *Trace) *unifier {
+	return &unifier{ids: make(map[Var]int), tr: tr}
+}
+
+// node returns the node of a Var, adding it if need be.
+func (u *unifier) node(v Var) int {
+	if n, ok := u.ids[v]; ok {
+		return n
+	}
+	n := len(u.vars)
+	u.ids[v] = n
+	u.vars = append(u.vars, v)
+	u.parent = append(u.parent, n)
+	u.rank = append(u.rank, 0)
+	u.rep = append(u.rep, n)
+	u.term = append(u.term, nil)
+	u.to = append(u.to, -1)
+	u.cons = append(u.cons, -1)
+	return n
+}
+
+// find returns the root of the class of a node, halving the path to it along the way.
+func (u *unifier) find(n int) int {
+	for u.parent[n] != n {
+		u.parent[n] = u.parent[u.parent[n]]
+		n = u.parent[n]
+	}
+	return n
+}
+
+// root returns the root of the class of a Var, if the Var has been unified with anything.
+func (u *unifier) root(v Var) (int, bool) {
+	n, ok := u.ids[v]
+	if !ok {
+		return 0, false
+	}
+	return u.find(n), true
+}
+
+// walk returns the term that a Var is bound to, or the representative Var of its class. Any other expression is returned as is.
+func (u *unifier) walk(e substitutableExpr) substitutableExpr {
+	v, ok := e.(Var)
+	if !ok {
+		return e
+	}
+	r, ok := u.root(v)
+	if !ok {
+		return v
+	}
+	if u.term[r] != nil {
+		return u.term[r]
+	}
+	return u.vars[u.rep[r]]
+}
+
+// unify unifies a and b, and the pairs of sub-expressions that it leads to.
+func (u *unifier) unify(a, b substitutableExpr) error {
+	defer u.tr.at(0)
+	u.work = append(u.work[:0], unifyPair{a: a, b: b})
+	for len(u.work) > 0 {
+		p := u.work[len(u.work)-1]
+		u.work = u.work[:len(u.work)-1]
+
+		a, b := u.walk(p.a), u.walk(p.b)
+		if u.tr != nil {
+			u.tr.at(len(p.path))
+			u.tr.step(StepUnify, "%v ~ %v", u.show(a), u.show(b))
+		}
+		av, aok := a.(Var)
+		bv, bok := b.(Var)
+		switch {
+		case aok && bok:
+			if av != bv {
+				u.union(av, bv)
+			}
+		case aok:
+			if err := u.bind(av, b); err != nil {
+				return err
+			}
+		case bok:
+			if err := u.bind(bv, a); err != nil {
+				return err
+			}
+		case eq(a, b):
+		default:
+			aExprs := a.subExprs()
+			bExprs := b.subExprs()
+			if len(aExprs) == 0 || len(aExprs) != len(bExprs) {
+				return u.mismatch(a, b, len(aExprs), len(bExprs), p.path)
+			}
+			// the sub-expressions are pushed in reverse, so that they are unified from left to right
+			for i := len(aExprs) - 1; i >= 0; i-- {
+				path := make([]int, len(p.path)+1)
+				copy(path, p.path)
+				path[len(p.path)] = i
+				u.work = append(u.work, unifyPair{a: aExprs[i], b: bExprs[i], path: path})
+			}
+		}
+	}
+	return nil
+}
+
+// union merges the classes of two unbound Vars, so that a stands for b.
+func (u *unifier) union(a, b Var) {
+	ra, rb := u.find(u.node(a)), u.find(u.node(b))
+	rep := u.rep[rb]
+	u.markBound(u.rep[ra])
+	u.to[u.rep[ra]] = rep
+	u.tr.step(StepBind, "%v ↦ %v", a, b)
+
+	if u.rank[ra] > u.rank[rb] {
+		ra, rb = rb, ra
+	}
+	u.parent[ra] = rb
+	if u.rank[ra] == u.rank[rb] {
+		u.rank[rb]++
+	}
+	u.rep[rb] = rep
+}
+
+// bind binds the class of an unbound Var to a term. The term must be an expression, or something that may be made into one
+// (i.e. a BinOp, which becomes an E2). An error is returned if the Var occurs in the term, directly or through the terms of its Vars.
+func (u *unifier) bind(v Var, t substitutableExpr) error {
+	if e, err := decodedExpr(t); err != nil || e == nil {
+		return errors.Errorf(cannotBind, v, t, t)
+	}
+	r := u.find(u.node(v))
+	if u.occurs(r, t) {
+		err := newUnificationError(v, t, 0, 0, true)
+		var ue *UnificationError
+		if errors.As(err, &ue) {
+			ue.c = u.c
+		}
+		return err
 	}
-	return substitutions{{Sub: E.(Expr), For: v}}, nil
+	u.term[r] = t
+	u.markBound(u.rep[r])
+	if u.tr != nil {
+		u.tr.step(StepBind, "%v ↦ %v", v, u.show(t))
+	}
+	return nil
 }
 
-func occurs(v Var, in substitutable) bool {
-	vs := in.freevars()
-	return vs.Contains(v)
+// occurs returns true if the class of the root r occurs in t, or in the terms that the Vars of t are bound to.
+func (u *unifier) occurs(r int, t substitutableExpr) bool {
+	stack := termVars(t)
+	var seen map[int]struct{}
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		m, ok := u.root(v)
+		if !ok {
+			continue
+		}
+		if m == r {
+			return true
+		}
+		if u.term[m] == nil {
+			continue
+		}
+		if _, ok := seen[m]; ok {
+			continue
+		}
+		if seen == nil {
+			seen = make(map[int]struct{})
+		}
+		seen[m] = struct{}{}
+		stack = append(stack, termVars(u.term[m])...)
+	}
+	return false
+}
+
+// show returns e with the substitutions so far applied, for the trace.
+func (u *unifier) show(e substitutableExpr) substitutable {
+	ss, err := u.substitutions()
+	if err != nil || len(ss) == 0 {
+		return e
+	}
+	return e.apply(ss.resolved())
+}
+
+func (u *unifier) markBound(n int) {
+	u.bound = append(u.bound, n)
+	u.cons[n] = u.c
+}
+
+// mismatch returns the error for two terms that cannot be unified. The terms are shown with the substitutions so far applied.
+func (u *unifier) mismatch(a, b substitutableExpr, numA, numB int, path []int) error {
+	if ss, err := u.substitutions(); err == nil && len(ss) > 0 {
+		ss = ss.resolved()
+		if a2, ok := a.apply(ss).(substitutableExpr); ok {
+			a = a2
+		}
+		if b2, ok := b.apply(ss).(substitutableExpr); ok {
+			b = b2
+		}
+	}
+	err := newUnificationError(a, b, numA, numB, false)
+	var ue *UnificationError
+	if errors.As(err, &ue) {
+		ue.Path = path
+	}
+	return err
+}
+
+// substitutions reads out the substitutions that solve what has been unified so far, the most recently bound Var first.
+//
+// A Var that was unified with another Var is substituted by that Var, as it was when they were unified. Thus unifying (a, b)
+// with (b, c) gives {c/b, b/a}, and the substitutions must be resolved (see resolved) before they are applied.
+// A Var that was bound to a term is substituted by the term, fully resolved.
+//
+// An error is returned if a Var occurs in the term it is bound to, directly or through the terms of other Vars.
+func (u *unifier) substitutions() (substitutions, error) {
+	if len(u.bound) == 0 {
+		return nil, nil
+	}
+	resolved := make([]Expr, len(u.vars))
+	state := make([]byte, len(u.vars)) // 0: unvisited, 1: being resolved, 2: resolved
+	retVal := make(substitutions, 0, len(u.bound))
+	for i := len(u.bound) - 1; i >= 0; i-- {
+		n := u.bound[i]
+		if u.to[n] >= 0 {
+			retVal = append(retVal, substitution{Sub: u.vars[u.to[n]], For: u.vars[n]})
+			continue
+		}
+		r := u.find(n)
+		if err := u.resolve(r, resolved, state); err != nil {
+			return nil, err
+		}
+		retVal = append(retVal, substitution{Sub: resolved[r], For: u.vars[n]})
+	}
+	return retVal, nil
+}
+
+// resolve applies the substitutions of the Vars in the term of the root r to it, after resolving their own terms, depth first.
+func (u *unifier) resolve(r int, resolved []Expr, state []byte) error {
+	stack := []int{r}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		switch state[n] {
+		case 0:
+			state[n] = 1
+			for _, v := range termVars(u.term[n]) {
+				m, ok := u.root(v)
+				if !ok || u.term[m] == nil {
+					continue
+				}
+				switch state[m] {
+				case 0:
+					stack = append(stack, m)
+				case 1:
+					err := newUnificationError(u.vars[u.rep[m]], u.term[m], 0, 0, true)
+					var ue *UnificationError
+					if errors.As(err, &ue) {
+						ue.c = u.cons[u.rep[m]]
+						if c := u.cons[u.rep[n]]; c > ue.c {
+							ue.c = c
+						}
+					}
+					return err
+				}
+			}
+		case 1:
+			var ss substitutions
+			for _, v := range termVars(u.term[n]) {
+				m, ok := u.root(v)
+				switch {
+				case !ok:
+				case u.term[m] != nil:
+					ss = append(ss, substitution{Sub: resolved[m], For: v})
+				case u.vars[u.rep[m]] != v:
+					ss = append(ss, substitution{Sub: u.vars[u.rep[m]], For: v})
+				}
+			}
+			var err error
+			if resolved[n], err = decodedExpr(u.term[n].apply(ss)); err != nil {
+				return err
+			}
+			state[n] = 2
+			stack = stack[:len(stack)-1]
+		default:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return nil
 }
@@ -1,8 +1,11 @@
 package shapes
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
+
+	"github.com/pkg/errors"
 )
 
 var unifyTests = []struct {
@@ -47,6 +50,54 @@ var unifyTests = []struct {
 		substitutions{substitution{Sub: Shape{2, 3}, For: Var('a')}},
 		false,
 	},
+
+	{
+		// unify a with a
+		Var('a'), Var('a'),
+		nil,
+		false,
+	},
+
+	{
+		// unify (a, a, b) with (b, 2, c): a, b and c are all the same Var, which is 2. a was unified with b before b was bound.
+		Abstract{Var('a'), Var('a'), Var('b')}, Abstract{Var('b'), Size(2), Var('c')},
+		substitutions{{Sub: Size(2), For: Var('c')}, {Sub: Size(2), For: Var('b')}, {Sub: Var('b'), For: Var('a')}},
+		false,
+	},
+
+	{
+		// unify (a, a + 1) with (b, 3): a BinOp is bound as an E2
+		Abstract{Var('a'), BinOp{Add, Var('a'), Size(1)}}, Abstract{Var('b'), Var('c')},
+		substitutions{{Sub: E2{BinOp{Add, Var('b'), Size(1)}}, For: Var('c')}, {Sub: Var('b'), For: Var('a')}},
+		false,
+	},
+
+	{
+		// unify a with (a) → b
+		Var('a'), Arrow{Abstract{Var('a')}, Var('b')},
+		nil,
+		true,
+	},
+
+	{
+		// diagonals with different offsets have different sizes, so they do not unify
+		DiagOf{Offset: 1, Axis1: 0, Axis2: 1, A: Var('a')}, DiagOf{Offset: -1, Axis1: 0, Axis2: 1, A: Var('b')},
+		nil,
+		true,
+	},
+
+	{
+		DiagEmbedOf{Offset: 1, Axis1: 0, Axis2: 1, A: Var('a')}, DiagEmbedOf{Offset: 2, Axis1: 0, Axis2: 1, A: Var('b')},
+		nil,
+		true,
+	},
+
+	{
+		// unify a with a SubjectTo, which is not an expression
+		Var('a'), SubjectTo{Lt, Size(1), Size(2)},
+		nil,
+		true,
+	},
 }
 
 func TestUnify(t *testing.T) {
@@ -73,6 +124,33 @@ var solveTests = []struct {
 		substitutions{},
 		substitutions{{Var('c'), Var('b')}, {Var('b'), Var('a')}},
 		false},
+
+	// b is bound by the second constraint, after a was bound to it
+	{constraints{{a: Var('a'), b: Abstract{Var('b'), Size(2)}}, {a: Var('b'), b: Size(3)}},
+		nil,
+		substitutions{{Size(3), Var('b')}, {Abstract{Size(3), Size(2)}, Var('a')}},
+		false},
+
+	// a occurs in its own substitution through b
+	{constraints{{a: Var('a'), b: Arrow{Var('b'), Var('b')}}, {a: Var('b'), b: Arrow{Var('a'), Var('a')}}},
+		nil,
+		nil,
+		true},
+
+	// a ↦ c → c, b ↦ d → d and d ↦ c → c, so that c ~ d → d is recursive through the term of d. It must fail rather than loop
+	{constraints{{
+		a: Arrow{MakeArrow(Var('a'), Var('b'), Var('a'), Var('b'), Var('a')), Shape{}},
+		b: Arrow{MakeArrow(Arrow{Var('c'), Var('c')}, Arrow{Var('d'), Var('d')}, Var('d'), Var('c'), Var('c')), Var('e')},
+	}},
+		nil,
+		nil,
+		true},
+
+	// the second constraint fails once the first is solved
+	{constraints{{a: Arrow{Var('a'), Var('a')}, b: Arrow{Shape{2}, Var('b')}}, {a: Var('b'), b: Shape{3}}},
+		nil,
+		nil,
+		true},
 }
 
 func TestSolve(t *testing.T) {
@@ -88,3 +166,119 @@ func TestSolve(t *testing.T) {
 		}
 	}
 }
+
+func TestSubstitutions_resolved(t *testing.T) {
+	for _, c := range []struct {
+		ss, correct substitutions
+	}{
+		{substitutions{{Var('c'), Var('b')}, {Var('b'), Var('a')}}, substitutions{{Var('c'), Var('b')}, {Var('c'), Var('a')}}},
+		{substitutions{{Var('b'), Var('a')}, {Size(2), Var('b')}}, substitutions{{Size(2), Var('a')}, {Size(2), Var('b')}}},
+		{substitutions{{Var('b'), Var('a')}, {Var('a'), Var('b')}}, substitutions{{Var('b'), Var('a')}, {Var('b'), Var('b')}}},
+		{nil, substitutions{}},
+	} {
+		if got := c.ss.resolved(); !reflect.DeepEqual(got, c.correct) {
+			t.Errorf("Resolving %v. Expected %v. Got %v instead", c.ss, c.correct, got)
+		}
+	}
+}
+
+func TestSolve_errors(t *testing.T) {
+	// the failure of a nested sub-expression says where it is
+	_, err := solve(constraints{{a: Arrow{Abstract{Var('a'), Size(2)}, Var('b')}, b: Arrow{Shape{1, 3}, Var('c')}}}, nil)
+	var ue *UnificationError
+	if !errors.As(err, &ue) {
+		t.Fatalf("Expected a *UnificationError. Got %v", err)
+	}
+	if !reflect.DeepEqual(ue.Path, []int{0, 1}) || ue.A != Size(2) || ue.B != Size(3) {
+		t.Errorf("Expected 2 ~ 3 at [0 1]. Got %v ~ %v at %v", ue.A, ue.B, ue.Path)
+	}
+
+	// a cycle that is closed by the second constraint is attributed to it
+	prov := &Provenance{Arg: 2}
+	cs := constraints{{a: Var('a'), b: Abstract{Var('b')}}, {a: Var('b'), b: Abstract{Var('a')}, prov: prov}}
+	_, err = solve(cs, nil)
+	var ae *ArgError
+	if !errors.As(err, &ae) || ae.Arg != 2 {
+		t.Errorf("Expected an *ArgError of arg 2. Got %v", err)
+	}
+	ue = nil
+	if !errors.As(err, &ue) || !ue.Recursive {
+		t.Errorf("Expected a recursive *UnificationError. Got %v", err)
+	}
+}
+
+// benchVar returns the i-th Var of a benchmark. The Vars are outside of the letters, so that they do not clash with any.
+func benchVar(i int) Var { return Var(0x10000 + i) }
+
+// chainConstraints returns n constraints that make 2n+1 Vars equal to one another, and then binds the first of them.
+func chainConstraints(n int) constraints {
+	cs := make(constraints, 0, n+1)
+	for i := 0; i < n; i++ {
+		cs = append(cs, exprConstraint{
+			a: Abstract{benchVar(2 * i), benchVar(2*i + 1)},
+			b: Abstract{benchVar(2*i + 1), benchVar(2*i + 2)},
+		})
+	}
+	return append(cs, exprConstraint{a: Abstract{benchVar(0)}, b: Shape{3}})
+}
+
+// matmulConstraints returns the constraints of a chain of n matrix multiplications, each of which takes the result of the one before.
+func matmulConstraints(n int) constraints {
+	cs := make(constraints, 0, n)
+	var x Expr = Shape{2, 3}
+	for i := 0; i < n; i++ {
+		a, b, c := benchVar(4*i), benchVar(4*i+1), benchVar(4*i+2)
+		y := benchVar(4*i + 3)
+		cs = append(cs, exprConstraint{
+			a: MakeArrow(Abstract{a, b}, Abstract{b, c}, Abstract{a, c}),
+			b: MakeArrow(x, Shape{3, 3}, y),
+		})
+		x = y
+	}
+	return cs
+}
+
+func TestSolve_large(t *testing.T) {
+	subs, err := solve(chainConstraints(10000), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 20001 {
+		t.Fatalf("Expected 20001 substitutions. Got %d", len(subs))
+	}
+	for _, s := range subs.resolved() {
+		if s.Sub != Size(3) {
+			t.Fatalf("Expected %v to be substituted by 3. Got %v", s.For, s.Sub)
+		}
+	}
+
+	if subs, err = solve(matmulConstraints(10000), nil); err != nil {
+		t.Fatal(err)
+	}
+	last := benchVar(4*9999 + 3)
+	if got := last.apply(subs.resolved()); !eq(got, Abstract{Size(2), Size(3)}) {
+		t.Errorf("Expected the result of the last matmul to be (2, 3). Got %v", got)
+	}
+}
+
+func BenchmarkSolve(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		cs   func(int) constraints
+	}{
+		{"chain", chainConstraints},
+		{"matmul", matmulConstraints},
+	} {
+		for _, n := range []int{1000, 10000, 100000} {
+			cs := bench.cs(n)
+			b.Run(fmt.Sprintf("%s/%d", bench.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := solve(cs, nil); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -11,35 +11,43 @@ func (s substitution) Format(f fmt.State, r rune) { fmt.Fprintf(f, "{%v/%v}", s.
 
 type substitutions []substitution
 
-func compose(a, b substitutions) substitutions {
-	if len(b) == 0 {
-		return a
+// resolved returns the substitutions with every chain of Vars followed to its end, e.g. {c/b, b/a} becomes {c/b, c/a},
+// so that they may be applied all at once.
+func (ss substitutions) resolved() substitutions {
+	idx := make(map[Var]int, len(ss))
+	for i := len(ss) - 1; i >= 0; i-- {
+		idx[ss[i].For] = i // the first substitution for a Var is the one that apply uses
 	}
-
-	retVal := make(substitutions, len(b), len(b)+len(a))
-	copy(retVal, b)
-	if len(a) == 0 {
-		return retVal
-	}
-
-	retVal = append(retVal, a...)
-
-	for _, s := range retVal {
-		retVal = appendSubs(retVal, substitution{
-			For: s.For,
-			Sub: s.Sub.apply(a).(Expr),
-		})
-	}
-	return retVal
-}
-
-func appendSubs(ss substitutions, s substitution) substitutions {
-	for _, s2 := range ss {
-		if s2.For == s.For {
-			return ss
+	retVal := make(substitutions, len(ss))
+	copy(retVal, ss)
+	done := make([]bool, len(ss))
+	var chain []int
+	for i := range retVal {
+		if done[i] {
+			continue
+		}
+		// follow the chain until a substitution that is done, a term, or a Var that is not substituted (or a cycle)
+		chain = chain[:0]
+		for j := i; !done[j]; {
+			done[j] = true
+			chain = append(chain, j)
+			v, ok := retVal[j].Sub.(Var)
+			if !ok {
+				break
+			}
+			if j, ok = idx[v]; !ok {
+				break
+			}
+		}
+		end := retVal[chain[len(chain)-1]].Sub
+		if v, ok := end.(Var); ok {
+			if j, ok := idx[v]; ok {
+				end = retVal[j].Sub
+			}
+		}
+		for _, j := range chain {
+			retVal[j].Sub = end
 		}
 	}
-	ss = append(ss, s)
-
-	return ss
+	return retVal
 }
@@ -0,0 +1,238 @@
+package shapes
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// tensorfile.go describes how to read the shapes of tensors from the headers of tensor files, without reading the data.
+// The supported file formats are NumPy's .npy and safetensors.
+
+// maxHeaderSize is the largest header that will be read. It is the same limit as the safetensors format's.
+const maxHeaderSize = 100 << 20
+
+// npyMagic is the start of every .npy file.
+const npyMagic = "\x93NUMPY"
+
+// ReadNpyHeader reads the header of a .npy file. It returns the shape and dtype (e.g. "<f4") of the array,
+// and whether the data is in Fortran (i.e. column major) order. Nothing after the header is read, so r is left at the start of the data.
+//
+// Structured dtypes are not supported.
+func ReadNpyHeader(r io.Reader) (shape Shape, dtype string, fortranOrder bool, err error) {
+	var prelude [len(npyMagic) + 2]byte
+	if _, err = io.ReadFull(r, prelude[:]); err != nil {
+		return nil, "", false, errors.Wrap(err, "Unable to read the .npy header")
+	}
+	if string(prelude[:len(npyMagic)]) != npyMagic {
+		return nil, "", false, errors.New("Not a .npy file")
+	}
+
+	var headerLen int
+	switch major := prelude[len(npyMagic)]; major {
+	case 1:
+		var l uint16
+		err = binary.Read(r, binary.LittleEndian, &l)
+		headerLen = int(l)
+	case 2, 3:
+		var l uint32
+		err = binary.Read(r, binary.LittleEndian, &l)
+		headerLen = int(l)
+	default:
+		return nil, "", false, errors.Errorf(unsupportedVersion, major, 3)
+	}
+	if err != nil {
+		return nil, "", false, errors.Wrap(err, "Unable to read the length of the .npy header")
+	}
+	if headerLen > maxHeaderSize {
+		return nil, "", false, errors.Errorf("The .npy header is too long (%d bytes)", headerLen)
+	}
+
+	header := make([]byte, headerLen)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return nil, "", false, errors.Wrap(err, "Unable to read the .npy header")
+	}
+	dict, err := parsePyDict(string(header))
+	if err != nil {
+		return nil, "", false, errors.Wrapf(err, "Unable to parse the .npy header %q", header)
+	}
+
+	var ok bool
+	if dtype, ok = dict["descr"].(string); !ok {
+		return nil, "", false, errors.Errorf("Expected the descr of a .npy header to be a string. Got %v instead", dict["descr"])
+	}
+	if fortranOrder, ok = dict["fortran_order"].(bool); !ok {
+		return nil, "", false, errors.Errorf("Expected the fortran_order of a .npy header to be a bool. Got %v instead", dict["fortran_order"])
+	}
+	if shape, ok = dict["shape"].(Shape); !ok {
+		return nil, "", false, errors.Errorf("Expected the shape of a .npy header to be a tuple. Got %v instead", dict["shape"])
+	}
+	return shape, dtype, fortranOrder, nil
+}
+
+// parsePyDict parses the Python dict literal of a .npy header, e.g. {'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }.
+// Only strings, bools and tuples of ints (which are parsed as a Shape) are supported as values.
+func parsePyDict(s string) (map[string]interface{}, error) {
+	p := pyParser{s: s}
+	retVal := make(map[string]interface{})
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	for !p.accept('}') {
+		k, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(':'); err != nil {
+			return nil, err
+		}
+		if retVal[k], err = p.value(); err != nil {
+			return nil, err
+		}
+		if !p.accept(',') {
+			if err = p.expect('}'); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if p.skipSpace(); p.i != len(p.s) {
+		return nil, errors.Errorf("Unexpected %q after the dict", p.s[p.i:])
+	}
+	return retVal, nil
+}
+
+type pyParser struct {
+	s string
+	i int
+}
+
+func (p *pyParser) skipSpace() {
+	for p.i < len(p.s) && unicode.IsSpace(rune(p.s[p.i])) {
+		p.i++
+	}
+}
+
+// accept skips the byte c if it is next.
+func (p *pyParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.i < len(p.s) && p.s[p.i] == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *pyParser) expect(c byte) error {
+	if !p.accept(c) {
+		return errors.Errorf("Expected %q at %d", c, p.i)
+	}
+	return nil
+}
+
+func (p *pyParser) str() (string, error) {
+	p.skipSpace()
+	if p.i >= len(p.s) || (p.s[p.i] != '\'' && p.s[p.i] != '"') {
+		return "", errors.Errorf("Expected a string at %d", p.i)
+	}
+	end := strings.IndexByte(p.s[p.i+1:], p.s[p.i])
+	if end < 0 {
+		return "", errors.Errorf("Unterminated string at %d", p.i)
+	}
+	retVal := p.s[p.i+1 : p.i+1+end]
+	p.i += end + 2
+	return retVal, nil
+}
+
+func (p *pyParser) value() (interface{}, error) {
+	p.skipSpace()
+	rest := p.s[p.i:]
+	switch {
+	case strings.HasPrefix(rest, "True"):
+		p.i += len("True")
+		return true, nil
+	case strings.HasPrefix(rest, "False"):
+		p.i += len("False")
+		return false, nil
+	case strings.HasPrefix(rest, "("):
+		p.i++
+		retVal := Shape{}
+		for !p.accept(')') {
+			p.skipSpace()
+			j := p.i
+			for j < len(p.s) && p.s[j] >= '0' && p.s[j] <= '9' {
+				j++
+			}
+			d, err := strconv.Atoi(p.s[p.i:j])
+			if err != nil {
+				return nil, errors.Errorf("Expected a size at %d", p.i)
+			}
+			p.i = j
+			retVal = append(retVal, d)
+			if !p.accept(',') {
+				if err = p.expect(')'); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		return retVal, nil
+	}
+	return p.str()
+}
+
+// SafetensorsTensor is the entry of a tensor in the header of a safetensors file.
+type SafetensorsTensor struct {
+	DType       string // e.g. "F32"
+	Shape       Shape
+	DataOffsets [2]int64 // the start and end of the data of the tensor, relative to the end of the header
+}
+
+// ReadSafetensorsHeader reads the header of a safetensors file. It returns the tensors by name, and the metadata of the file (if any).
+// Nothing after the header is read, so r is left at the start of the data.
+func ReadSafetensorsHeader(r io.Reader) (tensors map[string]SafetensorsTensor, metadata map[string]string, err error) {
+	var n uint64
+	if err = binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, nil, errors.Wrap(err, "Unable to read the length of the safetensors header")
+	}
+	if n > maxHeaderSize {
+		return nil, nil, errors.Errorf("The safetensors header is too long (%d bytes)", n)
+	}
+	header := make([]byte, n)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return nil, nil, errors.Wrap(err, "Unable to read the safetensors header")
+	}
+
+	var entries map[string]json.RawMessage
+	if err = json.Unmarshal(header, &entries); err != nil {
+		return nil, nil, errors.Wrap(err, "Unable to parse the safetensors header")
+	}
+	tensors = make(map[string]SafetensorsTensor, len(entries))
+	for name, raw := range entries {
+		if name == "__metadata__" {
+			if err = json.Unmarshal(raw, &metadata); err != nil {
+				return nil, nil, errors.Wrap(err, "Unable to parse the metadata of the safetensors header")
+			}
+			continue
+		}
+		// the shape is a plain list of ints, not the JSON encoding of a Shape
+		var t struct {
+			DType       string   `json:"dtype"`
+			Shape       []int    `json:"shape"`
+			DataOffsets [2]int64 `json:"data_offsets"`
+		}
+		if err = json.Unmarshal(raw, &t); err != nil {
+			return nil, nil, errors.Wrapf(err, "Unable to parse the entry of tensor %q in the safetensors header", name)
+		}
+		if t.DType == "" || t.Shape == nil {
+			return nil, nil, errors.Errorf("Expected the entry of tensor %q in the safetensors header to have a dtype and a shape", name)
+		}
+		tensors[name] = SafetensorsTensor{DType: t.DType, Shape: Shape(t.Shape), DataOffsets: t.DataOffsets}
+	}
+	return tensors, metadata, nil
+}
@@ -0,0 +1,146 @@
+package shapes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// npy returns a .npy file of the given version and header, padded as NumPy does, followed by some data.
+func npy(major byte, header string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(npyMagic)
+	buf.Write([]byte{major, 0})
+	prefix := buf.Len() + 2
+	if major > 1 {
+		prefix += 2
+	}
+	for (prefix+len(header)+1)%64 != 0 {
+		header += " "
+	}
+	header += "\n"
+	if major == 1 {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	} else {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(header)))
+	}
+	buf.WriteString(header)
+	buf.WriteString("DATA")
+	return buf.Bytes()
+}
+
+var npyTests = []struct {
+	name string
+	in   []byte
+
+	shape   Shape
+	dtype   string
+	fortran bool
+	err     bool
+}{
+	{"matrix", npy(1, "{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }"), Shape{2, 3}, "<f4", false, false},
+	{"vector", npy(1, "{'descr': '|u1', 'fortran_order': False, 'shape': (5,), }"), Shape{5}, "|u1", false, false},
+	{"scalar", npy(1, "{'descr': '<f8', 'fortran_order': False, 'shape': (), }"), Shape{}, "<f8", false, false},
+	{"fortran", npy(1, "{'descr': '>i8', 'fortran_order': True, 'shape': (4, 3, 2), }"), Shape{4, 3, 2}, ">i8", true, false},
+	{"version 2", npy(2, "{'descr': '<f4', 'fortran_order': False, 'shape': (70000, 3), }"), Shape{70000, 3}, "<f4", false, false},
+	{"version 3", npy(3, `{"descr": "<U3", "fortran_order": False, "shape": (1,)}`), Shape{1}, "<U3", false, false},
+
+	{"not npy", []byte("\x93NUMPZ\x01\x00"), nil, "", false, true},
+	{"version 4", npy(4, "{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }"), nil, "", false, true},
+	{"truncated", npy(1, "{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }")[:20], nil, "", false, true},
+	{"structured", npy(1, "{'descr': [('x', '<f4')], 'fortran_order': False, 'shape': (2,), }"), nil, "", false, true},
+	{"no shape", npy(1, "{'descr': '<f4', 'fortran_order': False, }"), nil, "", false, true},
+	{"bad shape", npy(1, "{'descr': '<f4', 'fortran_order': False, 'shape': (2, a), }"), nil, "", false, true},
+}
+
+func TestReadNpyHeader(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range npyTests {
+		r := bytes.NewReader(c.in)
+		shape, dtype, fortran, err := ReadNpyHeader(r)
+		if checkErr(t, c.err, err, "ReadNpyHeader", c.name) {
+			continue
+		}
+		assert.Equal(c.shape, shape, "%q: shape", c.name)
+		assert.Equal(c.dtype, dtype, "%q: dtype", c.name)
+		assert.Equal(c.fortran, fortran, "%q: fortran order", c.name)
+
+		// only the header is read
+		rest, _ := ioutil.ReadAll(r)
+		assert.Equal("DATA", string(rest), "%q: data", c.name)
+	}
+}
+
+// safetensors returns a safetensors file with the given header, followed by some data.
+func safetensors(header string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint64(len(header)))
+	buf.WriteString(header)
+	buf.WriteString("DATA")
+	return buf.Bytes()
+}
+
+func TestReadSafetensorsHeader(t *testing.T) {
+	assert := assert.New(t)
+	r := bytes.NewReader(safetensors(`{
+		"__metadata__": {"format": "pt"},
+		"w": {"dtype": "F32", "shape": [784, 10], "data_offsets": [0, 31360]},
+		"b": {"dtype": "F32", "shape": [10], "data_offsets": [31360, 31400]},
+		"step": {"dtype": "I64", "shape": [], "data_offsets": [31400, 31408]}
+	}`))
+	tensors, metadata, err := ReadSafetensorsHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(map[string]SafetensorsTensor{
+		"w":    {"F32", Shape{784, 10}, [2]int64{0, 31360}},
+		"b":    {"F32", Shape{10}, [2]int64{31360, 31400}},
+		"step": {"I64", Shape{}, [2]int64{31400, 31408}},
+	}, tensors)
+	assert.Equal(map[string]string{"format": "pt"}, metadata)
+	rest, _ := ioutil.ReadAll(r)
+	assert.Equal("DATA", string(rest))
+
+	bad := [][]byte{
+		safetensors(`{"w": {"dtype": "F32", "shape": [784, 10]`),
+		safetensors(`{"w": {"dtype": "F32"}}`),
+		safetensors(`{"w": {"dtype": "F32", "shape": [-1]}}`)[:10],
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+	for i, in := range bad {
+		_, _, err := ReadSafetensorsHeader(bytes.NewReader(in))
+		checkErr(t, true, err, "ReadSafetensorsHeader", i)
+	}
+}
+
+func ExampleReadSafetensorsHeader() {
+	// a checkpoint of a linear layer. The data is not read
+	var buf bytes.Buffer
+	header := `{"w":{"dtype":"F32","shape":[784,10],"data_offsets":[0,31360]},"b":{"dtype":"F32","shape":[10],"data_offsets":[31360,31400]}}`
+	binary.Write(&buf, binary.LittleEndian, uint64(len(header)))
+	buf.WriteString(header)
+
+	tensors, _, err := ReadSafetensorsHeader(&buf)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	type linear struct {
+		W Shape `shape:"(a, b)"`
+		B Shape `shape:"(b)"`
+	}
+	ok := linear{W: tensors["w"].Shape, B: tensors["b"].Shape}
+	fmt.Println(Verify(ok) == nil)
+
+	transposed := linear{W: Shape{10, 784}, B: tensors["b"].Shape}
+	fmt.Println(Verify(transposed) == nil)
+
+	// Output:
+	// true
+	// false
+}
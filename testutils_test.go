@@ -15,3 +15,13 @@ func checkErr(t *testing.T, expected bool, err error, name string, id interface{
 	}
 	return false
 }
+
+// checkShape checks that got is the expected expression. If it is not, the axes that differ are reported side by side.
+func checkShape(t *testing.T, expected, got Expr, name string, id interface{}) (ok bool) {
+	t.Helper()
+	if eq(expected, got) {
+		return true
+	}
+	t.Errorf("Test %v (%v): expected %v of %T. Got %v of %T instead\n%v", name, id, expected, expected, got, got, DiffReport(expected, got))
+	return false
+}
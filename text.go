@@ -0,0 +1,64 @@
+package shapes
+
+import (
+	"encoding/gob"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// text.go describes the text encoding of Shape and Abstract, which is the same as the canonical syntax, e.g. (2, 3, a).
+//
+// Because Shape and Abstract implement encoding.TextMarshaler and encoding.TextUnmarshaler, they may be used as
+// flag values (flag.TextVar) and in text based formats such as YAML and TOML. encoding/gob uses the text encoding of an Abstract too,
+// as its dimensions are interfaces.
+
+func init() {
+	// Shape and Abstract are registered so that they may be sent as the values of Expr fields with encoding/gob.
+	gob.Register(Shape{})
+	gob.Register(Abstract{})
+}
+
+// MarshalText returns the shape in the canonical syntax, e.g. (2, 3).
+func (s Shape) MarshalText() ([]byte, error) { return []byte(fmt.Sprint(s)), nil }
+
+// UnmarshalText parses a shape written in the canonical syntax, e.g. (2, 3).
+func (s *Shape) UnmarshalText(text []byte) error {
+	e, err := Parse(string(text))
+	if err != nil {
+		return errors.Wrapf(err, "Unable to unmarshal %q into a Shape", text)
+	}
+	sh, ok := e.(Shape)
+	if !ok {
+		return errors.Errorf("Unable to unmarshal %q into a Shape. Got %v of %T instead", text, e, e)
+	}
+	*s = sh
+	return nil
+}
+
+// MarshalText returns the abstract shape in the canonical syntax, e.g. (2, 3, a).
+func (a Abstract) MarshalText() ([]byte, error) { return []byte(fmt.Sprint(a)), nil }
+
+// UnmarshalText parses an abstract shape written in the canonical syntax, e.g. (2, 3, a).
+// A shape without any variables, e.g. (2, 3), is accepted too.
+func (a *Abstract) UnmarshalText(text []byte) error {
+	e, err := Parse(string(text))
+	if err != nil {
+		return errors.Wrapf(err, "Unable to unmarshal %q into an Abstract", text)
+	}
+	switch et := e.(type) {
+	case Abstract:
+		*a = et
+	case Shape:
+		*a = et.toAbs(len(et))
+	default:
+		return errors.Errorf("Unable to unmarshal %q into an Abstract. Got %v of %T instead", text, e, e)
+	}
+	return nil
+}
+
+// GobEncode encodes the abstract shape in the canonical syntax for encoding/gob.
+func (a Abstract) GobEncode() ([]byte, error) { return a.MarshalText() }
+
+// GobDecode decodes an abstract shape encoded by GobEncode.
+func (a *Abstract) GobDecode(data []byte) error { return a.UnmarshalText(data) }
@@ -0,0 +1,125 @@
+package shapes
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var shapeTextTests = []struct {
+	in      string
+	correct Shape
+	err     bool
+}{
+	{"(2, 3)", Shape{2, 3}, false},
+	{"(2)", Shape{2}, false},
+	{"()", Shape{}, false},
+	{"(2, a)", nil, true},
+	{"a → a", nil, true},
+	{"(2, 3", nil, true},
+}
+
+func TestShape_UnmarshalText(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range shapeTextTests {
+		var s Shape
+		err := s.UnmarshalText([]byte(c.in))
+		if checkErr(t, c.err, err, "Shape.UnmarshalText", c.in) {
+			continue
+		}
+		assert.Equal(c.correct, s, "Shape.UnmarshalText(%q)", c.in)
+
+		text, err := s.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(c.in, string(text))
+	}
+}
+
+var abstractTextTests = []struct {
+	in      string
+	correct Abstract
+	err     bool
+}{
+	{"(2, 3, a)", Abstract{Size(2), Size(3), Var('a')}, false},
+	{"(a)", Abstract{Var('a')}, false},
+	{"(a + b, Π c)", Abstract{BinOp{Add, Var('a'), Var('b')}, UnaryOp{Prod, Var('c')}}, false},
+	{"(2, 3)", Abstract{Size(2), Size(3)}, false},
+	{"(S)", Abstract{Var('S')}, false},
+	{"(T, Σ S, X)", Abstract{Var('T'), UnaryOp{Sum, Var('S')}, Var('X')}, false},
+	{"a", nil, true},
+	{"(a, b) → (b, a)", nil, true},
+}
+
+func TestAbstract_UnmarshalText(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range abstractTextTests {
+		var a Abstract
+		err := a.UnmarshalText([]byte(c.in))
+		if checkErr(t, c.err, err, "Abstract.UnmarshalText", c.in) {
+			continue
+		}
+		assert.Equal(c.correct, a, "Abstract.UnmarshalText(%q)", c.in)
+
+		text, err := a.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(c.in, string(text))
+	}
+}
+
+func TestGob(t *testing.T) {
+	type checkpoint struct {
+		Input  Expr
+		Output Expr
+		Shape  Shape
+	}
+	in := checkpoint{
+		Input:  Abstract{Var('a'), BinOp{Mul, Var('b'), Size(2)}},
+		Output: Shape{2, 3},
+		Shape:  Shape{4},
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var out checkpoint
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, in, out)
+
+	// variables that are spelled like keywords
+	in = checkpoint{Input: Abstract{Var('S'), Var('T'), Var('X')}, Output: Abstract{Var('D'), Var('K'), Var('P')}}
+	buf.Reset()
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	out = checkpoint{}
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, in, out)
+}
+
+func ExampleAbstract_MarshalText() {
+	a := Abstract{Size(2), Size(3), Var('a')}
+	text, _ := a.MarshalText()
+	fmt.Printf("%s\n", text)
+
+	var b Abstract
+	if err := b.UnmarshalText([]byte("(b, 2 × b)")); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Printf("%v of %T\n", b[1], b[1])
+
+	// Output:
+	// (2, 3, a)
+	// 2 × b of shapes.BinOp
+}
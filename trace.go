@@ -0,0 +1,160 @@
+package shapes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// trace.go describes how to record the steps that the inferencer takes, so that a surprising result may be explained, e.g.
+//
+//	retVal, tr, err := shapes.InferExplain(matmul, shapes.Shape{2, 3}, shapes.Shape{3, 4})
+//	fmt.Println(tr)
+//
+// Unlike the logging of the debug build, a trace is asked for at runtime. When no trace is asked for, nothing is recorded.
+
+// StepKind is the kind of a Step.
+type StepKind byte
+
+const (
+	// StepApply is the application of a function to an argument.
+	StepApply StepKind = iota
+	// StepAlpha is the renaming of the variables of an argument that clash with those of the function.
+	StepAlpha
+	// StepFresh is the fresh variable that stands for the result of an application.
+	StepFresh
+	// StepConstraint is a constraint that is about to be solved.
+	StepConstraint
+	// StepUnify is the unification of two expressions.
+	StepUnify
+	// StepBind is the substitution of a variable, made by unification.
+	StepBind
+	// StepCompose is the substitutions that solve the constraints so far, once a constraint has been unified.
+	StepCompose
+	// StepResolve is the rewrite of an expression into a simpler one, e.g. (2, 3)[1] ⇒ (3).
+	StepResolve
+	// StepCheck is the check of a SubjectTo.
+	StepCheck
+)
+
+// String returns the name of the kind.
+func (k StepKind) String() string {
+	switch k {
+	case StepApply:
+		return "apply"
+	case StepAlpha:
+		return "alpha"
+	case StepFresh:
+		return "fresh"
+	case StepConstraint:
+		return "constraint"
+	case StepUnify:
+		return "unify"
+	case StepBind:
+		return "bind"
+	case StepCompose:
+		return "compose"
+	case StepResolve:
+		return "resolve"
+	case StepCheck:
+		return "check"
+	}
+	return fmt.Sprintf("StepKind(%d)", byte(k))
+}
+
+// Step is a step taken by the inferencer.
+type Step struct {
+	Kind  StepKind
+	Arg   int    // the argument being applied, starting from 1
+	Depth int    // how deeply nested the unification of sub-expressions is
+	Desc  string // a description of the step, e.g. "b ↦ 3"
+}
+
+// Trace is the record of the steps taken by the inferencer. A nil *Trace records nothing.
+type Trace struct {
+	Steps []Step
+
+	arg   int
+	depth int
+}
+
+// step records a step, described by the format and its arguments.
+func (tr *Trace) step(kind StepKind, format string, args ...interface{}) {
+	if tr == nil {
+		return
+	}
+	tr.Steps = append(tr.Steps, Step{Kind: kind, Arg: tr.arg, Depth: tr.depth, Desc: fmt.Sprintf(format, args...)})
+}
+
+// subs records substitutions as a step. Nothing is recorded if there are no substitutions.
+func (tr *Trace) subs(kind StepKind, ss substitutions) {
+	if tr == nil || len(ss) == 0 {
+		return
+	}
+	descs := make([]string, 0, len(ss))
+	for _, s := range ss {
+		descs = append(descs, fmt.Sprintf("%v ↦ %v", s.For, s.Sub))
+	}
+	tr.step(kind, "%s", strings.Join(descs, ", "))
+}
+
+// rewrite records the rewrite of an expression. Nothing is recorded if the expression looks the same after the rewrite,
+// e.g. when an Abstract of Sizes becomes a Shape.
+func (tr *Trace) rewrite(from, to Expr) {
+	if tr == nil {
+		return
+	}
+	f, t := fmt.Sprintf("%v", from), fmt.Sprintf("%v", to)
+	if f == t {
+		return
+	}
+	tr.step(StepResolve, "%s ⇒ %s", f, t)
+}
+
+// at sets the depth of the steps that follow.
+func (tr *Trace) at(depth int) {
+	if tr != nil {
+		tr.depth = depth
+	}
+}
+
+// Format writes the steps one per line. The steps of each application are indented under it, as are the unifications of
+// sub-expressions under the unification of their parents.
+func (tr *Trace) Format(s fmt.State, r rune) {
+	if tr == nil {
+		return
+	}
+	for i, st := range tr.Steps {
+		if i > 0 {
+			fmt.Fprintln(s)
+		}
+		indent := st.Depth
+		if st.Kind != StepApply {
+			indent++
+		}
+		fmt.Fprintf(s, "%s%-10s %s", strings.Repeat("  ", indent), st.Kind, st.Desc)
+	}
+}
+
+// String returns the steps one per line.
+func (tr *Trace) String() string { return fmt.Sprintf("%v", tr) }
+
+// InferExplain is like InferApp, except that it also returns a trace of each step that was taken to infer the result:
+// the alpha renaming of the arguments, the constraints, the substitutions made by unification and those that solve the constraints,
+// the rewrites made when resolving the result, and the checks of any SubjectTo.
+//
+// The trace is returned even if the inference fails, in which case its last steps lead up to the failure.
+// As with InferApp, a failure to apply an argument is an *ArgError.
+func InferExplain(a Expr, others ...Expr) (retVal Expr, tr *Trace, err error) {
+	tr = new(Trace)
+	if len(others) == 0 {
+		return nil, tr, errors.New("Expected at least one other shape expression in order to InferExplain")
+	}
+	args := make([]Arg, 0, len(others))
+	for _, e := range others {
+		args = append(args, Arg{Expr: e})
+	}
+	retVal, err = inferArgs(tr, a, args...)
+	return retVal, tr, err
+}
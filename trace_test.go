@@ -0,0 +1,145 @@
+package shapes
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var traceMatMul = MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')})
+
+var inferExplainTests = []struct {
+	name   string
+	fn     Expr
+	others []Expr
+
+	correct Expr
+	err     bool
+	steps   []Step // steps that must be in the trace, in order. Depth is not checked.
+}{
+	{"matmul", traceMatMul, []Expr{Shape{2, 3}, Shape{3, 4}}, Shape{2, 4}, false, []Step{
+		{Kind: StepApply, Arg: 1, Desc: "(a, b) → (b, c) → (a, c) @ (2, 3)"},
+		{Kind: StepConstraint, Arg: 1, Desc: "{(a, b) → (b, c) → (a, c) = (2, 3) → d}"},
+		{Kind: StepBind, Arg: 1, Desc: "b ↦ 3"},
+		{Kind: StepCompose, Arg: 1, Desc: "d ↦ (3, c) → (2, c), b ↦ 3, a ↦ 2"},
+		{Kind: StepApply, Arg: 2, Desc: "(3, c) → (2, c) @ (3, 4)"},
+		{Kind: StepBind, Arg: 2, Desc: "c ↦ 4"},
+	}},
+	{"alpha", MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b')}), []Expr{Abstract{Var('a'), Size(3)}}, Shape{3}, false, []Step{
+		{Kind: StepAlpha, Arg: 1, Desc: "a ↦ c"},
+		{Kind: StepFresh, Arg: 1, Desc: "d"},
+		{Kind: StepBind, Arg: 1, Desc: "d ↦ (3)"},
+	}},
+	{"resolve", MakeArrow(Abstract{Var('a'), Var('b')}, IndexOf{1, Abstract{Var('a'), Var('b')}}), []Expr{Shape{2, 3}}, Shape{3}, false, []Step{
+		{Kind: StepBind, Arg: 1, Desc: "b ↦ 3"},
+		{Kind: StepResolve, Arg: 1, Desc: "(2, 3)[1] ⇒ (3)"},
+	}},
+	{"SubjectTo", Compound{MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('a')}), SubjectTo{Lt, IndexOf{1, Abstract{Var('a'), Var('b')}}, Size(4)}},
+		[]Expr{Shape{2, 5}}, nil, true, []Step{
+			{Kind: StepCheck, Arg: 1, Desc: "((2, 5)[1] < 4) ⇒ false"},
+		}},
+	{"unification failure", traceMatMul, []Expr{Shape{2, 3}, Shape{4, 5}}, nil, true, []Step{
+		{Kind: StepApply, Arg: 2, Desc: "(3, c) → (2, c) @ (4, 5)"},
+		{Kind: StepUnify, Arg: 2, Desc: "3 ~ 4"},
+	}},
+	{"not an arrow", Shape{2}, []Expr{Shape{2}}, nil, true, []Step{
+		{Kind: StepApply, Arg: 1, Desc: "(2) @ (2)"},
+	}},
+	{"Compound of a Var", Compound{Var('a'), SubjectTo{Eq, Size(1), Size(1)}}, []Expr{Shape{2}}, nil, true, []Step{
+		{Kind: StepApply, Arg: 1, Desc: "{ a | (1 = 1) } @ (2)"},
+	}},
+	{"Application arg", traceMatMul, []Expr{Shape{2, 3}, Application{Arrow{Var('a'), Var('a')}, Shape{3, 4}}}, Shape{2, 4}, false, []Step{
+		{Kind: StepApply, Arg: 2, Desc: "(3, c) → (2, c) @ (3, 4)"},
+		{Kind: StepBind, Arg: 2, Desc: "c ↦ 4"},
+	}},
+	{"nested Application", Application{traceMatMul, Shape{2, 3}}, []Expr{Application{Arrow{Var('a'), Var('a')}, Shape{3, 4}}}, Shape{2, 4}, false, []Step{
+		{Kind: StepApply, Arg: 1, Desc: "(a, b) → (b, c) → (a, c) @ (2, 3)"},
+		{Kind: StepBind, Arg: 1, Desc: "b ↦ 3"},
+		{Kind: StepApply, Arg: 1, Desc: "a → a @ (3, 4)"},
+		{Kind: StepApply, Arg: 1, Desc: "(3, c) → (2, c) @ (3, 4)"},
+		{Kind: StepBind, Arg: 1, Desc: "c ↦ 4"},
+	}},
+	{"no args", traceMatMul, nil, nil, true, nil},
+}
+
+func TestInferExplain(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range inferExplainTests {
+		got, tr, err := InferExplain(c.fn, c.others...)
+		if !assert.NotNil(tr, c.name) {
+			continue
+		}
+
+		// the steps must be found in order
+		j := 0
+		for _, st := range tr.Steps {
+			if j < len(c.steps) && st.Kind == c.steps[j].Kind && st.Arg == c.steps[j].Arg && st.Desc == c.steps[j].Desc {
+				j++
+			}
+		}
+		if j < len(c.steps) {
+			t.Errorf("%v: expected step %v %q of arg %d in the trace\n%v", c.name, c.steps[j].Kind, c.steps[j].Desc, c.steps[j].Arg, tr)
+		}
+
+		if checkErr(t, c.err, err, "InferExplain", c.name) {
+			continue
+		}
+		checkShape(t, c.correct, got, "InferExplain", c.name)
+
+		// the trace does not change the result
+		want, err := InferApp(c.fn, c.others...)
+		assert.NoError(err, c.name)
+		assert.Equal(want, got, c.name)
+	}
+
+	// failures have the same provenance as those of InferApp
+	_, _, err := InferExplain(traceMatMul, Shape{2, 3}, Shape{4, 5})
+	var ae *ArgError
+	if assert.True(errors.As(err, &ae), "Expected an *ArgError. Got %v of %T instead", err, err) {
+		assert.Equal("arg 2 dim 0: expected b=3 from arg 1, got 4", err.Error())
+	}
+
+	// nothing is recorded without a trace
+	var tr *Trace
+	tr.step(StepApply, "%v", Shape{2})
+	assert.Equal("", tr.String())
+}
+
+func TestTraceFormat(t *testing.T) {
+	_, tr, err := InferExplain(traceMatMul, Shape{2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(tr.String(), "\n")
+	assert.Len(t, lines, len(tr.Steps))
+	assert.Equal(t, "apply      (a, b) → (b, c) → (a, c) @ (2, 3)", lines[0])
+	assert.Equal(t, "      bind       a ↦ 2", lines[6])
+}
+
+func ExampleInferExplain() {
+	fn := MakeArrow(Abstract{Var('a'), Var('b')}, Abstract{Var('b'), Var('c')}, Abstract{Var('a'), Var('c')})
+	retVal, tr, err := InferExplain(fn, Shape{2, 3})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(retVal)
+	fmt.Println(tr)
+
+	// Output:
+	// (3, c) → (2, c)
+	// apply      (a, b) → (b, c) → (a, c) @ (2, 3)
+	//   fresh      d
+	//   constraint {(a, b) → (b, c) → (a, c) = (2, 3) → d}
+	//   unify      (a, b) → (b, c) → (a, c) ~ (2, 3) → d
+	//     unify      (a, b) ~ (2, 3)
+	//       unify      a ~ 2
+	//       bind       a ↦ 2
+	//       unify      b ~ 3
+	//       bind       b ↦ 3
+	//     unify      (3, c) → (2, c) ~ d
+	//     bind       d ↦ (3, c) → (2, c)
+	//   compose    d ↦ (3, c) → (2, c), b ↦ 3, a ↦ 2
+}
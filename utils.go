@@ -84,6 +84,14 @@ func sizesToInts(a Sizes) []int {
 	return *(*[]int)(unsafe.Pointer(&a))
 }
 
+func intsToAxes(a []int) Axes {
+	return *(*Axes)(unsafe.Pointer(&a))
+}
+
+func intsToSizes(a []int) Sizes {
+	return *(*Sizes)(unsafe.Pointer(&a))
+}
+
 func arrowToTup(a *Arrow) *exprtup {
 	return (*exprtup)(unsafe.Pointer(a))
 }
@@ -155,7 +163,7 @@ func UnsafePermute(pattern []int, xs ...[]int) (err error) {
 			dims = len(x)
 		}
 		if d != dims || d != patLen {
-			err = errors.Errorf(dimsMismatch, len(x), len(pattern))
+			err = newDimMismatchError(dimsMismatch, len(x), len(pattern), nil)
 			return
 		}
 
@@ -228,7 +236,7 @@ func genericUnsafePermute(pattern []int, xs ...interface{}) (err error) {
 		}
 		d = v.Len()
 		if d != dims || d != patLen {
-			return errors.Errorf(dimsMismatch, d, len(pattern))
+			return newDimMismatchError(dimsMismatch, d, len(pattern), nil)
 		}
 
 		// all good? now we cast the data into a byte slice.
@@ -299,12 +307,12 @@ func patternCheck(pattern []int, dims int) (err error) {
 	seen := make(map[int]struct{})
 	for _, a := range pattern {
 		if a >= dims {
-			err = errors.Errorf(invalidAxis, a, dims)
+			err = newInvalidAxisError(Axis(a), dims, nil)
 			return
 		}
 
 		if _, ok := seen[a]; ok {
-			err = errors.Errorf(repeatedAxis, a)
+			err = newRepeatedAxisError(Axis(a), dims, nil)
 			return
 		}
 
@@ -446,7 +454,7 @@ func AreBroadcastable(a, b Shape) (err error) {
 		return noopError{}
 	}
 	if a.Dims() != b.Dims() {
-		return errors.Errorf(dimMismatch, a.Dims(), b.Dims())
+		return newDimMismatchError(dimMismatch, a.Dims(), b.Dims(), b)
 	}
 	maxDim := a.Dims()
 
@@ -456,7 +464,7 @@ func AreBroadcastable(a, b Shape) (err error) {
 		bDim := a[i]
 		aDim := b[i]
 		if bDim != aDim && bDim != 1 && aDim != 1 {
-			return errors.Errorf(broadcastErr, a, b, i)
+			return newBroadcastError(a, b, i)
 		}
 	}
 	return
@@ -3,6 +3,7 @@ package shapes
 import (
 	"sort"
 
+	"github.com/pkg/errors"
 	"github.com/xtgo/set"
 )
 
@@ -28,3 +29,15 @@ func unique(a varset) varset {
 	n := set.Uniq(a)
 	return a[:n]
 }
+
+// freshVar returns the first letter of the generator alphabet that is not used.
+func freshVar(used varset) (Var, error) {
+	for _, r := range generator {
+		for l := r.start; l <= r.end; l++ {
+			if !used.Contains(Var(l)) {
+				return Var(l), nil
+			}
+		}
+	}
+	return 0, errors.Errorf("Ran out of variables. %d variables are in use", len(used))
+}
@@ -55,7 +55,7 @@ func Verify(any interface{}) error {
 	var cs constraints
 	for k, s := range m {
 		v := m2[k]
-		cs = append(cs, exprConstraint{s, v})
+		cs = append(cs, exprConstraint{a: s, b: v})
 	}
 	_, err := solve(cs, nil)
 
@@ -0,0 +1,193 @@
+package shapes
+
+import (
+	"github.com/pkg/errors"
+)
+
+// View describes how a multidimensional array is read from a flat slice of memory.
+// The element at the coordinate c is found at Offset + Σ c[i] × Strides[i].
+//
+// Where Shape.S and Shape.T only compute the resulting sizes, the methods of View also keep track of the strides and offset,
+// so that slicing, transposing, reshaping and broadcasting can all be done without copying any data.
+type View struct {
+	Shape   Shape
+	Strides []int
+	Offset  int
+}
+
+// MakeView creates a View of a contiguous array of shape s, laid out in the given order.
+func MakeView(s Shape, o DataOrder) View {
+	return View{Shape: s.Clone(), Strides: s.Strides(o)}
+}
+
+// IsContiguous returns true if the view reads a contiguous block of memory.
+func (v View) IsContiguous() bool { return IsContiguous(v.Shape, v.Strides) }
+
+// S slices the view. The resulting shape is the same as the one computed by Shape.S.
+func (v View) S(slices ...Slice) (retVal View, err error) {
+	dims := len(v.Shape)
+	if len(slices) > dims {
+		err = newDimMismatchError(dimsMismatch, dims, len(slices), v.Shape)
+		return
+	}
+
+	shp := make(Shape, 0, dims)
+	strides := make([]int, 0, dims)
+	offset := v.Offset
+	for d, size := range v.Shape {
+		var sl Slice // default is a nil Slice
+		if d < len(slices) {
+			sl = slices[d]
+		}
+		var start, step, newSize int
+		if start, _, step, err = SliceDetails(sl, size); err != nil {
+			return View{}, errors.Wrapf(err, "Unable to slice view %v. Dim %d caused an error", v.Shape, d)
+		}
+		if newSize, err = sliceSize(sl, size); err != nil {
+			return View{}, errors.Wrapf(err, "Unable to slice view %v. Dim %d caused an error", v.Shape, d)
+		}
+		offset += start * v.Strides[d]
+
+		// like Shape.S, drop any sliced dimension that has a size of 1
+		if newSize == 1 && sl != nil {
+			continue
+		}
+		shp = append(shp, newSize)
+		strides = append(strides, v.Strides[d]*step)
+	}
+
+	if shp.IsScalar() {
+		return View{Shape: ScalarShape(), Offset: offset}, nil
+	}
+	return View{Shape: shp, Strides: strides, Offset: offset}, nil
+}
+
+// T transposes the view by permuting its strides. Like Shape.T, a NoOpError is returned if the permutation does nothing.
+func (v View) T(axes ...Axis) (retVal View, err error) {
+	shp, strides, err := v.Shape.TStrides(v.Strides, axes...)
+	if _, ok := err.(NoOpError); err != nil && !ok {
+		return View{}, err
+	}
+	return View{Shape: shp, Strides: strides, Offset: v.Offset}, err
+}
+
+// Reshape returns a view with the new shape, reading the same memory in RowMajor order.
+// An error is returned if the reshape cannot be expressed with strides, i.e. if a copy would be required.
+func (v View) Reshape(s Shape) (retVal View, err error) {
+	if s.TotalSize() != v.Shape.TotalSize() {
+		return View{}, errors.Errorf("Cannot reshape %v into %v. The total sizes differ.", v.Shape, s)
+	}
+	if s.TotalSize() == 0 {
+		retVal = MakeView(s, RowMajor)
+		retVal.Offset = v.Offset
+		return retVal, nil
+	}
+
+	// axes of size 1 do not matter
+	var oldDims, oldStrides []int
+	for i, size := range v.Shape {
+		if size != 1 {
+			oldDims = append(oldDims, size)
+			oldStrides = append(oldStrides, v.Strides[i])
+		}
+	}
+
+	// walk the old and new shapes in lockstep, grouping axes which have the same product of sizes.
+	// Each group of old axes must be contiguous amongst itself.
+	strides := make([]int, len(s))
+	oi, oj := 0, 1
+	ni, nj := 0, 1
+	for ni < len(s) && oi < len(oldDims) {
+		np := s[ni]
+		op := oldDims[oi]
+		for np != op {
+			if np < op {
+				np *= s[nj]
+				nj++
+			} else {
+				op *= oldDims[oj]
+				oj++
+			}
+		}
+
+		for k := oi; k < oj-1; k++ {
+			if oldStrides[k] != oldDims[k+1]*oldStrides[k+1] {
+				return View{}, errors.Errorf("Cannot reshape %v (strides %v) into %v without copying.", v.Shape, v.Strides, s)
+			}
+		}
+
+		strides[nj-1] = oldStrides[oj-1]
+		for k := nj - 1; k > ni; k-- {
+			strides[k-1] = strides[k] * s[k]
+		}
+		ni = nj
+		nj++
+		oi = oj
+		oj++
+	}
+
+	// trailing axes of size 1
+	last := 1
+	if ni >= 1 {
+		last = strides[ni-1]
+	}
+	for k := ni; k < len(s); k++ {
+		strides[k] = last
+	}
+
+	if s.IsScalar() {
+		strides = nil
+	}
+	return View{Shape: s.Clone(), Strides: strides, Offset: v.Offset}, nil
+}
+
+// Broadcast returns a view that reads the memory as if it had the shape `to`, by setting the strides of the broadcast axes to 0.
+// New axes may be prepended, but every existing axis must either match its counterpart in `to` or have a size of 1.
+func (v View) Broadcast(to Shape) (retVal View, err error) {
+	if len(to) < len(v.Shape) {
+		return View{}, newDimMismatchError(dimMismatch, len(v.Shape), len(to), to)
+	}
+	lead := len(to) - len(v.Shape)
+	strides := make([]int, len(to))
+	for i := lead; i < len(to); i++ {
+		d := i - lead
+		switch v.Shape[d] {
+		case to[i]:
+			strides[i] = v.Strides[d]
+		case 1:
+			strides[i] = 0
+		default:
+			return View{}, newBroadcastError(v.Shape, to, i)
+		}
+	}
+	return View{Shape: to.Clone(), Strides: strides, Offset: v.Offset}, nil
+}
+
+// ExpandDims returns a view with a new axis of size 1 inserted at the given axis.
+// Negative axes count from the end of the resulting shape.
+func (v View) ExpandDims(axis Axis) (retVal View, err error) {
+	dims := len(v.Shape)
+	a := int(axis)
+	if a < 0 {
+		a += dims + 1
+	}
+	if a < 0 || a > dims {
+		return View{}, newInvalidAxisError(axis, dims+1, v.Shape)
+	}
+
+	stride := 1
+	if a < dims {
+		stride = v.Strides[a] * v.Shape[a]
+	}
+
+	shp := make(Shape, 0, dims+1)
+	shp = append(shp, v.Shape[:a]...)
+	shp = append(shp, 1)
+	shp = append(shp, v.Shape[a:]...)
+
+	strides := make([]int, 0, dims+1)
+	strides = append(strides, v.Strides[:a]...)
+	strides = append(strides, stride)
+	strides = append(strides, v.Strides[a:]...)
+	return View{Shape: shp, Strides: strides, Offset: v.Offset}, nil
+}
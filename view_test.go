@@ -0,0 +1,209 @@
+package shapes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// materialize reads the data through the view, in RowMajor order.
+func materialize(t *testing.T, v View, data []int) []int {
+	it, err := NewStridedIterator(v.Shape, v.Strides, RowMajor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var retVal []int
+	for it.Next() {
+		retVal = append(retVal, data[v.Offset+it.Offset()])
+	}
+	return retVal
+}
+
+func arange(n int) []int {
+	retVal := make([]int, n)
+	for i := range retVal {
+		retVal[i] = i
+	}
+	return retVal
+}
+
+var viewSliceTests = []struct {
+	name   string
+	s      Shape
+	slices []Slice
+
+	correct []int
+	err     bool
+}{
+	{"row", Shape{2, 3}, []Slice{S(1)}, []int{3, 4, 5}, false},
+	{"col", Shape{2, 3}, []Slice{nil, S(1)}, []int{1, 4}, false},
+	{"element", Shape{2, 3}, []Slice{S(1), S(2)}, []int{5}, false},
+	{"range", Shape{3, 4}, []Slice{S(1, 3), S(1, 3)}, []int{5, 6, 9, 10}, false},
+	{"stepped", Shape{2, 6}, []Slice{nil, S(0, 6, 2)}, []int{0, 2, 4, 6, 8, 10}, false},
+	{"3D", Shape{2, 3, 4}, []Slice{nil, S(1, 3), S(3)}, []int{7, 11, 19, 23}, false},
+	{"too many slices", Shape{2, 3}, []Slice{nil, nil, nil}, nil, true},
+	{"bad slice", Shape{2, 3}, []Slice{S(5)}, nil, true},
+}
+
+func TestView_S(t *testing.T) {
+	assert := assert.New(t)
+	for i, c := range viewSliceTests {
+		v := MakeView(c.s, RowMajor)
+		v2, err := v.S(c.slices...)
+		if checkErr(t, c.err, err, c.name, i) {
+			continue
+		}
+		expectedShape, err := c.s.S(c.slices...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(expectedShape, v2.Shape, "Test %q: the shape should agree with Shape.S", c.name)
+		assert.Equal(c.correct, materialize(t, v2, arange(c.s.TotalSize())), "Test %q", c.name)
+	}
+}
+
+func TestView_T(t *testing.T) {
+	assert := assert.New(t)
+	v := MakeView(Shape{2, 3}, RowMajor)
+	vT, err := v.T(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(Shape{3, 2}, vT.Shape)
+	assert.Equal([]int{0, 3, 1, 4, 2, 5}, materialize(t, vT, arange(6)))
+	assert.True(vT.IsContiguous(), "A transposed matrix is contiguous in ColMajor order")
+
+	if _, err = v.T(0, 1); err == nil {
+		t.Errorf("Expected a NoOpError")
+	} else if _, ok := err.(NoOpError); !ok {
+		t.Errorf("Expected a NoOpError. Got %v instead", err)
+	}
+
+	v3 := MakeView(Shape{2, 3, 4}, RowMajor)
+	v3T, err := v3.T(1, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(v3T.IsContiguous())
+}
+
+func TestView_Reshape(t *testing.T) {
+	assert := assert.New(t)
+	data := arange(24)
+
+	// contiguous arrays can always be reshaped
+	v := MakeView(Shape{2, 3, 4}, RowMajor)
+	v2, err := v.Reshape(Shape{6, 1, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{4, 4, 1}, v2.Strides)
+	assert.Equal(data, materialize(t, v2, data))
+
+	// a sliced array where the sliced axis is not merged
+	sliced, err := v.S(nil, nil, S(0, 4, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v3, err := sliced.Reshape(Shape{6, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(materialize(t, sliced, data), materialize(t, v3, data))
+	assert.False(v3.IsContiguous())
+
+	// merging a transposed axis requires a copy
+	vT, err := v.T(0, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = vT.Reshape(Shape{2, 12}); err == nil {
+		t.Errorf("Expected an error when reshaping would require a copy")
+	}
+	// but splitting it does not
+	v4, err := vT.Reshape(Shape{2, 2, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(materialize(t, vT, data), materialize(t, v4, data))
+
+	// scalars
+	one := MakeView(Shape{1, 1}, RowMajor)
+	s, err := one.Reshape(ScalarShape())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(ScalarShape(), s.Shape)
+
+	if _, err = v.Reshape(Shape{5, 5}); err == nil {
+		t.Errorf("Expected an error when the total sizes differ")
+	}
+}
+
+func TestView_Broadcast(t *testing.T) {
+	assert := assert.New(t)
+	v := MakeView(Shape{3, 1}, RowMajor)
+	bc, err := v.Broadcast(Shape{2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal([]int{0, 1, 0}, bc.Strides)
+	assert.False(bc.IsContiguous())
+	got := materialize(t, bc, arange(3))
+	assert.Equal(24, len(got))
+	assert.Equal([]int{0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2}, got[:12])
+
+	if _, err = v.Broadcast(Shape{2, 4}); err == nil {
+		t.Errorf("Expected an error when broadcasting incompatible shapes")
+	}
+	if _, err = v.Broadcast(Shape{3}); err == nil {
+		t.Errorf("Expected an error when broadcasting to fewer dimensions")
+	}
+}
+
+func TestView_ExpandDims(t *testing.T) {
+	assert := assert.New(t)
+	v := MakeView(Shape{2, 3}, RowMajor)
+	for _, c := range []struct {
+		axis    Axis
+		correct Shape
+	}{
+		{0, Shape{1, 2, 3}},
+		{1, Shape{2, 1, 3}},
+		{2, Shape{2, 3, 1}},
+		{-1, Shape{2, 3, 1}},
+		{-3, Shape{1, 2, 3}},
+	} {
+		v2, err := v.ExpandDims(c.axis)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(c.correct, v2.Shape, "ExpandDims(%d)", c.axis)
+		assert.True(v2.IsContiguous(), "ExpandDims(%d)", c.axis)
+		assert.Equal(arange(6), materialize(t, v2, arange(6)))
+	}
+	if _, err := v.ExpandDims(3); err == nil {
+		t.Errorf("Expected an error for an invalid axis")
+	}
+}
+
+func ExampleView() {
+	v := MakeView(Shape{2, 3, 4}, RowMajor)
+	fmt.Printf("%v\n", v)
+
+	sliced, _ := v.S(nil, S(1))
+	fmt.Printf("Sliced: %v. Contiguous: %t\n", sliced, sliced.IsContiguous())
+
+	transposed, _ := sliced.T(1, 0)
+	fmt.Printf("Transposed: %v. Contiguous: %t\n", transposed, transposed.IsContiguous())
+
+	_, err := transposed.Reshape(Shape{8})
+	fmt.Printf("Reshaping the transposed view requires a copy: %t\n", err != nil)
+
+	// Output:
+	// {(2, 3, 4) [12 4 1] 0}
+	// Sliced: {(2, 4) [12 1] 4}. Contiguous: false
+	// Transposed: {(4, 2) [1 12] 4}. Contiguous: false
+	// Reshaping the transposed view requires a copy: true
+}